@@ -0,0 +1,252 @@
+package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/pkg/ivf"
+)
+
+const (
+	// maxFormulaUpload is the largest formula file accepted by the admin endpoints.
+	maxFormulaUpload  = 10 << 20
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// FormulaAdministrator manages versioned formula sets on behalf of an authenticated actor.
+type FormulaAdministrator interface {
+	Versions(actor string, set string) ([]models.FormulaVersion, error)
+	Formulas(actor string, set string, version int) ([]*models.Formula, error)
+	Validate(actor string, set string, format string, data []byte) (*models.ValidationReport, error)
+	Upload(actor string, set string, source string, format string, data []byte) (*models.ValidationReport, error)
+	Activate(actor string, set string, version int) error
+	Rollback(actor string, set string) (int, error)
+	AuditLog(limit int) ([]models.AuditEntry, error)
+}
+
+// ShadowReporter summarizes the shadow-mode comparison of a candidate formula set with the active one.
+type ShadowReporter interface {
+	ShadowReport() *models.ShadowReport
+}
+
+// adminRoutes lists the admin endpoints, registered when the server has a FormulaAdministrator.
+func (s *Server) adminRoutes() []route {
+	return []route{
+		{"GET /admin/formula-sets/{set}/versions", s.withAdminAuth(s.ListFormulaVersionsHandler)},
+		{"GET /admin/formula-sets/{set}/formulas", s.withAdminAuth(s.ListFormulasHandler)},
+		{"POST /admin/formula-sets/{set}/validate", s.withAdminAuth(s.ValidateFormulasHandler)},
+		{"POST /admin/formula-sets/{set}/versions", s.withAdminAuth(s.UploadFormulasHandler)},
+		{"POST /admin/formula-sets/{set}/versions/{version}/activate", s.withAdminAuth(s.ActivateFormulasHandler)},
+		{"POST /admin/formula-sets/{set}/rollback", s.withAdminAuth(s.RollbackFormulasHandler)},
+		{"GET /admin/audit", s.withAdminAuth(s.AuditLogHandler)},
+	}
+}
+
+// shadowRoutes lists the shadow report endpoint, registered when the server has a ShadowReporter.
+func (s *Server) shadowRoutes() []route {
+	return []route{
+		{"GET /admin/shadow", s.withAdminAuth(s.ShadowReportHandler)},
+	}
+}
+
+// withAdminAuth rejects requests without a bearer token from AdminTokens and passes the actor to the handler.
+func (s *Server) withAdminAuth(next http.HandlerFunc) http.HandlerFunc {
+	return withBearerAuth("admin", next, s.AdminTokens)
+}
+
+// withBearerAuth rejects requests without a bearer token from one of the token maps and passes the actor to the
+// handler.
+func withBearerAuth(realm string, next http.HandlerFunc, tokens ...map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		actor := ""
+		if ok && token != "" {
+			for _, m := range tokens {
+				for t, a := range m {
+					if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+						actor = a
+					}
+				}
+			}
+		}
+		if actor == "" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q`, realm))
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r.WithContext(withAdminActor(r.Context(), actor)))
+	}
+}
+
+func (s *Server) ListFormulaVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	versions, err := s.Admin.Versions(adminActor(r), r.PathValue("set"))
+	if err != nil {
+		s.adminError(w, r, err)
+		return
+	}
+	writeJSON(w, struct {
+		Versions []models.FormulaVersion `json:"versions"`
+	}{versions})
+}
+
+func (s *Server) ListFormulasHandler(w http.ResponseWriter, r *http.Request) {
+	version, err := optionalInt(r.URL.Query(), "version", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	formulas, err := s.Admin.Formulas(adminActor(r), r.PathValue("set"), version)
+	if err != nil {
+		s.adminError(w, r, err)
+		return
+	}
+
+	var definitions bytes.Buffer
+	if err := ivf.WriteFormulaDefinitions(&definitions, formulas); err != nil {
+		s.adminError(w, r, err)
+		return
+	}
+	writeJSON(w, struct {
+		Formulas json.RawMessage `json:"formulas"`
+	}{definitions.Bytes()})
+}
+
+func (s *Server) ValidateFormulasHandler(w http.ResponseWriter, r *http.Request) {
+	format, data, ok := s.readFormulaUpload(w, r)
+	if !ok {
+		return
+	}
+	report, err := s.Admin.Validate(adminActor(r), r.PathValue("set"), format, data)
+	if err != nil {
+		s.adminError(w, r, err)
+		return
+	}
+	writeJSON(w, report)
+}
+
+func (s *Server) UploadFormulasHandler(w http.ResponseWriter, r *http.Request) {
+	format, data, ok := s.readFormulaUpload(w, r)
+	if !ok {
+		return
+	}
+	actor := adminActor(r)
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		source = "upload by " + actor
+	}
+
+	report, err := s.Admin.Upload(actor, r.PathValue("set"), source, format, data)
+	if err != nil {
+		s.adminError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if report.Valid {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+func (s *Server) ActivateFormulasHandler(w http.ResponseWriter, r *http.Request) {
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil || version < 1 {
+		http.Error(w, fmt.Sprintf("version must be a positive integer. Got %s", r.PathValue("version")),
+			http.StatusBadRequest)
+		return
+	}
+	set := r.PathValue("set")
+	if err := s.Admin.Activate(adminActor(r), set, version); err != nil {
+		s.adminError(w, r, err)
+		return
+	}
+	writeActiveVersion(w, set, version)
+}
+
+func (s *Server) RollbackFormulasHandler(w http.ResponseWriter, r *http.Request) {
+	set := r.PathValue("set")
+	version, err := s.Admin.Rollback(adminActor(r), set)
+	if err != nil {
+		s.adminError(w, r, err)
+		return
+	}
+	writeActiveVersion(w, set, version)
+}
+
+func (s *Server) AuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := intParam(r.URL.Query(), "limit", defaultAuditLimit, 1, maxAuditLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := s.Admin.AuditLog(limit)
+	if err != nil {
+		s.adminError(w, r, err)
+		return
+	}
+	writeJSON(w, struct {
+		Entries []models.AuditEntry `json:"entries"`
+	}{entries})
+}
+
+func (s *Server) ShadowReportHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.Shadow.ShadowReport())
+}
+
+// readFormulaUpload reads the formula file from the request body. The format is the format query parameter,
+// or is taken from the Content-Type: text/csv or application/json.
+func (s *Server) readFormulaUpload(w http.ResponseWriter, r *http.Request) (string, []byte, bool) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "text/csv":
+			format = "csv"
+		case "application/json":
+			format = "json"
+		}
+	}
+	if format != "csv" && format != "json" {
+		http.Error(w, "format must be csv or json, set it with the format parameter or a text/csv or "+
+			"application/json Content-Type", http.StatusBadRequest)
+		return "", nil, false
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxFormulaUpload))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error reading the formula file: %v", err), http.StatusBadRequest)
+		return "", nil, false
+	}
+	return format, data, true
+}
+
+func writeActiveVersion(w http.ResponseWriter, set string, version int) {
+	writeJSON(w, struct {
+		FormulaSet    string `json:"formula_set"`
+		ActiveVersion int    `json:"active_version"`
+	}{set, version})
+}
+
+// adminError reports missing sets and versions as 404, actions that don't apply as 409 and anything else as 500.
+func (s *Server) adminError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, models.ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		s.Logger.Printf("request_id=%s admin error: %v", RequestIDFromContext(r.Context()), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/internal/repo"
+	"ivf_calculator/internal/server"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAdminTestServer(t *testing.T) *Server {
+	db, err := repo.NewSQLiteFormula(&repo.SQLiteConfig{Path: filepath.Join(t.TempDir(), "formulas.db")})
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	referenceCases, err := repo.LoadReferenceCases(referenceCasesPath)
+	require.NoError(t, err)
+
+	return New(&Config{
+		Logger:      log.New(io.Discard, "", 0),
+		IVFService:  &stubCalculator{rate: 62.21},
+		Admin:       server.NewFormulaAdmin(&server.AdminConfig{Store: db, ReferenceCases: referenceCases}),
+		AdminTokens: map[string]string{"secret": "alice"},
+	})
+}
+
+func adminRequest(t *testing.T, s *Server, method string, target string, contentType string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rr := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAdminAuth(t *testing.T) {
+	s := newAdminTestServer(t)
+
+	for name, authorization := range map[string]string{
+		"No token":      "",
+		"Unknown token": "Bearer other",
+		"Basic auth":    "Basic c2VjcmV0",
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/audit", nil)
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+			rr := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusUnauthorized, rr.Code)
+			assert.Equal(t, `Bearer realm="admin"`, rr.Header().Get("WWW-Authenticate"))
+		})
+	}
+
+	t.Run("Disabled without an administrator", func(t *testing.T) {
+		rr := adminRequest(t, newTestServer(CORSConfig{}), http.MethodGet, "/admin/audit", "", "")
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestAdminFormulaSets(t *testing.T) {
+	s := newAdminTestServer(t)
+	csv, err := os.ReadFile("../internal/repo/data/ivf_success_formulas.csv")
+	require.NoError(t, err)
+
+	rr := adminRequest(t, s, http.MethodGet, "/admin/formula-sets/cdc/versions", "", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = adminRequest(t, s, http.MethodPost, "/admin/formula-sets/cdc/validate", "text/plain", string(csv))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = adminRequest(t, s, http.MethodPost, "/admin/formula-sets/cdc/validate", "text/csv", string(csv))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var report struct {
+		Valid   bool `json:"valid"`
+		Version int  `json:"version"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.True(t, report.Valid)
+	assert.Zero(t, report.Version)
+
+	for version := 1; version <= 2; version++ {
+		rr = adminRequest(t, s, http.MethodPost, "/admin/formula-sets/cdc/versions?format=csv&source=cdc.csv", "",
+			string(csv))
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+		assert.Equal(t, version, report.Version)
+
+		rr = adminRequest(t, s, http.MethodPost, "/admin/formula-sets/cdc/versions/"+strconv.Itoa(version)+
+			"/activate", "", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	rr = adminRequest(t, s, http.MethodPost, "/admin/formula-sets/cdc/versions", "application/json", "[]")
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+	rr = adminRequest(t, s, http.MethodPost, "/admin/formula-sets/cdc/versions/x/activate", "", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = adminRequest(t, s, http.MethodPost, "/admin/formula-sets/cdc/versions/9/activate", "", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = adminRequest(t, s, http.MethodPost, "/admin/formula-sets/cdc/rollback", "", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"formula_set":"cdc","active_version":1}`, rr.Body.String())
+	rr = adminRequest(t, s, http.MethodPost, "/admin/formula-sets/cdc/rollback", "", "")
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	rr = adminRequest(t, s, http.MethodGet, "/admin/formula-sets/cdc/formulas?version=2", "", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var definitions struct {
+		Formulas []struct {
+			CDCFormula string `json:"cdc_formula"`
+		} `json:"formulas"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &definitions))
+	assert.Len(t, definitions.Formulas, 6)
+
+	rr = adminRequest(t, s, http.MethodGet, "/admin/audit?limit=2", "", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var audit struct {
+		Entries []struct {
+			Actor  string `json:"actor"`
+			Action string `json:"action"`
+		} `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &audit))
+	require.Len(t, audit.Entries, 2)
+	assert.Equal(t, "list_formulas", audit.Entries[0].Action)
+	assert.Equal(t, "rollback", audit.Entries[1].Action)
+	assert.Equal(t, "alice", audit.Entries[1].Actor)
+}
+
+// stubShadow reports a fixed shadow comparison.
+type stubShadow struct{}
+
+func (s *stubShadow) ShadowReport() *models.ShadowReport {
+	return &models.ShadowReport{Candidate: "candidate.csv", Since: "2024-01-01T00:00:00Z", Requests: 3, Errors: 1,
+		Formulas: []models.ShadowFormula{}}
+}
+
+func TestShadowReportHandler(t *testing.T) {
+	s := New(&Config{
+		Logger:      log.New(io.Discard, "", 0),
+		IVFService:  &stubCalculator{rate: 62.21},
+		Shadow:      &stubShadow{},
+		AdminTokens: map[string]string{"secret": "alice"},
+	})
+
+	rr := adminRequest(t, s, http.MethodGet, "/admin/shadow", "", "")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"candidate":"candidate.csv","since":"2024-01-01T00:00:00Z","requests":3,"errors":1,
+		"formulas":[]}`, rr.Body.String())
+
+	rr = adminRequest(t, s, http.MethodGet, "/admin/formula-sets/cdc/versions", "", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code, "formula set endpoints need an administrator")
+}
@@ -0,0 +1,45 @@
+package api
+
+import "net/http"
+
+const defaultToAge = 50
+
+// CalculateAgeCurveHandler returns the success rate for a range of ages with every other input fixed.
+// It takes the /calculate parameters plus from_age (defaults to age), to_age (defaults to 50) and step.
+func (s *Server) CalculateAgeCurveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	input, err := s.validateInput(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fromAge, err := optionalInt(params, "from_age", input.Age)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	toAge, err := optionalInt(params, "to_age", defaultToAge)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	step, err := optionalInt(params, "step", 1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	curve, err := s.IVFService.CalculateAgeCurve(input, fromAge, toAge, step)
+	if err != nil {
+		s.calculationError(w, r, err)
+		return
+	}
+	setLogFormula(r, curve.CDCFormula)
+
+	writeJSON(w, curve)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculateAgeCurveHandler(t *testing.T) {
+	s := newCalculatorServer()
+
+	t.Run("Defaults to the current age up to 50", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/calculate/age-curve?"+validQuery, nil)
+		rec := httptest.NewRecorder()
+
+		s.Handler().ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var curve ivf.AgeCurve
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &curve))
+		assert.Equal(t, "1-3", curve.CDCFormula)
+		require.Len(t, curve.Points, 19)
+		assert.Equal(t, ivf.AgePoint{Age: 32, SuccessRate: 62.21}, curve.Points[0])
+		assert.Equal(t, 50, curve.Points[18].Age)
+		for i := 1; i < len(curve.Points); i++ {
+			assert.Less(t, curve.Points[i].SuccessRate, curve.Points[i-1].SuccessRate)
+		}
+	})
+
+	t.Run("Uses the given range and step", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/calculate/age-curve?"+validQuery+"&from_age=30&to_age=40&step=5", nil)
+		rec := httptest.NewRecorder()
+
+		s.Handler().ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var curve ivf.AgeCurve
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &curve))
+		require.Len(t, curve.Points, 3)
+		assert.Equal(t, []int{30, 35, 40}, []int{curve.Points[0].Age, curve.Points[1].Age, curve.Points[2].Age})
+	})
+
+	for query, expected := range map[string]string{
+		"&to_age=51":   "to_age must be between 32 and 50. Got 51",
+		"&from_age=19": "from_age must be between 20 and 50. Got 19",
+		"&step=0":      "step must be at least 1. Got 0",
+		"&step=a":      "step must be an integer. Got a",
+	} {
+		t.Run("Rejects "+query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/calculate/age-curve?"+validQuery+query, nil)
+			rec := httptest.NewRecorder()
+
+			s.Handler().ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, expected+"\n", rec.Body.String())
+		})
+	}
+}
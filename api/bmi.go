@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"ivf_calculator/pkg/ivf"
+)
+
+// WhatIfBMIHandler predicts the change in success rate for a target weight or BMI and, with optimize=Yes,
+// the BMI that maximizes the prediction. It takes the /calculate parameters plus target_weight or target_bmi.
+func (s *Server) WhatIfBMIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	input, err := s.validateInput(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var target *ivf.BMITarget
+	targetWeight, err := optionalInt(params, "target_weight", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	targetBMI := 0.0
+	if str := params.Get("target_bmi"); str != "" {
+		if targetBMI, err = strconv.ParseFloat(str, 64); err != nil {
+			http.Error(w, fmt.Sprintf("target_bmi must be a number. Got %s", str), http.StatusBadRequest)
+			return
+		}
+	}
+	if targetWeight != 0 || targetBMI != 0 {
+		target = &ivf.BMITarget{Weight: targetWeight, BMI: targetBMI}
+	}
+
+	optimize := false
+	switch str := params.Get("optimize"); str {
+	case "", `No`:
+	case `Yes`:
+		optimize = true
+	default:
+		http.Error(w, fmt.Sprintf("optimize has invalid value %s", str), http.StatusBadRequest)
+		return
+	}
+	if target == nil && !optimize {
+		http.Error(w, "target_weight, target_bmi or optimize=Yes is required", http.StatusBadRequest)
+		return
+	}
+
+	whatIf, err := s.IVFService.WhatIfBMI(input, target, optimize)
+	if err != nil {
+		s.calculationError(w, r, err)
+		return
+	}
+	setLogFormula(r, whatIf.CDCFormula)
+
+	writeJSON(w, whatIf)
+}
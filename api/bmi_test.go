@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhatIfBMIHandler(t *testing.T) {
+	s := newCalculatorServer()
+
+	t.Run("Target weight and optimal BMI", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/whatif/bmi?"+validQuery+"&target_weight=200&optimize=Yes", nil)
+		rec := httptest.NewRecorder()
+
+		s.Handler().ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var whatIf ivf.BMIWhatIf
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &whatIf))
+		assert.Equal(t, "1-3", whatIf.CDCFormula)
+		assert.Equal(t, ivf.BMIOutcome{BMI: 22.8, Weight: 150, SuccessRate: 62.21}, whatIf.Current)
+
+		require.NotNil(t, whatIf.Target)
+		assert.Equal(t, 30.4, whatIf.Target.BMI)
+		assert.Equal(t, 200.0, whatIf.Target.Weight)
+		assert.Less(t, whatIf.Target.SuccessRate, 62.21)
+		assert.InDelta(t, whatIf.Target.SuccessRate-62.21, whatIf.Target.Change, 0.001)
+
+		// BMILinear + 2*BMIPower*bmi = 0 at 0.06997997 / (2 * 0.0015045) = 23.26
+		require.NotNil(t, whatIf.Optimal)
+		assert.Contains(t, []float64{23.2, 23.3}, whatIf.Optimal.BMI)
+		assert.GreaterOrEqual(t, whatIf.Optimal.SuccessRate, whatIf.Current.SuccessRate)
+		assert.InDelta(t, 153, whatIf.Optimal.Weight, 1)
+	})
+
+	t.Run("Target BMI", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/whatif/bmi?"+validQuery+"&target_bmi=25", nil)
+		rec := httptest.NewRecorder()
+
+		s.Handler().ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var whatIf ivf.BMIWhatIf
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &whatIf))
+		require.NotNil(t, whatIf.Target)
+		assert.Equal(t, 25.0, whatIf.Target.BMI)
+		assert.InDelta(t, 164.4, whatIf.Target.Weight, 0.1)
+		assert.Nil(t, whatIf.Optimal)
+	})
+
+	for query, expected := range map[string]string{
+		"":                                 "target_weight, target_bmi or optimize=Yes is required",
+		"&target_weight=150&target_bmi=25": "only one of target_weight or target_bmi can be set",
+		"&target_weight=350":               "target_weight must be between 80 and 300. Got 350",
+		"&target_bmi=80":                   "target_bmi must be between 12.2 and 45.6 at this height. Got 80",
+		"&target_bmi=NaN":                  "target_bmi must be between 12.2 and 45.6 at this height. Got NaN",
+		"&target_bmi=Inf":                  "target_bmi must be between 12.2 and 45.6 at this height. Got +Inf",
+		"&target_bmi=-Inf":                 "target_bmi must be between 12.2 and 45.6 at this height. Got -Inf",
+		"&optimize=maybe":                  "optimize has invalid value maybe",
+	} {
+		t.Run("Rejects "+query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/whatif/bmi?"+validQuery+query, nil)
+			rec := httptest.NewRecorder()
+
+			s.Handler().ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, expected+"\n", rec.Body.String())
+		})
+	}
+}
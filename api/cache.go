@@ -0,0 +1,55 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"ivf_calculator/internal/models"
+)
+
+// CalculationCache identifies the predictions that give the same result and reports the result cache use.
+type CalculationCache interface {
+	// CalculationKey is empty when the prediction can't be cached.
+	CalculationKey(model string, values url.Values) (string, error)
+	CacheStats() models.CacheStats
+}
+
+// cacheRoutes lists the cache metrics endpoint, registered when the server has a CalculationCache.
+func (s *Server) cacheRoutes() []route {
+	return []route{
+		{"GET /admin/cache", s.withAdminAuth(s.CacheStatsHandler)},
+	}
+}
+
+func (s *Server) CacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.Cache.CacheStats())
+}
+
+// calculationETag returns the ETag of the /calculate response, built on the calculation key. It is empty when the
+// response can't be cached, or differs every time because the calculation is recorded in the history.
+func (s *Server) calculationETag(params url.Values) string {
+	if s.Cache == nil || s.History != nil {
+		return ""
+	}
+	key, err := s.Cache.CalculationKey(params.Get("model"), params)
+	if err != nil || key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether the If-None-Match header lists the ETag, using the weak comparison. Only real ETags
+// match: * would answer 304 for any calculation the client never saw.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
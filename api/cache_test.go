@@ -0,0 +1,107 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/internal/repo"
+	"ivf_calculator/internal/server"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculateETag(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	calculator := server.NewSuccessCalculator(&server.Config{
+		Logger:    logger,
+		Repo:      repo.NewIVFFormula(&repo.Config{FilePath: "../internal/repo/data/ivf_success_formulas.csv"}),
+		CacheSize: 10,
+	})
+	s := New(&Config{Logger: logger, IVFService: calculator, Cache: calculator})
+	calculate := func(query string, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/calculate?"+query, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := calculate(validQuery, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+
+	rec = calculate(validQuery+"&utm_source=widget", etag)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, etag, rec.Header().Get("ETag"))
+	assert.Empty(t, rec.Body.String())
+
+	rec = calculate(validQuery, `"other", W/`+etag)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	rec = calculate(validQuery, "*")
+	assert.Equal(t, http.StatusOK, rec.Code, "* is not an ETag of the calculation")
+	assert.Equal(t, etag, rec.Header().Get("ETag"))
+
+	rec = calculate(validQuery+"&precision=exact", etag)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+
+	rec = calculate(validQuery+"&age=51", "")
+	assert.Equal(t, http.StatusOK, rec.Code, "the first age is calculated with")
+	rec = calculate("age=51", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, rec.Header().Get("ETag"), "errors have no ETag")
+	rec = calculate("age=51", "*")
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "invalid input is rejected whatever the ETag")
+
+	stats := calculator.CacheStats()
+	assert.Equal(t, 4, stats.Hits, "not modified responses are predicted from the cache")
+	assert.Equal(t, 4, stats.Misses)
+	assert.Equal(t, 2, stats.Size)
+}
+
+func TestCalculateETagWithHistory(t *testing.T) {
+	s := New(&Config{
+		Logger:     log.New(io.Discard, "", 0),
+		IVFService: &stubCalculator{rate: 62.21},
+		Cache:      &stubCache{},
+		History:    &stubHistory{},
+	})
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/calculate?"+validQuery, nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("ETag"), "every recorded calculation has its own ID")
+}
+
+// stubCache gives every calculation the same key.
+type stubCache struct{}
+
+func (c *stubCache) CalculationKey(model string, values url.Values) (string, error) {
+	return "key", nil
+}
+
+func (c *stubCache) CacheStats() models.CacheStats {
+	return models.CacheStats{}
+}
+
+// stubHistory records calculations without storing them.
+type stubHistory struct{}
+
+func (h *stubHistory) Record(model string, values url.Values, result *models.IVFResult) (*models.Calculation, error) {
+	return &models.Calculation{ID: "id"}, nil
+}
+
+func (h *stubHistory) Calculation(id string) (*models.Calculation, error) {
+	return nil, models.ErrNotFound
+}
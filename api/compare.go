@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+
+	"ivf_calculator/pkg/ivf"
+)
+
+// CompareScenariosHandler evaluates one patient profile under every egg source and prior IVF formula
+// and returns the results side by side, with the formula used for each.
+func (s *Server) CompareScenariosHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	input, err := s.validateInput(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	scenarios, err := s.IVFService.CompareScenarios(input)
+	if err != nil {
+		s.calculationError(w, r, err)
+		return
+	}
+	for _, scenario := range scenarios {
+		if scenario.MatchesInput {
+			setLogFormula(r, scenario.CDCFormula)
+		}
+	}
+
+	response := struct {
+		Scenarios []ivf.Scenario `json:"scenarios"`
+	}{
+		Scenarios: scenarios,
+	}
+
+	writeJSON(w, response)
+}
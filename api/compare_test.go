@@ -0,0 +1,38 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareScenariosHandler(t *testing.T) {
+	s := newCalculatorServer()
+	query := "age=32&weight=150&feet=5&inches=8&ivf_used=2&gravida=1&tubal_factor=Yes&male_factor_infertility=No" +
+		"&endometriosis=No&ovulatory_disorder=No&diminished_ovarian_reserve=Yes&uterine_factor=No&other_reason=No" +
+		"&unexplained_infertility=No&donotknow=No&eggSource=Own&previous_live_births=1"
+	req := httptest.NewRequest(http.MethodGet, "/compare?"+query, nil)
+	rec := httptest.NewRecorder()
+
+	s.Handler().ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response struct {
+		Scenarios []ivf.Scenario `json:"scenarios"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Scenarios, 3)
+
+	assert.Equal(t, ivf.Scenario{EggSource: "Own", AttemptedIVFPreviously: "No", CDCFormula: "1-3",
+		SuccessRate: response.Scenarios[0].SuccessRate}, response.Scenarios[0])
+	assert.Equal(t, ivf.Scenario{EggSource: "Own", AttemptedIVFPreviously: "Yes", CDCFormula: "7-8",
+		SuccessRate: 40.89, MatchesInput: true}, response.Scenarios[1])
+	assert.Equal(t, ivf.Scenario{EggSource: "Donor", AttemptedIVFPreviously: "N/A", CDCFormula: "11-13",
+		SuccessRate: 51.18}, response.Scenarios[2])
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+)
+
+const (
+	defaultCycles              = 3
+	maxCycles                  = 10
+	defaultMonthsBetweenCycles = 3
+)
+
+// CalculateCumulativeHandler projects the cumulative success rate over several IVF cycles.
+// It takes the /calculate parameters plus cycles and months_between_cycles.
+func (s *Server) CalculateCumulativeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	input, err := s.validateInput(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cycles, err := intParam(params, "cycles", defaultCycles, 1, maxCycles)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	monthsBetween, err := intParam(params, "months_between_cycles", defaultMonthsBetweenCycles, 0, 24)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	projection, err := s.IVFService.CalculateCumulative(input, cycles, monthsBetween)
+	if err != nil {
+		s.calculationError(w, r, err)
+		return
+	}
+	setLogFormula(r, projection.Cycles[0].CDCFormula)
+
+	writeJSON(w, projection)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculateCumulativeHandler(t *testing.T) {
+	s := newCalculatorServer()
+
+	t.Run("Switches to the previously attempted formula and ages the patient", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/calculate/cumulative?"+validQuery+"&cycles=3&months_between_cycles=6", nil)
+		rec := httptest.NewRecorder()
+
+		s.Handler().ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var projection ivf.Projection
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &projection))
+		require.Len(t, projection.Cycles, 3)
+
+		first := projection.Cycles[0]
+		assert.Equal(t, 62.21, first.SuccessRate)
+		assert.Equal(t, 62.21, first.CumulativeSuccessRate)
+		assert.Equal(t, "1-3", first.CDCFormula)
+		assert.Equal(t, []int{32, 32, 33}, []int{first.Age, projection.Cycles[1].Age, projection.Cycles[2].Age})
+		assert.Equal(t, "7-8", projection.Cycles[1].CDCFormula)
+		assert.Equal(t, "7-8", projection.Cycles[2].CDCFormula)
+
+		failure := 1.0
+		for _, c := range projection.Cycles {
+			failure *= 1 - c.SuccessRate/100
+		}
+		assert.InDelta(t, (1-failure)*100, projection.CumulativeSuccessRate, 0.005)
+		assert.Greater(t, projection.CumulativeSuccessRate, projection.Cycles[1].CumulativeSuccessRate)
+	})
+
+	t.Run("Rejects a timeline past the supported age", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/calculate/cumulative?"+strings.Replace(validQuery, "age=32", "age=45", 1)+
+			"&cycles=4&months_between_cycles=24", nil)
+		rec := httptest.NewRecorder()
+
+		s.Handler().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "the last cycle would start at age 51, the calculator supports ages up to 50")
+	})
+
+	t.Run("Rejects invalid cycles", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/calculate/cumulative?"+validQuery+"&cycles=0", nil)
+		rec := httptest.NewRecorder()
+
+		s.Handler().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "cycles must be between 1 and 10. Got 0\n", rec.Body.String())
+	})
+}
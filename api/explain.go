@@ -0,0 +1,28 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ExplainHandler returns the term breakdown of the /calculate prediction: the contribution of every formula term to
+// the score and the resulting success rate. It takes the /calculate parameters.
+func (s *Server) ExplainHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	result, err := s.IVFService.Predict(params.Get("model"), params)
+	if err != nil {
+		s.calculationError(w, r, err)
+		return
+	}
+	if result.Explanation == nil {
+		http.Error(w, fmt.Sprintf("model %s has no term breakdown", result.Model), http.StatusBadRequest)
+		return
+	}
+	setLogFormula(r, result.CDCFormula)
+
+	writeJSON(w, result.Explanation)
+}
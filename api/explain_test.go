@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ivf_calculator/internal/repo"
+	"ivf_calculator/internal/server"
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainHandler(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	formulas := repo.NewIVFFormula(&repo.Config{FilePath: "../internal/repo/data/ivf_success_formulas.csv", Logger: logger})
+	registry, err := ivf.NewRegistry(ivf.NewCDCModel(formulas), &fixedModel{})
+	require.NoError(t, err)
+	s := New(&Config{
+		Logger:     logger,
+		IVFService: server.NewSuccessCalculator(&server.Config{Logger: logger, Repo: formulas, Models: registry}),
+	})
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/calculate/explain?"+validQuery, nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var explanation ivf.Explanation
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &explanation))
+	assert.Equal(t, "1-3", explanation.CDCFormula)
+	assert.Equal(t, 62.21, explanation.SuccessRate)
+	assert.Contains(t, explanation.Terms, ivf.Term{Name: "endometriosis", Input: "Yes", Contribution: 0.02773216})
+
+	tests := []struct {
+		name  string
+		query string
+		error string
+	}{
+		{"invalid input", strings.Replace(validQuery, "age=32", "age=60", 1), "age must be between 20 and 50. Got 60"},
+		{"model without breakdown", "model=fixed", "model fixed has no term breakdown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/calculate/explain?"+tt.query, nil))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, tt.error, strings.TrimSpace(rec.Body.String()))
+		})
+	}
+}
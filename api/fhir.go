@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"ivf_calculator/internal/fhir"
+	"ivf_calculator/pkg/ivf"
+)
+
+// maxFHIRBody limits the size of the resources posted to the FHIR endpoint.
+const maxFHIRBody = 1 << 20
+
+// PredictFHIRHandler reads the calculator input from a FHIR QuestionnaireResponse or Bundle and returns the
+// prediction as a FHIR RiskAssessment. Errors are returned as an OperationOutcome.
+func (s *Server) PredictFHIRHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxFHIRBody))
+	if err != nil {
+		s.fhirError(w, r, &ivf.InputError{Message: "error reading the request body: " + err.Error()})
+		return
+	}
+	now := time.Now()
+	input, err := fhir.ReadInput(data, now)
+	if err != nil {
+		s.fhirError(w, r, err)
+		return
+	}
+	model := input.Values.Get("model")
+	result, err := s.IVFService.Predict(model, input.Values)
+	if err != nil {
+		s.fhirError(w, r, err)
+		return
+	}
+	setLogFormula(r, result.CDCFormula)
+	writeFHIR(w, http.StatusOK, fhir.NewRiskAssessment(result, input, now))
+}
+
+// fhirError reports invalid inputs as 400 and anything else as 500, both as an OperationOutcome.
+func (s *Server) fhirError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusBadRequest
+	var inputErr *ivf.InputError
+	if !errors.As(err, &inputErr) {
+		s.Logger.Printf("request_id=%s error calculating success rate: %v", RequestIDFromContext(r.Context()), err)
+		status = http.StatusInternalServerError
+	}
+	writeFHIR(w, status, fhir.NewOperationOutcome(err))
+}
+
+// writeFHIR writes the resource as FHIR JSON.
+func writeFHIR(w http.ResponseWriter, status int, resource interface{}) {
+	w.Header().Set("Content-Type", fhir.MimeType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resource)
+}
+
+// QuestionnaireHandler serves the intake form whose QuestionnaireResponses PredictFHIRHandler reads.
+func (s *Server) QuestionnaireHandler(w http.ResponseWriter, r *http.Request) {
+	writeFHIR(w, http.StatusOK, fhir.NewQuestionnaire())
+}
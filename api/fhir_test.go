@@ -0,0 +1,109 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"ivf_calculator/internal/fhir"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postFHIR(t *testing.T, s *Server, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/fhir/RiskAssessment/$predict", bytes.NewReader(body))
+	req.Header.Set("Content-Type", fhir.MimeType)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	assert.Equal(t, fhir.MimeType, rec.Header().Get("Content-Type"))
+	return rec
+}
+
+func TestPredictFHIRHandler(t *testing.T) {
+	s := newCalculatorServer()
+
+	tests := []struct {
+		fixture     string
+		subject     string
+		formula     string
+		probability float64
+	}{
+		{"bundle_metric.json", "Patient/patient-1", "1-3", 0.6221},
+		{"questionnaire_response.json", "Patient/patient-2", "11-13", 0.5118},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			data, err := os.ReadFile("../internal/fhir/testdata/" + tt.fixture)
+			require.NoError(t, err)
+			rec := postFHIR(t, s, data)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+			var ra fhir.RiskAssessment
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ra))
+			assert.Equal(t, "RiskAssessment", ra.ResourceType)
+			assert.Equal(t, tt.subject, ra.Subject.Reference)
+			assert.NotEmpty(t, ra.Basis)
+			require.Len(t, ra.Prediction, 1)
+			assert.Contains(t, ra.Method.Coding, fhir.Coding{System: fhir.FormulaCodeSystem, Code: tt.formula,
+				Display: "CDC formula " + tt.formula})
+			assert.Equal(t, tt.probability, ra.Prediction[0].ProbabilityDecimal)
+		})
+	}
+}
+
+func TestPredictFHIRHandlerErrors(t *testing.T) {
+	s := newCalculatorServer()
+	invalidUnit, err := os.ReadFile("../internal/fhir/testdata/bundle_invalid_unit.json")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		body       string
+		expression []string
+		diagnosis  string
+	}{
+		{"unsupported unit", string(invalidUnit), []string{"weight"}, "unsupported unit"},
+		{"missing input", `{"resourceType": "QuestionnaireResponse", "item": [{"linkId": "age", "answer": [{"valueInteger": 32}]}]}`,
+			nil, "is required"},
+		{"out of range", `{"resourceType": "QuestionnaireResponse", "item": [{"linkId": "age", "answer": [{"valueInteger": 51}]}]}`,
+			[]string{"age"}, "age"},
+		{"other resource", `{"resourceType": "Patient"}`, nil, "resourceType must be"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := postFHIR(t, s, []byte(tt.body))
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var outcome fhir.OperationOutcome
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &outcome))
+			require.Len(t, outcome.Issue, 1)
+			assert.Equal(t, "invalid", outcome.Issue[0].Code)
+			assert.Contains(t, outcome.Issue[0].Diagnostics, tt.diagnosis)
+			if tt.expression != nil {
+				assert.Equal(t, tt.expression, outcome.Issue[0].Expression)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fhir/RiskAssessment/$predict", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestQuestionnaireHandler(t *testing.T) {
+	s := newCalculatorServer()
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fhir/Questionnaire/ivf-success", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, fhir.MimeType, rec.Header().Get("Content-Type"))
+	var q fhir.Questionnaire
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &q))
+	assert.Equal(t, "Questionnaire", q.ResourceType)
+	assert.Equal(t, fhir.QuestionnaireURL, q.URL)
+	assert.NotEmpty(t, q.Item)
+}
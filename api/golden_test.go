@@ -0,0 +1,110 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/internal/repo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	goldenCasesPath = "testdata/golden_cases.json"
+	// referenceCasesPath are the patients formula uploads are dry-run against, shipped with the server.
+	referenceCasesPath = "../internal/repo/data/reference_cases.json"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the expected responses of "+goldenCasesPath)
+
+// goldenCase is a request and the response it must keep returning. Text responses are stored as JSON strings.
+type goldenCase struct {
+	Name    string          `json:"name"`
+	Request string          `json:"request"`
+	Status  int             `json:"status"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// TestGoldenCases runs the cases of testdata/golden_cases.json through the handler, the validation and the CSV
+// formulas, and reports any response that drifted. Run `go test ./api -run TestGoldenCases -update` to accept
+// intended changes, and review the diff of the data file.
+func TestGoldenCases(t *testing.T) {
+	cases := readGoldenCases(t)
+
+	s := newCalculatorServer()
+	for i, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.Request, nil))
+			body := goldenBody(t, rec)
+
+			if *updateGolden {
+				cases[i].Status = rec.Code
+				cases[i].Body = body
+				return
+			}
+			assert.Equal(t, tt.Status, rec.Code, "status of %s", tt.Request)
+			assert.JSONEq(t, string(tt.Body), string(body), "response of %s", tt.Request)
+		})
+	}
+
+	if *updateGolden {
+		var out bytes.Buffer
+		encoder := json.NewEncoder(&out)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		require.NoError(t, encoder.Encode(cases))
+		require.NoError(t, os.WriteFile(goldenCasesPath, out.Bytes(), 0o644))
+	}
+}
+
+// readGoldenCases reads testdata/golden_cases.json, the single source of the README patients.
+func readGoldenCases(t *testing.T) []goldenCase {
+	data, err := os.ReadFile(goldenCasesPath)
+	require.NoError(t, err)
+	var cases []goldenCase
+	require.NoError(t, json.Unmarshal(data, &cases))
+	require.NotEmpty(t, cases)
+	return cases
+}
+
+// TestReferenceCasesMatchGoldenCases checks that the reference cases are the successful /calculate golden cases, so
+// the dry run of formula uploads expects the same rates as the responses pinned here.
+func TestReferenceCasesMatchGoldenCases(t *testing.T) {
+	var expected []models.ReferenceCase
+	for _, c := range readGoldenCases(t) {
+		route, query, _ := strings.Cut(c.Request, "?")
+		if route != "/calculate" || c.Status != http.StatusOK {
+			continue
+		}
+		var body struct {
+			SuccessRate float64 `json:"success_rate"`
+		}
+		require.NoError(t, json.Unmarshal(c.Body, &body), c.Name)
+		expected = append(expected, models.ReferenceCase{Name: c.Name, Query: query, SuccessRate: body.SuccessRate})
+	}
+
+	referenceCases, err := repo.LoadReferenceCases(referenceCasesPath)
+	require.NoError(t, err)
+	assert.Equal(t, expected, referenceCases)
+}
+
+// goldenBody returns the JSON response, or the text response as a JSON string.
+func goldenBody(t *testing.T, rec *httptest.ResponseRecorder) json.RawMessage {
+	if strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+		var body bytes.Buffer
+		require.NoError(t, json.Indent(&body, bytes.TrimSpace(rec.Body.Bytes()), "      ", "  "))
+		return body.Bytes()
+	}
+	body, err := json.Marshal(strings.TrimSpace(rec.Body.String()))
+	require.NoError(t, err)
+	return body
+}
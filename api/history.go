@@ -0,0 +1,43 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"ivf_calculator/internal/models"
+)
+
+// CalculationHistory records the predictions returned by /calculate so they can be retrieved by ID.
+type CalculationHistory interface {
+	Record(model string, values url.Values, result *models.IVFResult) (*models.Calculation, error)
+	Calculation(id string) (*models.Calculation, error)
+}
+
+// historyRoutes lists the calculation history endpoints, registered when the server has a CalculationHistory. They
+// hold patient data and need a bearer token from HistoryTokens or AdminTokens.
+func (s *Server) historyRoutes() []route {
+	return []route{
+		{"GET /calculations/{id}", s.withHistoryAuth(s.GetCalculationHandler)},
+		{"GET /calculations/{id}/report", s.withHistoryAuth(s.CalculationReportHandler)},
+	}
+}
+
+// withHistoryAuth rejects requests without a bearer token from HistoryTokens or AdminTokens.
+func (s *Server) withHistoryAuth(next http.HandlerFunc) http.HandlerFunc {
+	return withBearerAuth("history", next, s.HistoryTokens, s.AdminTokens)
+}
+
+func (s *Server) GetCalculationHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := s.History.Calculation(r.PathValue("id"))
+	if errors.Is(err, models.ErrNotFound) {
+		http.Error(w, "Calculation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.Logger.Printf("request_id=%s error reading calculation: %v", RequestIDFromContext(r.Context()), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, c)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"ivf_calculator/internal/repo"
+	"ivf_calculator/internal/server"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculationHistory(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	history, err := repo.NewSQLiteHistory(&repo.HistoryConfig{Path: filepath.Join(t.TempDir(), "history.db")})
+	require.NoError(t, err)
+	defer history.Close()
+	calculator := server.NewSuccessCalculator(&server.Config{
+		Logger:  logger,
+		Repo:    repo.NewIVFFormula(&repo.Config{FilePath: "../internal/repo/data/ivf_success_formulas.csv"}),
+		History: history,
+	})
+	s := New(&Config{Logger: logger, IVFService: calculator, History: calculator,
+		HistoryTokens: map[string]string{"reader-token": "reader"}, AdminTokens: map[string]string{"secret": "alice"}})
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/calculate?"+validQuery, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response struct {
+		SuccessRate   float64 `json:"success_rate"`
+		CalculationID string  `json:"calculation_id"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.NotEmpty(t, response.CalculationID)
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, historyRequest("/calculations/"+response.CalculationID, "reader-token"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var calculation struct {
+		ID          string            `json:"id"`
+		Inputs      map[string]string `json:"inputs"`
+		SuccessRate float64           `json:"success_rate"`
+		Explanation struct {
+			Terms []json.RawMessage `json:"terms"`
+		} `json:"explanation"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &calculation))
+	assert.Equal(t, response.CalculationID, calculation.ID)
+	assert.Equal(t, response.SuccessRate, calculation.SuccessRate)
+	assert.Equal(t, "32", calculation.Inputs["age"])
+	assert.NotEmpty(t, calculation.Explanation.Terms)
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, historyRequest("/calculations/unknown", "reader-token"))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	t.Run("Bearer token", func(t *testing.T) {
+		for token, status := range map[string]int{
+			"":             http.StatusUnauthorized,
+			"other":        http.StatusUnauthorized,
+			"reader-token": http.StatusOK,
+			"secret":       http.StatusOK,
+		} {
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, historyRequest("/calculations/"+response.CalculationID, token))
+			assert.Equal(t, status, rec.Code, "token %q", token)
+			if status == http.StatusUnauthorized {
+				assert.Equal(t, `Bearer realm="history"`, rec.Header().Get("WWW-Authenticate"))
+			}
+		}
+	})
+}
+
+// historyRequest is a GET request of a history endpoint with the bearer token, if any.
+func historyRequest(target string, token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
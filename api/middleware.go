@@ -0,0 +1,218 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ivf_calculator/internal/utils"
+)
+
+// RequestIDHeader is the header used to accept and return the request ID.
+const RequestIDHeader = "X-Request-ID"
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	accessLogKey
+	adminActorKey
+)
+
+// accessLogEntry collects the fields handlers contribute to the access log line.
+// It must never hold patient inputs.
+type accessLogEntry struct {
+	formula string
+}
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// RequestIDFromContext returns the request ID assigned by the server middleware.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func withAdminActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, adminActorKey, actor)
+}
+
+// adminActor returns the admin authenticated by withAdminAuth.
+func adminActor(r *http.Request) string {
+	actor, _ := r.Context().Value(adminActorKey).(string)
+	return actor
+}
+
+// setLogFormula records the formula used for the request in its access log line.
+func setLogFormula(r *http.Request, formula string) {
+	if entry, ok := r.Context().Value(accessLogKey).(*accessLogEntry); ok {
+		entry.formula = formula
+	}
+}
+
+// withRequestLogging accepts the caller's X-Request-ID or generates a new one, puts it into the
+// request context and the response headers, and writes one access log line per request.
+// Only the path is logged since the query string carries patient inputs.
+func (s *Server) withRequestLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		entry := &accessLogEntry{}
+		ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+		ctx = context.WithValue(ctx, accessLogKey, entry)
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		formula := entry.formula
+		if formula == "" {
+			formula = "-"
+		}
+		s.Logger.Printf("access request_id=%s method=%s path=%q status=%d latency_ms=%.3f client=%s formula=%s",
+			requestID, r.Method, r.URL.Path, rec.status,
+			float64(time.Since(start).Microseconds())/1000, r.RemoteAddr, formula)
+	})
+}
+
+// validRequestID accepts caller supplied IDs that are safe to echo and log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// CORSConfig controls which browser origins may call the public endpoints.
+type CORSConfig struct {
+	// AllowedOrigins lists origins allowed to make cross-origin requests.
+	// A single "*" allows any origin. An empty list disables CORS.
+	AllowedOrigins []string
+	// AllowedHeaders lists request headers a preflight may ask for.
+	AllowedHeaders []string
+	// MaxAge is how long, in seconds, browsers may cache a preflight response.
+	MaxAge int
+}
+
+var defaultCORSHeaders = []string{"Accept", "Content-Type", RequestIDHeader}
+
+// originAllowed returns true if the given origin is in the allowed list.
+func (c *CORSConfig) originAllowed(origin string) bool {
+	return utils.Contains(c.AllowedOrigins, "*") || utils.Contains(c.AllowedOrigins, origin)
+}
+
+// allowedMethods returns the methods the routes registered on mux accept for the path of r, OPTIONS included.
+// Routes without a method in their pattern only answer GET.
+func allowedMethods(mux *http.ServeMux, methods []string, r *http.Request) []string {
+	allowed := []string{}
+	for _, method := range methods {
+		req := r.Clone(r.Context())
+		req.Method = method
+		_, pattern := mux.Handler(req)
+		if pattern == "" {
+			continue
+		}
+		if !strings.Contains(pattern, " ") {
+			method = http.MethodGet
+		}
+		if !utils.Contains(allowed, method) {
+			allowed = append(allowed, method)
+		}
+	}
+	return append(allowed, http.MethodOptions)
+}
+
+// routeMethods lists the methods used in the route patterns, GET included.
+func routeMethods(routes []route) []string {
+	methods := []string{http.MethodGet}
+	for _, rt := range routes {
+		if method, _, ok := strings.Cut(rt.pattern, " "); ok && !utils.Contains(methods, method) {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// withCORS adds CORS headers for allowed origins and answers preflight requests
+// before they reach the endpoint handlers. A preflight allows the methods registered on mux for the requested path.
+func (s *Server) withCORS(mux *http.ServeMux, methods []string) http.Handler {
+	headers := s.CORS.AllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || len(s.CORS.AllowedOrigins) == 0 {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if !s.CORS.originAllowed(origin) {
+			if preflight {
+				http.Error(w, "Origin not allowed", http.StatusForbidden)
+				return
+			}
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Expose-Headers", RequestIDHeader)
+		if !preflight {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(allowedMethods(mux, methods, r), ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+		if s.CORS.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(s.CORS.MaxAge))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubCalculator returns a fixed success rate for any input.
+type stubCalculator struct {
+	rate float64
+}
+
+func (c *stubCalculator) Predict(model string, values url.Values) (*models.IVFResult, error) {
+	if _, err := ivf.ParseInput(values); err != nil {
+		return nil, err
+	}
+	return &models.IVFResult{Model: ivf.CDCModelName, SuccessRate: c.rate, CDCFormula: "1-3"}, nil
+}
+
+func (c *stubCalculator) CalculateCumulative(params *models.IVFInput, cycles int, monthsBetween int) (*ivf.Projection, error) {
+	return &ivf.Projection{
+		CumulativeSuccessRate: c.rate,
+		Cycles:                []ivf.CycleProjection{{Cycle: 1, Age: params.Age, CDCFormula: "1-3", SuccessRate: c.rate}},
+	}, nil
+}
+
+func (c *stubCalculator) CalculateAgeCurve(params *models.IVFInput, fromAge int, toAge int, step int) (*ivf.AgeCurve, error) {
+	return &ivf.AgeCurve{CDCFormula: "1-3", Points: []ivf.AgePoint{{Age: fromAge, SuccessRate: c.rate}}}, nil
+}
+
+func (c *stubCalculator) WhatIfBMI(params *models.IVFInput, target *ivf.BMITarget, optimize bool) (*ivf.BMIWhatIf, error) {
+	return &ivf.BMIWhatIf{CDCFormula: "1-3", Current: ivf.BMIOutcome{SuccessRate: c.rate}}, nil
+}
+
+func (c *stubCalculator) ModelParams(model string) ([]ivf.Param, error) {
+	return ivf.Params(), nil
+}
+
+func (c *stubCalculator) CompareScenarios(params *models.IVFInput) ([]ivf.Scenario, error) {
+	return []ivf.Scenario{{EggSource: ivf.EggSourceOwn, AttemptedIVFPreviously: "No", CDCFormula: "1-3",
+		SuccessRate: c.rate, MatchesInput: true}}, nil
+}
+
+const validQuery = "age=32&weight=150&feet=5&inches=8&ivf_used=0&gravida=1&tubal_factor=No&male_factor_infertility=No" +
+	"&endometriosis=Yes&ovulatory_disorder=Yes&diminished_ovarian_reserve=No&uterine_factor=No&other_reason=No" +
+	"&unexplained_infertility=No&donotknow=No&eggSource=Own&previous_live_births=1"
+
+func newTestServer(cors CORSConfig) *Server {
+	return New(&Config{
+		Logger:     log.New(io.Discard, "", 0),
+		IVFService: &stubCalculator{rate: 62.21},
+		CORS:       cors,
+	})
+}
+
+func TestCORS(t *testing.T) {
+	s := newTestServer(CORSConfig{
+		AllowedOrigins: []string{"https://widget.example.com"},
+		AllowedHeaders: []string{"Content-Type", "X-Request-ID"},
+		MaxAge:         300,
+	})
+
+	tests := []struct {
+		name          string
+		method        string
+		origin        string
+		preflight     bool
+		expectedCode  int
+		expectedAllow string
+	}{
+		{
+			name:          "Allowed origin gets CORS headers",
+			method:        http.MethodGet,
+			origin:        "https://widget.example.com",
+			expectedCode:  http.StatusOK,
+			expectedAllow: "https://widget.example.com",
+		},
+		{
+			name:         "Unknown origin is served without CORS headers",
+			method:       http.MethodGet,
+			origin:       "https://evil.example.com",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Same-origin request is untouched",
+			method:       http.MethodGet,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:          "Preflight from allowed origin",
+			method:        http.MethodOptions,
+			origin:        "https://widget.example.com",
+			preflight:     true,
+			expectedCode:  http.StatusNoContent,
+			expectedAllow: "https://widget.example.com",
+		},
+		{
+			name:         "Preflight from unknown origin is rejected",
+			method:       http.MethodOptions,
+			origin:       "https://evil.example.com",
+			preflight:    true,
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/calculate?"+validQuery, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			}
+			rec := httptest.NewRecorder()
+
+			s.Handler().ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Equal(t, tt.expectedAllow, rec.Header().Get("Access-Control-Allow-Origin"))
+			if tt.preflight && tt.expectedCode == http.StatusNoContent {
+				assert.Equal(t, "Content-Type, X-Request-ID", rec.Header().Get("Access-Control-Allow-Headers"))
+				assert.Equal(t, "GET, OPTIONS", rec.Header().Get("Access-Control-Allow-Methods"))
+				assert.Equal(t, "300", rec.Header().Get("Access-Control-Max-Age"))
+			}
+		})
+	}
+}
+
+func TestCORSWildcard(t *testing.T) {
+	s := newTestServer(CORSConfig{AllowedOrigins: []string{"*"}})
+
+	req := httptest.NewRequest(http.MethodOptions, "/calculate", nil)
+	req.Header.Set("Origin", "https://any.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	rec := httptest.NewRecorder()
+
+	s.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://any.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Accept, Content-Type, X-Request-ID", rec.Header().Get("Access-Control-Allow-Headers"))
+}
+
+func TestRequestLogging(t *testing.T) {
+	var logs bytes.Buffer
+	s := newTestServer(CORSConfig{})
+	s.Logger = log.New(&logs, "", 0)
+
+	t.Run("Caller request ID is propagated", func(t *testing.T) {
+		logs.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/calculate?"+validQuery, nil)
+		req.Header.Set(RequestIDHeader, "abc-123")
+		rec := httptest.NewRecorder()
+
+		s.Handler().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "abc-123", rec.Header().Get(RequestIDHeader))
+		line := logs.String()
+		assert.Contains(t, line, "request_id=abc-123")
+		assert.Contains(t, line, `method=GET path="/calculate" status=200`)
+		assert.Contains(t, line, "formula=1-3")
+		assert.NotContains(t, line, "weight=150")
+	})
+
+	t.Run("Request ID is generated when missing or unsafe", func(t *testing.T) {
+		logs.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/calculate?"+validQuery, nil)
+		req.Header.Set(RequestIDHeader, "bad id\nforged=1")
+		rec := httptest.NewRecorder()
+
+		s.Handler().ServeHTTP(rec, req)
+
+		id := rec.Header().Get(RequestIDHeader)
+		assert.Len(t, id, 32)
+		assert.Contains(t, logs.String(), "request_id="+id)
+	})
+
+	t.Run("Failed requests are logged without a formula", func(t *testing.T) {
+		logs.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/calculate?age=12", nil)
+		rec := httptest.NewRecorder()
+
+		s.Handler().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, logs.String(), "status=400")
+		assert.Contains(t, logs.String(), "formula=-")
+		assert.NotContains(t, logs.String(), "age=12")
+	})
+}
+
+func TestRequestIDFromContext(t *testing.T) {
+	s := newTestServer(CORSConfig{})
+	var seen string
+	h := s.withRequestLogging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "from-caller")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "from-caller", seen)
+}
+
+func TestCORSMethods(t *testing.T) {
+	s := newTestServer(CORSConfig{AllowedOrigins: []string{"*"}})
+
+	tests := []struct {
+		path    string
+		method  string
+		allowed string
+	}{
+		{"/calculate", http.MethodGet, "GET, OPTIONS"},
+		{"/fhir/RiskAssessment/$predict", http.MethodPost, "POST, OPTIONS"},
+		{"/fhir/Questionnaire/ivf-success", http.MethodGet, "GET, OPTIONS"},
+		{"/unknown", http.MethodGet, "OPTIONS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, tt.path, nil)
+			req.Header.Set("Origin", "https://any.example.com")
+			req.Header.Set("Access-Control-Request-Method", tt.method)
+			rec := httptest.NewRecorder()
+
+			s.Handler().ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+			assert.Equal(t, tt.allowed, rec.Header().Get("Access-Control-Allow-Methods"))
+		})
+	}
+}
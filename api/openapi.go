@@ -0,0 +1,735 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"ivf_calculator/internal/fhir"
+	"ivf_calculator/pkg/ivf"
+)
+
+// OpenAPIHandler serves the OpenAPI 3 document describing the public endpoints.
+func (s *Server) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(openAPIDocument())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// openAPIDocument builds the OpenAPI 3 document from the route parameter descriptions.
+func openAPIDocument() map[string]interface{} {
+	document := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Sunfish IVF Success Calculator",
+			"description": "Calculates the chance of having a baby using In Vitro Fertilization, following the CDC IVF calculator.",
+			"version":     "1.0.0",
+		},
+		"paths": map[string]interface{}{
+			"/calculate": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "calculateSuccess",
+					"summary": "Calculate the IVF success rate for a patient. The parameters are those of the CDC " +
+						"model; other prediction models read their own inputs.",
+					"parameters": calculateParameters(
+						queryParameter("model", "Prediction model to calculate with.", false,
+							map[string]interface{}{"type": "string", "default": ivf.CDCModelName}),
+						map[string]interface{}{
+							"name":        "If-None-Match",
+							"in":          "header",
+							"description": "ETag of a previous response. Responses have an ETag when the result cache is enabled.",
+							"required":    false,
+							"schema":      map[string]interface{}{"type": "string"},
+						},
+					),
+					"responses": withResponse(calculateResponses("The predicted chance of a live birth.", "SuccessRate"),
+						"304", map[string]interface{}{"description": "The result matching If-None-Match didn't change."}),
+				},
+			},
+			"/calculate/cumulative": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "calculateCumulative",
+					"summary": "Project the cumulative chance of a live birth over several IVF cycles. Cycles after the first " +
+						"use the \"attempted IVF previously\" formula and the age at the start of the cycle.",
+					"parameters": calculateParameters(
+						intQueryParameter("cycles", "Number of planned cycles.", defaultCycles, 1, maxCycles),
+						intQueryParameter("months_between_cycles", "Months between the start of two cycles.",
+							defaultMonthsBetweenCycles, 0, 24),
+					),
+					"responses": calculateResponses("The per-cycle and cumulative chances of a live birth.", "Projection"),
+				},
+			},
+			"/calculate/age-curve": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "calculateAgeCurve",
+					"summary":     "Calculate the IVF success rate over a range of ages with every other input fixed.",
+					"parameters": calculateParameters(
+						queryParameter("from_age", "First age of the curve. Defaults to age.", false,
+							map[string]interface{}{"type": "integer", "minimum": 20, "maximum": 50}),
+						queryParameter("to_age", "Last age of the curve.", false,
+							map[string]interface{}{"type": "integer", "minimum": 20, "maximum": 50, "default": defaultToAge}),
+						queryParameter("step", "Years between two points of the curve.", false,
+							map[string]interface{}{"type": "integer", "minimum": 1, "default": 1}),
+					),
+					"responses": calculateResponses("The success rate at every age of the range.", "AgeCurve"),
+				},
+			},
+			"/calculate/explain": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "explainSuccess",
+					"summary": "Break the /calculate prediction down into the contribution of every formula term. Only " +
+						"available for the models that have a term breakdown, like the CDC model.",
+					"parameters": calculateParameters(
+						queryParameter("model", "Prediction model to calculate with.", false,
+							map[string]interface{}{"type": "string", "default": ivf.CDCModelName}),
+					),
+					"responses": calculateResponses("The formula terms and the success rate.", "Explanation"),
+				},
+			},
+			"/compare": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "compareScenarios",
+					"summary": "Evaluate the patient under every egg source and prior IVF formula that applies to the " +
+						"infertility reason, e.g. own vs donor eggs. eggSource and ivf_used select the scenario marked matches_input.",
+					"parameters": calculateParameters(),
+					"responses":  calculateResponses("The success rate and formula of every scenario.", "Comparison"),
+				},
+			},
+			"/whatif/bmi": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "whatIfBMI",
+					"summary": "Predict the change in success rate at a target weight or BMI and find the BMI that " +
+						"maximizes the prediction under the selected formula. One of target_weight, target_bmi or " +
+						"optimize=Yes is required.",
+					"parameters": calculateParameters(
+						queryParameter("target_weight", "Target weight in pounds.", false,
+							map[string]interface{}{"type": "integer", "minimum": 80, "maximum": 300}),
+						queryParameter("target_bmi", "Target BMI. Limited to the BMIs the weight range allows at the patient's height.",
+							false, map[string]interface{}{"type": "number"}),
+						queryParameter("optimize", "Find the BMI that maximizes the success rate.", false,
+							map[string]interface{}{"type": "string", "enum": []string{"Yes", "No"}, "default": "No"}),
+					),
+					"responses": calculateResponses("The success rate at the current, target and optimal BMI.", "BMIWhatIf"),
+				},
+			},
+			"/calculations/{id}": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "getCalculation",
+					"summary": "Return a calculation of the history as it was shown: the normalized inputs, the formula " +
+						"version, the term breakdown and the result. Enabled with the calculation history.",
+					"parameters": []interface{}{pathParameter("id", "calculation_id returned by /calculate.", "string")},
+					"security":   historySecurity(),
+					"responses": map[string]interface{}{
+						"200": jsonResponse("The calculation.", "#/components/schemas/Calculation"),
+						"401": textResponse("The bearer token is missing or unknown."),
+						"404": textResponse("No calculation has the ID, or it is older than the retention."),
+						"500": textResponse("The calculation history could not be read."),
+					},
+				},
+			},
+			"/calculations/{id}/report": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "getCalculationReport",
+					"summary": "Render the patient report of a saved calculation. The prediction is the saved one; the " +
+						"own vs donor eggs comparison and the multi-cycle projection are calculated from its inputs. " +
+						"Enabled with the calculation history.",
+					"parameters": []interface{}{
+						pathParameter("id", "calculation_id returned by /calculate.", "string"),
+						reportFormatParameter(),
+					},
+					"security": historySecurity(),
+					"responses": map[string]interface{}{
+						"200": reportResponse(),
+						"400": textResponse("The format is invalid."),
+						"401": textResponse("The bearer token is missing or unknown."),
+						"404": textResponse("No calculation has the ID, or it is older than the retention."),
+						"500": textResponse("The calculation history could not be read."),
+					},
+				},
+			},
+			"/report": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "getReport",
+					"summary": "Render the one-page patient report of the /calculate parameters: the inputs, the " +
+						"predicted chance, the own vs donor eggs comparison, the projection over " +
+						strconv.Itoa(defaultCycles) + " cycles and the CDC caveats.",
+					"parameters": calculateParameters(reportFormatParameter()),
+					"responses":  withResponse(calculateResponses("The report.", "SuccessRate"), "200", reportResponse()),
+				},
+			},
+			"/fhir/RiskAssessment/$predict": map[string]interface{}{
+				"post": map[string]interface{}{
+					"operationId": "predictFHIR",
+					"summary": "Predict the chance of a live birth from a FHIR R4 QuestionnaireResponse, whose linkIds are " +
+						"the /calculate parameters, or a Bundle of a Patient with Observation and Condition resources. " +
+						"Returns a RiskAssessment whose method references the CDC formula used.",
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content": map[string]interface{}{
+							fhir.MimeType: map[string]interface{}{
+								"schema": map[string]interface{}{"type": "object", "description": "QuestionnaireResponse or Bundle."},
+							},
+						},
+					},
+					"responses": map[string]interface{}{
+						"200": fhirResponse("The RiskAssessment."),
+						"400": fhirResponse("OperationOutcome locating the input that is missing or invalid."),
+						"500": fhirResponse("OperationOutcome of the error calculating the prediction."),
+					},
+				},
+			},
+			"/fhir/Questionnaire/" + fhir.QuestionnaireID: map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "getFHIRQuestionnaire",
+					"summary": "The FHIR R4 Questionnaire of the intake form, derived from the input validation rules. " +
+						"Its QuestionnaireResponses can be posted to /fhir/RiskAssessment/$predict.",
+					"responses": map[string]interface{}{
+						"200": fhirResponse("The Questionnaire."),
+					},
+				},
+			},
+			"/ui": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "calculatorUI",
+					"summary": "The calculator as an HTML form for staff. Takes the /calculate parameters, which the form " +
+						"submits, and shows the result with its term breakdown or the invalid input next to its field.",
+					"parameters": calculateParameters(),
+					"responses": map[string]interface{}{
+						"200": htmlResponse("The form, with the result when the parameters are valid."),
+						"400": htmlResponse("The form with the invalid input."),
+						"500": htmlResponse("The form with a calculation error."),
+					},
+				},
+			},
+			"/openapi.json": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "getOpenAPI",
+					"summary":     "This document.",
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{
+							"description": "The OpenAPI document.",
+							"content": map[string]interface{}{
+								"application/json": map[string]interface{}{
+									"schema": map[string]interface{}{"type": "object"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"SuccessRate": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"model", "success_rate"},
+					"properties": map[string]interface{}{
+						"model": map[string]interface{}{
+							"type":        "string",
+							"description": "Prediction model the success rate was calculated with.",
+						},
+						"success_rate": rateSchema("Chance of having a baby, in percents. Rounded to 2 decimals unless the " +
+							"precision is exact."),
+						"precision": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{string(ivf.PrecisionCDC), string(ivf.PrecisionExact)},
+							"description": "Rounding the success rate was calculated with. Left out by models without precision modes.",
+						},
+						"confidence_interval": map[string]interface{}{
+							"$ref": "#/components/schemas/Interval",
+						},
+						"calculation_id": map[string]interface{}{
+							"type":        "string",
+							"description": "ID of the calculation in the history, set when the history is enabled.",
+						},
+					},
+				},
+				"Calculation": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"id", "created_at", "model", "inputs", "success_rate"},
+					"properties": map[string]interface{}{
+						"id":         map[string]interface{}{"type": "string"},
+						"created_at": map[string]interface{}{"type": "string", "format": "date-time"},
+						"model":      map[string]interface{}{"type": "string"},
+						"inputs": map[string]interface{}{
+							"type":                 "object",
+							"description":          "The inputs the model read, by query parameter name.",
+							"additionalProperties": map[string]interface{}{"type": "string"},
+						},
+						"formula_version": map[string]interface{}{
+							"type":        "string",
+							"description": "Version of the formulas, e.g. cdc@3 or the formula file and its hash.",
+						},
+						"cdc_formula":  map[string]interface{}{"type": "string"},
+						"success_rate": rateSchema("Chance of having a baby, in percents."),
+						"precision": map[string]interface{}{
+							"type": "string",
+							"enum": []string{string(ivf.PrecisionCDC), string(ivf.PrecisionExact)},
+						},
+						"confidence_interval": map[string]interface{}{"$ref": "#/components/schemas/Interval"},
+						"explanation": map[string]interface{}{
+							"type":        "object",
+							"description": "The formula terms and their contributions to the score.",
+						},
+					},
+				},
+				"Explanation": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"cdc_formula", "precision", "bmi", "terms", "score", "success_rate"},
+					"properties": map[string]interface{}{
+						"cdc_formula": map[string]interface{}{"type": "string"},
+						"precision": map[string]interface{}{
+							"type": "string",
+							"enum": []string{string(ivf.PrecisionCDC), string(ivf.PrecisionExact)},
+						},
+						"bmi": map[string]interface{}{"type": "number"},
+						"terms": map[string]interface{}{
+							"type": "array",
+							"items": map[string]interface{}{
+								"type":     "object",
+								"required": []string{"name", "contribution"},
+								"properties": map[string]interface{}{
+									"name": map[string]interface{}{"type": "string"},
+									"input": map[string]interface{}{
+										"type":        "string",
+										"description": "Patient value the term was evaluated with, absent for the intercept.",
+									},
+									"contribution": map[string]interface{}{"type": "number"},
+								},
+							},
+						},
+						"score": map[string]interface{}{
+							"type":        "number",
+							"description": "Sum of the term contributions, the log-odds of success.",
+						},
+						"success_rate":        rateSchema("Chance of having a baby, in percents."),
+						"confidence_interval": map[string]interface{}{"$ref": "#/components/schemas/Interval"},
+					},
+				},
+				"Interval": objectSchema(map[string]interface{}{
+					"lower": rateSchema("Lower bound of the success rate, in percents."),
+					"upper": rateSchema("Upper bound of the success rate, in percents."),
+					"level": map[string]interface{}{"type": "number", "description": "Confidence level, e.g. 0.95."},
+				}),
+				"Projection": objectSchema(map[string]interface{}{
+					"cumulative_success_rate": rateSchema("Chance of having a baby by the end of the last cycle, in percents."),
+					"cycles": map[string]interface{}{
+						"type":  "array",
+						"items": map[string]interface{}{"$ref": "#/components/schemas/CycleProjection"},
+					},
+				}),
+				"AgeCurve": objectSchema(map[string]interface{}{
+					"cdc_formula": map[string]interface{}{"type": "string"},
+					"points": map[string]interface{}{
+						"type": "array",
+						"items": objectSchema(map[string]interface{}{
+							"age":          map[string]interface{}{"type": "integer"},
+							"success_rate": rateSchema("Chance of having a baby at this age, in percents."),
+						}),
+					},
+				}),
+				"BMIWhatIf": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"cdc_formula", "current"},
+					"properties": map[string]interface{}{
+						"cdc_formula": map[string]interface{}{"type": "string"},
+						"current":     map[string]interface{}{"$ref": "#/components/schemas/BMIOutcome"},
+						"target":      map[string]interface{}{"$ref": "#/components/schemas/BMIOutcome"},
+						"optimal":     map[string]interface{}{"$ref": "#/components/schemas/BMIOutcome"},
+					},
+				},
+				"BMIOutcome": objectSchema(map[string]interface{}{
+					"bmi":          map[string]interface{}{"type": "number"},
+					"weight":       map[string]interface{}{"type": "number", "description": "Weight in pounds giving this BMI."},
+					"success_rate": rateSchema("Chance of having a baby at this BMI, in percents."),
+					"change": map[string]interface{}{
+						"type":        "number",
+						"description": "Difference with the current success rate, in percentage points.",
+					},
+				}),
+				"Comparison": objectSchema(map[string]interface{}{
+					"scenarios": map[string]interface{}{
+						"type":  "array",
+						"items": map[string]interface{}{"$ref": "#/components/schemas/Scenario"},
+					},
+				}),
+				"Scenario": objectSchema(map[string]interface{}{
+					"egg_source":               map[string]interface{}{"type": "string", "enum": []string{"Own", "Donor"}},
+					"attempted_ivf_previously": map[string]interface{}{"type": "string", "enum": []string{"Yes", "No", "N/A"}},
+					"cdc_formula":              map[string]interface{}{"type": "string"},
+					"success_rate":             rateSchema("Chance of having a baby in this scenario, in percents."),
+					"matches_input":            map[string]interface{}{"type": "boolean"},
+				}),
+				"CycleProjection": objectSchema(map[string]interface{}{
+					"cycle":                   map[string]interface{}{"type": "integer", "minimum": 1},
+					"age":                     map[string]interface{}{"type": "integer"},
+					"cdc_formula":             map[string]interface{}{"type": "string"},
+					"success_rate":            rateSchema("Chance of success of this cycle alone, in percents."),
+					"cumulative_success_rate": rateSchema("Chance of success by the end of this cycle, in percents."),
+				}),
+			},
+		},
+	}
+	addAdminPaths(document)
+	return document
+}
+
+// addAdminPaths describes the /admin endpoints, which require a bearer token.
+func addAdminPaths(document map[string]interface{}) {
+	paths := document["paths"].(map[string]interface{})
+	components := document["components"].(map[string]interface{})
+	schemas := components["schemas"].(map[string]interface{})
+	components["securitySchemes"] = map[string]interface{}{
+		"adminToken": map[string]interface{}{"type": "http", "scheme": "bearer"},
+		"historyToken": map[string]interface{}{"type": "http", "scheme": "bearer",
+			"description": "Read-only token of the calculation history."},
+	}
+
+	set := pathParameter("set", "Name of the formula set, e.g. cdc.", "string")
+	upload := map[string]interface{}{
+		"required": true,
+		"content": map[string]interface{}{
+			"text/csv":         map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			"application/json": map[string]interface{}{"schema": map[string]interface{}{"type": "array"}},
+		},
+	}
+	format := queryParameter("format", "Format of the formula file. Defaults to the Content-Type.", false,
+		map[string]interface{}{"type": "string", "enum": []string{"csv", "json"}})
+
+	paths["/admin/formula-sets/{set}/versions"] = map[string]interface{}{
+		"get": adminOperation("listFormulaVersions", "List the versions of the formula set.",
+			[]interface{}{set}, nil, adminResponses("200", "The versions, oldest first.", "FormulaVersions")),
+		"post": adminOperation("uploadFormulas", "Validate a formula file and, when it is valid, store it as a new "+
+			"inactive version of the formula set.",
+			[]interface{}{set, format, queryParameter("source", "Where the formulas come from.", false,
+				map[string]interface{}{"type": "string"})}, upload,
+			withResponse(adminResponses("201", "The validation report of the stored version.", "ValidationReport"),
+				"422", jsonResponse("The upload is invalid and was not stored.", "#/components/schemas/ValidationReport"))),
+	}
+	paths["/admin/formula-sets/{set}/formulas"] = map[string]interface{}{
+		"get": adminOperation("listFormulas", "Return the formula definitions of a version of the formula set.",
+			[]interface{}{set, queryParameter("version", "Version to return. Defaults to the active version.", false,
+				map[string]interface{}{"type": "integer", "minimum": 1})},
+			nil, adminResponses("200", "The formula definitions.", "FormulaDefinitions")),
+	}
+	paths["/admin/formula-sets/{set}/validate"] = map[string]interface{}{
+		"post": adminOperation("validateFormulas", "Check a formula file against the active version and dry-run it "+
+			"on the reference cases without storing it.", []interface{}{set, format}, upload,
+			adminResponses("200", "The validation report.", "ValidationReport")),
+	}
+	paths["/admin/formula-sets/{set}/versions/{version}/activate"] = map[string]interface{}{
+		"post": adminOperation("activateFormulas", "Make the version the active version of the formula set.",
+			[]interface{}{set, pathParameter("version", "Version to activate.", "integer")}, nil,
+			adminResponses("200", "The active version.", "ActiveVersion")),
+	}
+	paths["/admin/formula-sets/{set}/rollback"] = map[string]interface{}{
+		"post": adminOperation("rollbackFormulas", "Activate the newest version older than the active one.",
+			[]interface{}{set}, nil, adminResponses("200", "The active version.", "ActiveVersion")),
+	}
+	paths["/admin/shadow"] = map[string]interface{}{
+		"get": adminOperation("shadowReport", "Summarize how the success rates of the shadow candidate formula set "+
+			"differ from the active ones, per active formula.", []interface{}{}, nil,
+			adminResponses("200", "The distribution of the differences since the server started.", "ShadowReport")),
+	}
+	paths["/admin/cache"] = map[string]interface{}{
+		"get": adminOperation("cacheStats", "Report the use of the /calculate result cache.", []interface{}{}, nil,
+			adminResponses("200", "The size and hit rate of the cache since the server started.", "CacheStats")),
+	}
+	paths["/admin/audit"] = map[string]interface{}{
+		"get": adminOperation("auditLog", "Return the latest admin actions, newest first.",
+			[]interface{}{intQueryParameter("limit", "Number of entries to return.", defaultAuditLimit, 1, maxAuditLimit)},
+			nil, adminResponses("200", "The audit entries.", "AuditLog")),
+	}
+
+	schemas["FormulaVersions"] = objectSchema(map[string]interface{}{
+		"versions": map[string]interface{}{
+			"type": "array",
+			"items": objectSchema(map[string]interface{}{
+				"version":    map[string]interface{}{"type": "integer"},
+				"source":     map[string]interface{}{"type": "string"},
+				"created_at": map[string]interface{}{"type": "string", "format": "date-time"},
+				"formulas":   map[string]interface{}{"type": "integer"},
+				"active":     map[string]interface{}{"type": "boolean"},
+			}),
+		},
+	})
+	schemas["FormulaDefinitions"] = objectSchema(map[string]interface{}{
+		"formulas": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object"}},
+	})
+	schemas["ActiveVersion"] = objectSchema(map[string]interface{}{
+		"formula_set":    map[string]interface{}{"type": "string"},
+		"active_version": map[string]interface{}{"type": "integer"},
+	})
+	schemas["ValidationReport"] = map[string]interface{}{
+		"type":     "object",
+		"required": []string{"valid", "formulas", "errors", "warnings", "reference_cases"},
+		"properties": map[string]interface{}{
+			"valid":    map[string]interface{}{"type": "boolean"},
+			"formulas": map[string]interface{}{"type": "integer"},
+			"errors":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"warnings": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"reference_cases": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "object"},
+			},
+			"version": map[string]interface{}{"type": "integer", "description": "Version the upload was stored as."},
+		},
+	}
+	schemas["ShadowReport"] = objectSchema(map[string]interface{}{
+		"candidate": map[string]interface{}{"type": "string", "description": "The candidate formula set."},
+		"since":     map[string]interface{}{"type": "string", "format": "date-time"},
+		"requests":  map[string]interface{}{"type": "integer"},
+		"errors": map[string]interface{}{
+			"type":        "integer",
+			"description": "Requests the candidate couldn't calculate.",
+		},
+		"formulas": map[string]interface{}{
+			"type": "array",
+			"items": objectSchema(map[string]interface{}{
+				"cdc_formula":              map[string]interface{}{"type": "string", "description": "The active formula."},
+				"requests":                 map[string]interface{}{"type": "integer"},
+				"mean_difference":          differenceSchema("Mean of the candidate minus the active success rate."),
+				"mean_absolute_difference": differenceSchema("Mean of the absolute differences."),
+				"stddev_difference":        differenceSchema("Standard deviation of the differences."),
+				"min_difference":           differenceSchema("Smallest difference."),
+				"max_difference":           differenceSchema("Largest difference."),
+				"candidate_formulas": map[string]interface{}{
+					"type":                 "object",
+					"description":          "Number of requests per candidate formula.",
+					"additionalProperties": map[string]interface{}{"type": "integer"},
+				},
+				"histogram": map[string]interface{}{
+					"type": "array",
+					"items": objectSchema(map[string]interface{}{
+						"range": map[string]interface{}{"type": "string"},
+						"count": map[string]interface{}{"type": "integer"},
+					}),
+				},
+			}),
+		},
+	})
+	schemas["CacheStats"] = objectSchema(map[string]interface{}{
+		"size":      map[string]interface{}{"type": "integer"},
+		"capacity":  map[string]interface{}{"type": "integer"},
+		"hits":      map[string]interface{}{"type": "integer"},
+		"misses":    map[string]interface{}{"type": "integer"},
+		"hit_rate":  map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1},
+		"evictions": map[string]interface{}{"type": "integer"},
+		"invalidations": map[string]interface{}{
+			"type":        "integer",
+			"description": "Times the cache was emptied because the formulas changed.",
+		},
+	})
+	schemas["AuditLog"] = objectSchema(map[string]interface{}{
+		"entries": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id":          map[string]interface{}{"type": "integer"},
+					"at":          map[string]interface{}{"type": "string", "format": "date-time"},
+					"actor":       map[string]interface{}{"type": "string"},
+					"action":      map[string]interface{}{"type": "string"},
+					"formula_set": map[string]interface{}{"type": "string"},
+					"version":     map[string]interface{}{"type": "integer"},
+					"details":     map[string]interface{}{"type": "string"},
+				},
+			},
+		},
+	})
+}
+
+// historySecurity accepts a history or an admin token.
+func historySecurity() []interface{} {
+	return []interface{}{
+		map[string]interface{}{"historyToken": []string{}},
+		map[string]interface{}{"adminToken": []string{}},
+	}
+}
+
+func adminOperation(id string, summary string, parameters []interface{}, body map[string]interface{},
+	responses map[string]interface{}) map[string]interface{} {
+	operation := map[string]interface{}{
+		"operationId": id,
+		"summary":     summary,
+		"parameters":  parameters,
+		"security":    []interface{}{map[string]interface{}{"adminToken": []string{}}},
+		"responses":   responses,
+	}
+	if body != nil {
+		operation["requestBody"] = body
+	}
+	return operation
+}
+
+// adminResponses describes the responses shared by the admin endpoints.
+func adminResponses(status string, description string, schema string) map[string]interface{} {
+	return map[string]interface{}{
+		status: jsonResponse(description, "#/components/schemas/"+schema),
+		"400":  textResponse("A parameter or the formula file can't be read."),
+		"401":  textResponse("The bearer token is missing or unknown."),
+		"404":  textResponse("The formula set or version doesn't exist."),
+		"409":  textResponse("The action doesn't apply to the formula set, e.g. there is no version to roll back to."),
+		"500":  textResponse("The formula database could not be read or written."),
+	}
+}
+
+func differenceSchema(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "number", "description": description + " In percentage points."}
+}
+
+func withResponse(responses map[string]interface{}, status string, response map[string]interface{}) map[string]interface{} {
+	responses[status] = response
+	return responses
+}
+
+func pathParameter(name string, description string, schemaType string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "path",
+		"description": description,
+		"required":    true,
+		"schema":      map[string]interface{}{"type": schemaType},
+	}
+}
+
+// calculateParameters returns the /calculate query parameters followed by the given extra parameters.
+func calculateParameters(extra ...map[string]interface{}) []interface{} {
+	calculateParams := ivf.Params()
+	parameters := make([]interface{}, 0, len(calculateParams)+len(extra))
+	for _, p := range calculateParams {
+		schema := map[string]interface{}{"type": p.Type}
+		if len(p.Enum) > 0 {
+			schema["enum"] = p.Enum
+		}
+		if p.Minimum != nil {
+			schema["minimum"] = *p.Minimum
+		}
+		if p.Maximum != nil {
+			schema["maximum"] = *p.Maximum
+		}
+		parameters = append(parameters, queryParameter(p.Name, p.Description, p.Required, schema))
+	}
+	for _, p := range extra {
+		parameters = append(parameters, p)
+	}
+	return parameters
+}
+
+func queryParameter(name string, description string, required bool, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"required":    required,
+		"schema":      schema,
+	}
+}
+
+func intQueryParameter(name string, description string, def int, min int, max int) map[string]interface{} {
+	return queryParameter(name, description, false, map[string]interface{}{
+		"type":    "integer",
+		"default": def,
+		"minimum": min,
+		"maximum": max,
+	})
+}
+
+// calculateResponses describes the responses shared by the calculation endpoints.
+func calculateResponses(description string, schema string) map[string]interface{} {
+	return map[string]interface{}{
+		"200": jsonResponse(description, "#/components/schemas/"+schema),
+		"400": textResponse("An input is missing or invalid."),
+		"405": textResponse("Method not allowed."),
+		"500": textResponse("No formula matches the inputs or the formula could not be loaded."),
+	}
+}
+
+func objectSchema(properties map[string]interface{}) map[string]interface{} {
+	required := make([]string, 0, len(properties))
+	for name := range properties {
+		required = append(required, name)
+	}
+	sort.Strings(required)
+	return map[string]interface{}{
+		"type":       "object",
+		"required":   required,
+		"properties": properties,
+	}
+}
+
+func rateSchema(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "number",
+		"description": description,
+		"minimum":     0,
+		"maximum":     100,
+	}
+}
+
+func jsonResponse(description string, ref string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{"$ref": ref},
+			},
+		},
+	}
+}
+
+func textResponse(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"text/plain": map[string]interface{}{
+				"schema": map[string]interface{}{"type": "string"},
+			},
+		},
+	}
+}
+
+func fhirResponse(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			fhir.MimeType: map[string]interface{}{
+				"schema": map[string]interface{}{"type": "object"},
+			},
+		},
+	}
+}
+
+func htmlResponse(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"text/html": map[string]interface{}{
+				"schema": map[string]interface{}{"type": "string"},
+			},
+		},
+	}
+}
+
+func reportFormatParameter() map[string]interface{} {
+	return queryParameter("format", "Format of the report.", false,
+		map[string]interface{}{"type": "string", "enum": []string{"html", "pdf"}, "default": "html"})
+}
+
+func reportResponse() map[string]interface{} {
+	return map[string]interface{}{
+		"description": "The report as a printable HTML page or a PDF.",
+		"content": map[string]interface{}{
+			"text/html":       map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			"application/pdf": map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}},
+		},
+	}
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPIHandler(t *testing.T) {
+	s := newTestServer(CORSConfig{})
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	rec := httptest.NewRecorder()
+
+	s.Handler().ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var doc struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+
+	// the optional routes are only registered when the server is configured with them
+	routes := s.routes()
+	routes = append(routes, s.adminRoutes()...)
+	routes = append(routes, s.shadowRoutes()...)
+	routes = append(routes, s.historyRoutes()...)
+	routes = append(routes, s.cacheRoutes()...)
+	for _, rt := range routes {
+		path := rt.pattern
+		if i := strings.Index(path, " "); i >= 0 {
+			path = path[i+1:]
+		}
+		assert.Contains(t, doc.Paths, path, "route %s is not described in the OpenAPI document", rt.pattern)
+	}
+}
+
+// readValues records the names of the values read.
+type readValues struct {
+	url.Values
+	read map[string]bool
+}
+
+func (v *readValues) Get(key string) string {
+	v.read[key] = true
+	return v.Values.Get(key)
+}
+
+// TestCalculateParamsMatchValidateInput fails when validateInput and the OpenAPI parameters drift apart.
+func TestCalculateParamsMatchValidateInput(t *testing.T) {
+	s := newTestServer(CORSConfig{})
+	base, err := url.ParseQuery(validQuery + "&precision=cdc")
+	require.NoError(t, err)
+	_, err = s.validateInput(base)
+	require.NoError(t, err)
+
+	// every parameter validateInput reads must be documented, not only the documented ones read
+	read := &readValues{Values: base, read: map[string]bool{}}
+	_, err = s.validateInput(read)
+	require.NoError(t, err)
+	documented := map[string]bool{}
+	for _, p := range ivf.Params() {
+		documented[p.Name] = true
+	}
+	require.NotEmpty(t, read.read)
+	for name := range read.read {
+		assert.True(t, documented[name], "validateInput reads %s, which is not documented", name)
+	}
+
+	with := func(name, value string) url.Values {
+		params := url.Values{}
+		for k, v := range base {
+			params[k] = v
+		}
+		params.Set(name, value)
+		return params
+	}
+
+	for _, p := range ivf.Params() {
+		t.Run(p.Name, func(t *testing.T) {
+			require.Contains(t, base, p.Name, "the base query must set every documented parameter")
+
+			without := with(p.Name, "")
+			without.Del(p.Name)
+			_, err := s.validateInput(without)
+			if p.Required {
+				assert.Error(t, err, "%s is documented as required", p.Name)
+			} else {
+				assert.NoError(t, err, "%s is documented as optional", p.Name)
+			}
+
+			for _, value := range p.Enum {
+				_, err := s.validateInput(with(p.Name, value))
+				if err != nil {
+					assert.NotContains(t, err.Error(), p.Name+" has invalid value", "%s=%s is documented as valid", p.Name, value)
+				}
+			}
+			if len(p.Enum) > 0 {
+				_, err := s.validateInput(with(p.Name, "Maybe"))
+				assert.Error(t, err, "%s accepts a value outside of its enum", p.Name)
+			}
+
+			if p.Type == "integer" {
+				_, err := s.validateInput(with(p.Name, "abc"))
+				assert.Error(t, err, "%s accepts a non-integer value", p.Name)
+			}
+			if p.Minimum != nil {
+				_, err := s.validateInput(with(p.Name, strconv.Itoa(*p.Minimum)))
+				assert.NoError(t, err)
+				_, err = s.validateInput(with(p.Name, strconv.Itoa(*p.Minimum-1)))
+				assert.Error(t, err, "%s accepts a value below its minimum", p.Name)
+			}
+			if p.Maximum != nil {
+				_, err := s.validateInput(with(p.Name, strconv.Itoa(*p.Maximum)))
+				assert.NoError(t, err)
+				_, err = s.validateInput(with(p.Name, strconv.Itoa(*p.Maximum+1)))
+				assert.Error(t, err, "%s accepts a value above its maximum", p.Name)
+			}
+		})
+	}
+}
+
+// TestInputRulesMatchValidateInput fails when validateInput and the rules between inputs published in the FHIR
+// Questionnaire drift apart.
+func TestInputRulesMatchValidateInput(t *testing.T) {
+	s := newTestServer(CORSConfig{})
+	base, err := url.ParseQuery(validQuery)
+	require.NoError(t, err)
+
+	for _, c := range ivf.Choices() {
+		var names []string
+		for _, option := range c.Options {
+			names = append(names, option...)
+		}
+		selecting := func(options ...int) url.Values {
+			params := url.Values{}
+			for k, v := range base {
+				params[k] = v
+			}
+			for _, name := range names {
+				params.Set(name, "No")
+			}
+			for _, i := range options {
+				params.Set(c.Options[i][0], "Yes")
+			}
+			return params
+		}
+
+		_, err := s.validateInput(selecting())
+		assert.Error(t, err, "%s accepts no option", c.Name)
+		for i := range c.Options {
+			_, err := s.validateInput(selecting(i))
+			assert.NoError(t, err, "%s rejects option %v", c.Name, c.Options[i])
+			if i > 0 {
+				_, err := s.validateInput(selecting(0, i))
+				assert.Error(t, err, "%s accepts two options", c.Name)
+			}
+		}
+	}
+
+	donor, _ := url.ParseQuery(base.Encode())
+	donor.Set("eggSource", ivf.EggSourceDonor)
+	donor.Del("ivf_used")
+	_, err = s.validateInput(donor)
+	assert.EqualError(t, err, "ivf_used is required", "ivf_used is required with donor eggs too")
+
+	for _, p := range ivf.Params() {
+		if p.AtMost != "" {
+			params, _ := url.ParseQuery(base.Encode())
+			params.Set(p.AtMost, p.Enum[0])
+			params.Set(p.Name, p.Enum[len(p.Enum)-1])
+			_, err := s.validateInput(params)
+			assert.Error(t, err, "%s can be greater than %s", p.Name, p.AtMost)
+		}
+	}
+}
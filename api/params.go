@@ -0,0 +1,31 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"ivf_calculator/pkg/ivf"
+)
+
+// intParam parses an optional integer query parameter, returning def when it is missing.
+func intParam(params url.Values, name string, def int, min int, max int) (int, error) {
+	v, err := optionalInt(params, name, def)
+	if err != nil || v < min || v > max {
+		return 0, &ivf.InputError{Param: name, Message: fmt.Sprintf("%s must be between %d and %d. Got %s", name, min, max, params.Get(name))}
+	}
+	return v, nil
+}
+
+// optionalInt parses an optional integer query parameter, returning def when it is missing.
+func optionalInt(params url.Values, name string, def int) (int, error) {
+	str := params.Get(name)
+	if str == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(str)
+	if err != nil {
+		return 0, &ivf.InputError{Param: name, Message: fmt.Sprintf("%s must be an integer. Got %s", name, str)}
+	}
+	return v, nil
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/internal/report"
+	"ivf_calculator/pkg/ivf"
+)
+
+// ReportHandler renders the patient report of the /calculate parameters. format=pdf selects the PDF, the default is
+// a printable HTML page.
+func (s *Server) ReportHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	format, err := reportFormat(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := s.IVFService.Predict(params.Get("model"), params)
+	if err != nil {
+		s.calculationError(w, r, err)
+		return
+	}
+	setLogFormula(r, result.CDCFormula)
+
+	rep, err := s.newReport(params, result)
+	if err != nil {
+		s.calculationError(w, r, err)
+		return
+	}
+	rep.CreatedAt = time.Now()
+	s.writeReport(w, r, format, rep)
+}
+
+// CalculationReportHandler renders the patient report of a saved calculation. The prediction is the saved one; the
+// comparison and the projection are calculated from its inputs.
+func (s *Server) CalculationReportHandler(w http.ResponseWriter, r *http.Request) {
+	format, err := reportFormat(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c, err := s.History.Calculation(r.PathValue("id"))
+	if errors.Is(err, models.ErrNotFound) {
+		http.Error(w, "Calculation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.Logger.Printf("request_id=%s error reading calculation: %v", RequestIDFromContext(r.Context()), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	values := url.Values{}
+	for name, value := range c.Inputs {
+		values.Set(name, value)
+	}
+	rep, err := s.newReport(values, &models.IVFResult{
+		Model:       c.Model,
+		SuccessRate: c.SuccessRate,
+		CDCFormula:  c.CDCFormula,
+		Precision:   c.Precision,
+		Interval:    c.Interval,
+		Explanation: c.Explanation,
+	})
+	if err != nil {
+		s.calculationError(w, r, err)
+		return
+	}
+	rep.CalculationID = c.ID
+	rep.CreatedAt = c.CreatedAt
+	s.writeReport(w, r, format, rep)
+}
+
+// newReport adds the inputs the model of the result reads, and for the CDC model the own vs donor eggs comparison and
+// the projection over the default number of cycles, to the result. Other values, e.g. made up params, are not shown.
+func (s *Server) newReport(values url.Values, result *models.IVFResult) (*report.Report, error) {
+	params, err := s.IVFService.ModelParams(result.Model)
+	if err != nil {
+		return nil, err
+	}
+	rep := &report.Report{Inputs: report.Inputs(params, values), Result: result}
+	if result.Model != ivf.CDCModelName {
+		return rep, nil
+	}
+	input, err := s.validateInput(values)
+	if err != nil {
+		return nil, err
+	}
+	if rep.Scenarios, err = s.IVFService.CompareScenarios(input); err != nil {
+		return nil, err
+	}
+	if rep.Projection, err = s.IVFService.CalculateCumulative(input, defaultCycles, defaultMonthsBetweenCycles); err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+func (s *Server) writeReport(w http.ResponseWriter, r *http.Request, format string, rep *report.Report) {
+	var body bytes.Buffer
+	write, contentType := report.WriteHTML, "text/html; charset=utf-8"
+	if format == "pdf" {
+		write, contentType = report.WritePDF, "application/pdf"
+		w.Header().Set("Content-Disposition", `inline; filename="ivf-success-report.pdf"`)
+	}
+	if err := write(&body, rep); err != nil {
+		s.Logger.Printf("request_id=%s error rendering report: %v", RequestIDFromContext(r.Context()), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = body.WriteTo(w)
+}
+
+func reportFormat(params url.Values) (string, error) {
+	switch format := params.Get("format"); format {
+	case "", "html":
+		return "html", nil
+	case "pdf":
+		return format, nil
+	default:
+		return "", fmt.Errorf("format must be html or pdf. Got %s", format)
+	}
+}
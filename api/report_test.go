@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ivf_calculator/internal/repo"
+	"ivf_calculator/internal/server"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportHandler(t *testing.T) {
+	s := newCalculatorServer()
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report?"+validQuery, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	page := rec.Body.String()
+	assert.Contains(t, page, "<strong>62.21%</strong>")
+	assert.Contains(t, page, `<tr><td>Donor</td><td>Any</td><td class="number">60.91%</td></tr>`)
+	assert.Contains(t, page, `<tr><td>3</td><td>32</td><td class="number">57.60%</td><td class="number">93.21%</td></tr>`)
+	assert.NotContains(t, page, "<dt>format</dt>")
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+		"/report?format=pdf&Diagnosis=Anything&note=%E2%9C%93&"+validQuery, nil))
+	require.Equal(t, http.StatusOK, rec.Code, "params the model doesn't read are left out")
+	assert.NotContains(t, rec.Body.String(), "Diagnosis")
+	assert.NotContains(t, rec.Body.String(), "Anything")
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report?format=pdf&"+validQuery, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "%PDF-"))
+	assert.Contains(t, rec.Body.String(), "(Chance of having a baby with one IVF cycle: 62.21%)")
+
+	tests := []struct {
+		name  string
+		query string
+		error string
+	}{
+		{"invalid format", "format=docx&" + validQuery, "format must be html or pdf. Got docx"},
+		{"invalid input", strings.Replace(validQuery, "age=32", "age=60", 1), "age must be between 20 and 50. Got 60"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report?"+tt.query, nil))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, tt.error, strings.TrimSpace(rec.Body.String()))
+		})
+	}
+}
+
+func TestCalculationReportHandler(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	history, err := repo.NewSQLiteHistory(&repo.HistoryConfig{Path: filepath.Join(t.TempDir(), "history.db")})
+	require.NoError(t, err)
+	defer history.Close()
+	calculator := server.NewSuccessCalculator(&server.Config{
+		Logger:  logger,
+		Repo:    repo.NewIVFFormula(&repo.Config{FilePath: "../internal/repo/data/ivf_success_formulas.csv"}),
+		History: history,
+	})
+	s := New(&Config{Logger: logger, IVFService: calculator, History: calculator,
+		HistoryTokens: map[string]string{"reader-token": "reader"}})
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/calculate?"+validQuery, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response struct {
+		CalculationID string `json:"calculation_id"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, historyRequest("/calculations/"+response.CalculationID+"/report", "reader-token"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	page := rec.Body.String()
+	assert.Contains(t, page, "Calculation "+response.CalculationID)
+	assert.Contains(t, page, "<strong>62.21%</strong>")
+	assert.Contains(t, page, "<div><dt>Age</dt><dd>32</dd></div>")
+	assert.Contains(t, page, "Own (your plan)")
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, historyRequest(
+		"/calculations/"+response.CalculationID+"/report?format=pdf", "reader-token"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "Calculation "+response.CalculationID)
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, historyRequest("/calculations/unknown/report", "reader-token"))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -2,20 +2,35 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
 	"net/url"
-	"strconv"
 
+	"ivf_calculator/internal/fhir"
 	"ivf_calculator/internal/models"
-	"ivf_calculator/internal/utils"
+	"ivf_calculator/pkg/ivf"
 )
 
 type Config struct {
 	Port       string
 	Logger     *log.Logger
 	IVFService IVFCalculator
+	CORS       CORSConfig
+	// Admin serves the /admin endpoints, which are disabled when it is nil.
+	Admin FormulaAdministrator
+	// History records every /calculate result and serves GET /calculations/{id} to HistoryTokens and AdminTokens.
+	// Disabled when it is nil.
+	History CalculationHistory
+	// Cache adds ETags to the /calculate responses and serves GET /admin/cache. Disabled when it is nil.
+	Cache CalculationCache
+	// Shadow serves GET /admin/shadow, which is disabled when it is nil.
+	Shadow ShadowReporter
+	// AdminTokens maps the bearer tokens accepted by the /admin endpoints to the actor recorded in the audit trail.
+	// They are accepted by the history endpoints too.
+	AdminTokens map[string]string
+	// HistoryTokens maps the bearer tokens that can only read the calculation history to their actor.
+	HistoryTokens map[string]string
 }
 
 type Server struct {
@@ -23,7 +38,12 @@ type Server struct {
 }
 
 type IVFCalculator interface {
-	CalculateSuccess(params *models.IVFInput) (float64, error)
+	Predict(model string, values url.Values) (*models.IVFResult, error)
+	CalculateCumulative(params *models.IVFInput, cycles int, monthsBetween int) (*ivf.Projection, error)
+	CompareScenarios(params *models.IVFInput) ([]ivf.Scenario, error)
+	CalculateAgeCurve(params *models.IVFInput, fromAge int, toAge int, step int) (*ivf.AgeCurve, error)
+	WhatIfBMI(params *models.IVFInput, target *ivf.BMITarget, optimize bool) (*ivf.BMIWhatIf, error)
+	ModelParams(model string) ([]ivf.Param, error)
 }
 
 func New(config *Config) *Server {
@@ -33,214 +53,126 @@ func New(config *Config) *Server {
 }
 
 func (s *Server) Start() {
-	// Register handlers
-	http.HandleFunc("/calculate", s.CalculateIVFSuccessHandler)
-
 	// Start server
 	s.Logger.Printf("Starting server on port %s", s.Port)
-	if err := http.ListenAndServe(s.Port, nil); err != nil {
+	if err := http.ListenAndServe(s.Port, s.Handler()); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func (s *Server) CalculateIVFSuccessHandler(w http.ResponseWriter, r *http.Request) {
-	s.Logger.Printf("Received request from %s", r.RemoteAddr)
+type route struct {
+	pattern string
+	handler http.HandlerFunc
+}
+
+// routes lists every endpoint. Each of them must be described in the OpenAPI document.
+func (s *Server) routes() []route {
+	routes := []route{
+		{"/calculate", s.CalculateIVFSuccessHandler},
+		{"/calculate/cumulative", s.CalculateCumulativeHandler},
+		{"/calculate/age-curve", s.CalculateAgeCurveHandler},
+		{"/calculate/explain", s.ExplainHandler},
+		{"/compare", s.CompareScenariosHandler},
+		{"/whatif/bmi", s.WhatIfBMIHandler},
+		{"POST /fhir/RiskAssessment/$predict", s.PredictFHIRHandler},
+		{"GET /fhir/Questionnaire/" + fhir.QuestionnaireID, s.QuestionnaireHandler},
+		{"GET /ui", s.CalculatorUIHandler},
+		{"GET /report", s.ReportHandler},
+		{"/openapi.json", s.OpenAPIHandler},
+	}
+	if s.Admin != nil {
+		routes = append(routes, s.adminRoutes()...)
+	}
+	if s.Shadow != nil {
+		routes = append(routes, s.shadowRoutes()...)
+	}
+	if s.History != nil {
+		routes = append(routes, s.historyRoutes()...)
+	}
+	if s.Cache != nil {
+		routes = append(routes, s.cacheRoutes()...)
+	}
+	return routes
+}
+
+// Handler returns the router for all public endpoints wrapped in the server middleware.
+func (s *Server) Handler() http.Handler {
+	// Register handlers
+	routes := s.routes()
+	mux := http.NewServeMux()
+	for _, rt := range routes {
+		mux.HandleFunc(rt.pattern, rt.handler)
+	}
 
+	return s.withRequestLogging(s.withCORS(mux, routeMethods(routes)))
+}
+
+func (s *Server) CalculateIVFSuccessHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 	params := r.URL.Query()
-	input, err := s.validateInput(params)
+	result, err := s.IVFService.Predict(params.Get("model"), params)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		s.calculationError(w, r, err)
 		return
 	}
-
-	rate, err := s.IVFService.CalculateSuccess(input)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	setLogFormula(r, result.CDCFormula)
+	// the ETag is checked once the prediction succeeded, which the result cache makes cheap
+	if etag := s.calculationETag(params); etag != "" {
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
 	}
 
 	response := struct {
-		SuccessRate float64 `json:"success_rate"`
+		Model         string        `json:"model"`
+		SuccessRate   float64       `json:"success_rate"`
+		Precision     ivf.Precision `json:"precision,omitempty"`
+		Interval      *ivf.Interval `json:"confidence_interval,omitempty"`
+		CalculationID string        `json:"calculation_id,omitempty"`
 	}{
-		SuccessRate: rate,
+		Model:       result.Model,
+		SuccessRate: result.SuccessRate,
+		Precision:   result.Precision,
+		Interval:    result.Interval,
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(response)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-	}
-}
-
-func (s *Server) validateInput(params url.Values) (*models.IVFInput, error) {
-	input := &models.IVFInput{}
-
-	if ageStr := params.Get("age"); ageStr != "" {
-		age, err := strconv.Atoi(ageStr)
-		if err != nil {
-			return nil, err
-		}
-		if age < 20 || age > 50 {
-			return nil, fmt.Errorf("age must be between 20 and 50. Got %s", ageStr)
-		}
-		input.Age = age
-	}
-
-	if weightStr := params.Get("weight"); weightStr != "" {
-		weight, err := strconv.Atoi(weightStr)
-		if err != nil {
-			return nil, err
-		}
-		if weight < 80 || weight > 300 {
-			return nil, fmt.Errorf("weight must be between 80 and 300. Got %s", weightStr)
-		}
-		input.Weight = weight
-	}
-
-	if feetStr := params.Get("feet"); feetStr != "" {
-		feet, err := strconv.Atoi(feetStr)
+	if s.History != nil {
+		c, err := s.History.Record(params.Get("model"), params, result)
 		if err != nil {
-			return nil, err
+			s.calculationError(w, r, err)
+			return
 		}
-		input.Feet = feet
+		response.CalculationID = c.ID
 	}
 
-	if inchesStr := params.Get("inches"); inchesStr != "" {
-		inches, err := strconv.Atoi(inchesStr)
-		if err != nil {
-			return nil, err
-		}
-		input.Inches = inches
-	}
-
-	// Populate coefficients map
-	input.Coefficients = make(map[string]interface{})
-	priorPregnanciesStr := params.Get("gravida")
-	if priorPregnanciesStr != "" {
-		valGravida := []string{"0", "1", "2+"}
-		if utils.Contains(valGravida, priorPregnanciesStr) {
-			input.Coefficients["priorPregnancies"] = priorPregnanciesStr
-		} else {
-			return nil, fmt.Errorf("gravida has invalid value %s", priorPregnanciesStr)
-		}
-	} else {
-		return nil, fmt.Errorf("gravida is required")
-	}
-
-	priorLiveBirthsStr := params.Get("previous_live_births")
-	if priorLiveBirthsStr != "" {
-		valLiveBirths := []string{"0", "1", "2+"}
-		if utils.Contains(valLiveBirths, priorLiveBirthsStr) {
-			if priorLiveBirthsStr > priorPregnanciesStr {
-				return nil, fmt.Errorf("previous_live_births can't be greater then gravida")
-			}
-			input.Coefficients["priorLiveBirths"] = priorLiveBirthsStr
-		} else {
-			return nil, fmt.Errorf("previous_live_births has invalid value %s", priorLiveBirthsStr)
-		}
-	} else {
-		return nil, fmt.Errorf("previous_live_births is required")
-	}
-
-	factors := map[string]string{
-		"tubal_factor":               "tubalFactor",
-		"male_factor_infertility":    "maleFactorInfertility",
-		"endometriosis":              "endometriosis",
-		"ovulatory_disorder":         "ovulatoryDisorder",
-		"diminished_ovarian_reserve": "diminishedOvarianReserve",
-		"uterine_factor":             "uterineFactor",
-		"other_reason":               "otherReason",
-	}
-
-	known_reasons := false
-	for paramName, coefficientName := range factors {
-		if value, err := processKnownReasons(&params, paramName); err != nil {
-			return nil, err
-		} else {
-			input.Coefficients[coefficientName] = value
-			if value {
-				known_reasons = true
-			}
-		}
-	}
-
-	unexplainedInfertilitySel := false
-	if noReasonStr := params.Get("unexplained_infertility"); noReasonStr != "" {
-		switch noReasonStr {
-		case `Yes`:
-			input.Coefficients["unexplainedInfertility"] = true
-			unexplainedInfertilitySel = true
-		case `No`:
-			input.Coefficients["unexplainedInfertility"] = false
-		default:
-			return nil, fmt.Errorf("unexplained_infertility has invalid value %s", noReasonStr)
-		}
-	}
-
-	noReasonSel := false
-	if noReasonStr := params.Get("donotknow"); noReasonStr != "" {
-		switch noReasonStr {
-		case `Yes`:
-			input.ReasonKnown = "FALSE"
-			noReasonSel = true
-		case `No`:
-			input.ReasonKnown = "TRUE"
-		default:
-			return nil, fmt.Errorf("no_reason has invalid value %s", noReasonStr)
-		}
-	}
-
-	if !utils.OnlyOneTrue(known_reasons, unexplainedInfertilitySel, noReasonSel) {
-		return nil, fmt.Errorf("known_reasons OR unexplained_infertility OR no_reason is required")
-	}
+	writeJSON(w, response)
+}
 
-	if useOwnEggsStr := params.Get("eggSource"); useOwnEggsStr != "" {
-		switch useOwnEggsStr {
-		case `Own`:
-			input.UseOwnEggs = "TRUE"
-		case `Donor`:
-			input.UseOwnEggs = "FALSE"
-		default:
-			return nil, fmt.Errorf("eggSource has invalid value %s", useOwnEggsStr)
-		}
+// writeJSON writes the response as JSON.
+func writeJSON(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
 	}
+}
 
-	if ivfusedStr := params.Get("ivf_used"); ivfusedStr != "" {
-		if input.UseOwnEggs == "FALSE" {
-			input.IVFUsed = "N/A"
-		} else {
-			valIVFUsed := []string{"0", "1", "2", "3+"}
-			if utils.Contains(valIVFUsed, ivfusedStr) {
-				if ivfusedStr == "0" {
-					input.IVFUsed = "FALSE"
-				} else {
-					input.IVFUsed = "TRUE"
-				}
-			} else {
-				return nil, fmt.Errorf("ivf_used has invalid value %s", ivfusedStr)
-			}
-		}
-	} else {
-		return nil, fmt.Errorf("ivf_used is required")
+// calculationError reports invalid inputs found by the calculator as 400 and anything else as 500.
+func (s *Server) calculationError(w http.ResponseWriter, r *http.Request, err error) {
+	var inputErr *ivf.InputError
+	if errors.As(err, &inputErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-
-	return input, nil
+	s.Logger.Printf("request_id=%s error calculating success rate: %v", RequestIDFromContext(r.Context()), err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
 }
 
-func processKnownReasons(params *url.Values, paramName string) (bool, error) {
-	if paramStr := params.Get(paramName); paramStr != "" {
-		switch paramStr {
-		case `Yes`:
-			return true, nil
-		case `No`:
-			return false, nil
-		default:
-			return false, fmt.Errorf("%s has invalid value %s", paramName, paramStr)
-		}
-	}
-	return false, fmt.Errorf("%s is required", paramName)
+// validateInput checks the query parameters and converts them into the calculator input.
+func (s *Server) validateInput(params ivf.Values) (*models.IVFInput, error) {
+	return ivf.ParseInput(params)
 }
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"ivf_calculator/internal/repo"
+	"ivf_calculator/internal/server"
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCalculatorServer returns a server backed by the real calculator and CSV formulas.
+func newCalculatorServer() *Server {
+	return newCalculatorServerWithConfig(&repo.Config{})
+}
+
+func newCalculatorServerWithConfig(repoConfig *repo.Config) *Server {
+	logger := log.New(io.Discard, "", 0)
+	repoConfig.FilePath = "../internal/repo/data/ivf_success_formulas.csv"
+	repoConfig.Logger = logger
+	return New(&Config{
+		Logger: logger,
+		IVFService: server.NewSuccessCalculator(&server.Config{
+			Logger: logger,
+			Repo:   repo.NewIVFFormula(repoConfig),
+		}),
+	})
+}
+
+type calculateResponse struct {
+	SuccessRate float64       `json:"success_rate"`
+	Interval    *ivf.Interval `json:"confidence_interval"`
+}
+
+func TestCalculateIVFSuccessHandlerInterval(t *testing.T) {
+	t.Run("Point estimate only without covariance", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		newCalculatorServer().Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/calculate?"+validQuery, nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"model": "cdc", "success_rate": 62.21, "precision": "cdc"}`, rec.Body.String())
+	})
+
+	t.Run("Confidence interval with standard errors", func(t *testing.T) {
+		s := newCalculatorServerWithConfig(&repo.Config{CovariancePath: "../pkg/ivf/testdata/standard_errors.csv"})
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/calculate?"+validQuery, nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var response calculateResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, 62.21, response.SuccessRate)
+		assert.Equal(t, &ivf.Interval{Lower: 57.5, Upper: 66.69, Level: 0.95}, response.Interval)
+	})
+}
+
+func TestCalculateIVFSuccessHandlerPrecision(t *testing.T) {
+	s := newCalculatorServer()
+	calculate := func(t *testing.T, request string) (float64, string) {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, request, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var response struct {
+			SuccessRate float64 `json:"success_rate"`
+			Precision   string  `json:"precision"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response.SuccessRate, response.Precision
+	}
+
+	// the README patients of the golden cases, each with its exact twin
+	golden := readGoldenCases(t)
+	cases := map[string]goldenCase{}
+	for _, c := range golden {
+		cases[c.Name] = c
+	}
+	for _, readme := range golden {
+		patient, ok := strings.CutPrefix(readme.Name, "README: ")
+		if !ok {
+			continue
+		}
+		exact, ok := cases["Exact: "+patient]
+		require.True(t, ok, "golden cases have no exact twin of %s", readme.Name)
+
+		t.Run(patient, func(t *testing.T) {
+			var expected struct {
+				SuccessRate float64 `json:"success_rate"`
+			}
+			require.NoError(t, json.Unmarshal(readme.Body, &expected))
+			rate, precision := calculate(t, readme.Request)
+			assert.Equal(t, expected.SuccessRate, rate)
+			assert.Equal(t, "cdc", precision)
+
+			rate, precision = calculate(t, readme.Request+"&precision=cdc")
+			assert.Equal(t, expected.SuccessRate, rate)
+			assert.Equal(t, "cdc", precision)
+
+			require.NoError(t, json.Unmarshal(exact.Body, &expected))
+			rate, precision = calculate(t, exact.Request)
+			assert.InDelta(t, expected.SuccessRate, rate, 1e-9)
+			assert.Equal(t, "exact", precision)
+			assert.Equal(t, readme.Request+"&precision=exact", exact.Request)
+		})
+	}
+
+	t.Run("Invalid precision", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/calculate?"+validQuery+"&precision=high", nil))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "precision has invalid value high\n", rec.Body.String())
+	})
+}
+
+// fixedModel is a prediction model returning the same rate for any input.
+type fixedModel struct{}
+
+func (m *fixedModel) Name() string { return "fixed" }
+
+func (m *fixedModel) Params() []ivf.Param { return nil }
+
+func (m *fixedModel) Predict(values url.Values) (*ivf.Result, error) {
+	return &ivf.Result{Model: m.Name(), SuccessRate: 42}, nil
+}
+
+func TestCalculateIVFSuccessHandlerModel(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	formulas := repo.NewIVFFormula(&repo.Config{FilePath: "../internal/repo/data/ivf_success_formulas.csv", Logger: logger})
+	registry, err := ivf.NewRegistry(ivf.NewCDCModel(formulas), &fixedModel{})
+	require.NoError(t, err)
+	s := New(&Config{
+		Logger:     logger,
+		IVFService: server.NewSuccessCalculator(&server.Config{Logger: logger, Repo: formulas, Models: registry}),
+	})
+
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+		expectedBody string
+	}{
+		{"Default model", validQuery, http.StatusOK, `{"model": "cdc", "success_rate": 62.21, "precision": "cdc"}`},
+		{"CDC model by name", validQuery + "&model=cdc", http.StatusOK,
+			`{"model": "cdc", "success_rate": 62.21, "precision": "cdc"}`},
+		{"Other model by name", "model=fixed", http.StatusOK, `{"model": "fixed", "success_rate": 42}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/calculate?"+tt.query, nil))
+
+			require.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+			assert.JSONEq(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+
+	t.Run("Unknown model", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/calculate?"+validQuery+"&model=sart", nil))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "model has invalid value sart\n", rec.Body.String())
+	})
+}
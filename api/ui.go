@@ -0,0 +1,132 @@
+package api
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/pkg/ivf"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"percent": func(v float64) float64 { return v * 100 },
+}).ParseFS(templateFiles, "templates/*.html"))
+
+// uiField is an input of the calculator form.
+type uiField struct {
+	ivf.Param
+	// Control is "number", "radio" or "select".
+	Control string
+	Value   string
+	Error   string
+}
+
+// uiGroup is a fieldset of the calculator form. The infertility reasons are grouped by their Choice.
+type uiGroup struct {
+	Name   string
+	Legend string
+	Fields []uiField
+	Error  string
+}
+
+type uiPage struct {
+	Groups []uiGroup
+	Model  string
+	// Errors lists every input error for the summary at the top of the form.
+	Errors        []*ivf.InputError
+	Result        *models.IVFResult
+	CalculationID string
+	// ReportURL is the printable patient report of the result.
+	ReportURL string
+	// Failed is set when the calculation failed for another reason than the inputs.
+	Failed bool
+}
+
+// CalculatorUIHandler serves the calculator as an HTML form. The form submits the /calculate parameters to itself, so
+// a result page can be bookmarked or turned into an API call by changing the path.
+func (s *Server) CalculatorUIHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	page := &uiPage{Model: params.Get("model")}
+	status := http.StatusOK
+	if len(params) > 0 {
+		result, err := s.IVFService.Predict(params.Get("model"), params)
+		var inputErr *ivf.InputError
+		switch {
+		case errors.As(err, &inputErr):
+			page.Errors = append(page.Errors, inputErr)
+			status = http.StatusBadRequest
+		case err != nil:
+			s.Logger.Printf("request_id=%s error calculating success rate: %v", RequestIDFromContext(r.Context()), err)
+			page.Failed = true
+			status = http.StatusInternalServerError
+		default:
+			setLogFormula(r, result.CDCFormula)
+			page.Result = result
+			page.ReportURL = "/report?" + params.Encode()
+			if s.History != nil {
+				c, err := s.History.Record(params.Get("model"), params, result)
+				if err != nil {
+					s.Logger.Printf("request_id=%s error recording calculation: %v", RequestIDFromContext(r.Context()), err)
+				} else {
+					page.CalculationID = c.ID
+				}
+			}
+		}
+	}
+	page.Groups = formGroups(params, page.Errors)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := templates.ExecuteTemplate(w, "calculator.html", page); err != nil {
+		s.Logger.Printf("request_id=%s error rendering calculator: %v", RequestIDFromContext(r.Context()), err)
+	}
+}
+
+// formGroups lays the inputs out in the order of the CDC form. Consecutive inputs outside a Choice share a fieldset.
+func formGroups(values url.Values, errs []*ivf.InputError) []uiGroup {
+	errorOf := func(name string) string {
+		for _, err := range errs {
+			if err.Param == name {
+				return err.Message
+			}
+		}
+		return ""
+	}
+	choiceOf := map[string]ivf.Choice{}
+	for _, c := range ivf.Choices() {
+		for _, option := range c.Options {
+			for _, name := range option {
+				choiceOf[name] = c
+			}
+		}
+	}
+
+	var groups []uiGroup
+	for _, p := range ivf.Params() {
+		field := uiField{Param: p, Control: "select", Value: values.Get(p.Name), Error: errorOf(p.Name)}
+		switch {
+		case p.Type == "integer":
+			field.Control = "number"
+		case len(p.Enum) == 2:
+			field.Control = "radio"
+		}
+
+		name, legend := "patient", "About the patient"
+		if c, ok := choiceOf[p.Name]; ok {
+			name, legend = c.Name, c.Description
+		} else if len(groups) > 0 && groups[len(groups)-1].Name != "patient" {
+			name, legend = "treatment", "Treatment"
+		}
+		if len(groups) == 0 || groups[len(groups)-1].Name != name {
+			groups = append(groups, uiGroup{Name: name, Legend: legend, Error: errorOf(name)})
+		}
+		groups[len(groups)-1].Fields = append(groups[len(groups)-1].Fields, field)
+	}
+	return groups
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getUI(t *testing.T, query string) *httptest.ResponseRecorder {
+	s := newCalculatorServer()
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui?"+query, nil))
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	return rec
+}
+
+var (
+	controlPattern = regexp.MustCompile(`<(input|select)[^>]* id="([^"]+)"`)
+	labelPattern   = regexp.MustCompile(`<label for="([^"]+)"`)
+	idPattern      = regexp.MustCompile(`\sid="([^"]+)"`)
+	describedBy    = regexp.MustCompile(`aria-describedby="([^"]+)"`)
+)
+
+// assertAccessible checks the basics of an accessible form: a page language and title, a label for every control, a
+// unique id for every element and existing targets of aria-describedby.
+func assertAccessible(t *testing.T, page string) {
+	assert.Contains(t, page, `<html lang="en">`)
+	assert.Regexp(t, `<title>[^<]+</title>`, page)
+
+	labels := map[string]bool{}
+	for _, m := range labelPattern.FindAllStringSubmatch(page, -1) {
+		labels[m[1]] = true
+	}
+	for _, m := range controlPattern.FindAllStringSubmatch(page, -1) {
+		assert.True(t, labels[m[2]], "%s has no label", m[2])
+	}
+
+	ids := map[string]bool{}
+	for _, m := range idPattern.FindAllStringSubmatch(page, -1) {
+		assert.False(t, ids[m[1]], "id %s is not unique", m[1])
+		ids[m[1]] = true
+	}
+	for _, m := range describedBy.FindAllStringSubmatch(page, -1) {
+		assert.True(t, ids[m[1]], "aria-describedby %s has no target", m[1])
+	}
+	assert.Equal(t, strings.Count(page, "<fieldset"), strings.Count(page, "<legend>"), "every fieldset has a legend")
+}
+
+func TestCalculatorUI(t *testing.T) {
+	rec := getUI(t, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	page := rec.Body.String()
+	assertAccessible(t, page)
+	assert.NotContains(t, page, "Result")
+	assert.NotContains(t, page, `role="alert"`)
+	for _, p := range ivf.Params() {
+		assert.Contains(t, page, `name="`+p.Name+`"`, "the form has no %s field", p.Name)
+	}
+
+	rec = getUI(t, validQuery)
+	require.Equal(t, http.StatusOK, rec.Code)
+	page = rec.Body.String()
+	assertAccessible(t, page)
+	assert.Contains(t, page, "<strong>62.21%</strong>")
+	assert.Contains(t, page, "CDC formula 1-3")
+	assert.Contains(t, page, `<a href="/report?age=32&amp;`)
+	assert.Contains(t, page, `<tr><th scope="row">endometriosis</th><td>Yes</td><td class="number">0.0277</td></tr>`)
+	assert.Contains(t, page, `<input type="number" id="age" name="age" value="32"`, "the inputs are kept")
+	assert.Contains(t, page, `<option value="1" selected>1</option>`)
+	assert.Contains(t, page, `id="eggSource-Own" name="eggSource" value="Own" checked`)
+}
+
+func TestCalculatorUIErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		field string
+		error string
+	}{
+		{"out of range", strings.Replace(validQuery, "age=32", "age=60", 1), "age", "age must be between 20 and 50. Got 60"},
+		{"missing", strings.Replace(validQuery, "gravida=1", "gravida=", 1), "gravida", "gravida is required"},
+		{"several reasons", strings.Replace(validQuery, "donotknow=No", "donotknow=Yes", 1), "infertility_reason",
+			"known_reasons OR unexplained_infertility OR no_reason is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := getUI(t, tt.query)
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			page := rec.Body.String()
+			assertAccessible(t, page)
+			assert.Contains(t, page, `<title>Error: IVF Success Calculator</title>`)
+			assert.Contains(t, page, `<a href="#`+tt.field+`">`+tt.error+`</a>`, "the summary links to the field")
+			assert.Contains(t, page, `<p id="`+tt.field+`-error" class="error">`+tt.error+`</p>`, "the error is shown inline")
+			assert.Contains(t, page, `aria-describedby="`+tt.field+`-error"`)
+			assert.NotContains(t, page, "<strong>")
+		})
+	}
+}
@@ -0,0 +1,204 @@
+// Package client is a Go client for the IVF success calculator API.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"ivf_calculator/pkg/ivf"
+)
+
+// Egg sources accepted by the calculator.
+const (
+	EggSourceOwn   = ivf.EggSourceOwn
+	EggSourceDonor = ivf.EggSourceDonor
+)
+
+type Config struct {
+	// BaseURL is the calculator address, e.g. http://localhost:8080.
+	BaseURL string
+	// HTTPClient is used to send requests. http.DefaultClient is used when nil.
+	HTTPClient *http.Client
+	// MaxRetries is the number of times a request is retried after a network error or a 5xx/429 response.
+	MaxRetries int
+	// Backoff is the delay before the first retry. It doubles with every further retry.
+	Backoff time.Duration
+	// BatchConcurrency is the number of calculations Batch runs at once. Defaults to 4.
+	BatchConcurrency int
+}
+
+type Client struct {
+	*Config
+}
+
+func New(config *Config) *Client {
+	return &Client{
+		config,
+	}
+}
+
+const defaultBatchConcurrency = 4
+
+// Request holds the patient inputs of a calculation, mirroring the /calculate query parameters. Age, Weight and Feet
+// are required; the inputs left at their zero value are not sent.
+type Request = ivf.Patient
+
+// Result is the calculation response.
+type Result struct {
+	// Model is the prediction model the success rate was calculated with.
+	Model string `json:"model"`
+	// SuccessRate is the chance of having a baby in percents.
+	SuccessRate float64 `json:"success_rate"`
+	// Precision is the rounding of the success rate, empty for the models without precision modes.
+	Precision ivf.Precision `json:"precision,omitempty"`
+	// Interval is the confidence interval of the success rate, set when the server has a coefficient covariance.
+	Interval *ivf.Interval `json:"confidence_interval,omitempty"`
+	// CalculationID is the ID of the calculation in the server history, set when the history is enabled.
+	CalculationID string `json:"calculation_id,omitempty"`
+}
+
+// Explanation is the term breakdown of a success rate.
+type Explanation = ivf.Explanation
+
+// BatchResult is the outcome of one request of a batch: either Result or Err is set.
+type BatchResult struct {
+	Result *Result
+	Err    error
+}
+
+// Calculate returns the success rate for the given patient inputs.
+func (c *Client) Calculate(ctx context.Context, req *Request) (*Result, error) {
+	params, err := query(req)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{}
+	if err := c.get(ctx, "/calculate", params, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Explain returns the contribution of every formula term to the success rate of the given patient inputs.
+func (c *Client) Explain(ctx context.Context, req *Request) (*Explanation, error) {
+	params, err := query(req)
+	if err != nil {
+		return nil, err
+	}
+	explanation := &Explanation{}
+	if err := c.get(ctx, "/calculate/explain", params, explanation); err != nil {
+		return nil, err
+	}
+	return explanation, nil
+}
+
+// Batch calculates the success rate of every request, running up to BatchConcurrency calculations at once. The
+// results are in the order of the requests; a failed request doesn't stop the others.
+func (c *Client) Batch(ctx context.Context, reqs []*Request) []BatchResult {
+	concurrency := c.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	results := make([]BatchResult, len(reqs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, req *Request) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i].Result, results[i].Err = c.Calculate(ctx, req)
+		}(i, req)
+	}
+	wg.Wait()
+	return results
+}
+
+// query returns the /calculate parameters of the request. The server reads a missing number as 0, so the required
+// numbers are checked here rather than sent as 0.
+func query(req *Request) (url.Values, error) {
+	params := req.Values()
+	for _, name := range []string{"age", "weight", "feet"} {
+		if params.Get(name) == "0" {
+			return nil, fmt.Errorf("%w: %s is required", ErrInvalidInput, name)
+		}
+	}
+	for name := range params {
+		if v := params.Get(name); v == "" || (name == "inches" && v == "0") {
+			params.Del(name)
+		}
+	}
+	return params, nil
+}
+
+// get sends a GET request, retrying transient failures, and decodes the JSON response into out.
+func (c *Client) get(ctx context.Context, path string, params url.Values, out interface{}) error {
+	endpoint := strings.TrimRight(c.BaseURL, "/") + path + "?" + params.Encode()
+	backoff := c.Backoff
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = c.do(ctx, endpoint, out)
+		if err == nil || attempt >= c.MaxRetries || !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) do(ctx context.Context, endpoint string, out interface{}) error {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &Error{
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(body)),
+			RequestID:  resp.Header.Get("X-Request-ID"),
+		}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+// retryable returns true for network errors and server side failures.
+// Context cancellation and client errors are returned right away.
+func retryable(err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,200 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ivf_calculator/api"
+	"ivf_calculator/internal/repo"
+	"ivf_calculator/internal/server"
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCalculatorHandler returns the real API handler backed by the CSV formulas.
+func newCalculatorHandler() http.Handler {
+	logger := log.New(io.Discard, "", 0)
+	ivfRepo := repo.NewIVFFormula(&repo.Config{
+		FilePath: "../internal/repo/data/ivf_success_formulas.csv",
+		Logger:   logger,
+	})
+	return api.New(&api.Config{
+		Logger: logger,
+		IVFService: server.NewSuccessCalculator(&server.Config{
+			Logger: logger,
+			Repo:   ivfRepo,
+		}),
+	}).Handler()
+}
+
+func ownEggsRequest() *Request {
+	return &Request{
+		Age:                32,
+		Weight:             150,
+		Feet:               5,
+		Inches:             8,
+		IVFUsed:            "0",
+		Gravida:            "1",
+		PreviousLiveBirths: "1",
+		Endometriosis:      true,
+		OvulatoryDisorder:  true,
+		EggSource:          EggSourceOwn,
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	ts := httptest.NewServer(newCalculatorHandler())
+	defer ts.Close()
+	c := New(&Config{BaseURL: ts.URL})
+
+	result, err := c.Calculate(context.Background(), ownEggsRequest())
+
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Model: "cdc", SuccessRate: 62.21, Precision: "cdc"}, result)
+}
+
+func TestCalculateLeavesUnsetInputsOut(t *testing.T) {
+	var query url.Values
+	handler := newCalculatorHandler()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	c := New(&Config{BaseURL: ts.URL})
+
+	req := ownEggsRequest()
+	req.Inches = 0
+	req.Gravida = ""
+	_, err := c.Calculate(context.Background(), req)
+
+	assert.True(t, errors.Is(err, ErrInvalidInput))
+	assert.EqualError(t, err, "ivf calculator returned 400: gravida is required")
+	assert.NotContains(t, query, "gravida")
+	assert.NotContains(t, query, "inches")
+	assert.NotContains(t, query, "precision")
+
+	query = nil
+	req = ownEggsRequest()
+	req.Age = 0
+	_, err = c.Calculate(context.Background(), req)
+
+	assert.True(t, errors.Is(err, ErrInvalidInput))
+	assert.EqualError(t, err, "invalid input: age is required")
+	assert.Nil(t, query, "a request missing a required number is not sent")
+}
+
+func TestExplain(t *testing.T) {
+	ts := httptest.NewServer(newCalculatorHandler())
+	defer ts.Close()
+	c := New(&Config{BaseURL: ts.URL})
+
+	explanation, err := c.Explain(context.Background(), ownEggsRequest())
+
+	require.NoError(t, err)
+	assert.Equal(t, "1-3", explanation.CDCFormula)
+	assert.Equal(t, 62.21, explanation.SuccessRate)
+	assert.Contains(t, explanation.Terms, ivf.Term{Name: "endometriosis", Input: "Yes", Contribution: 0.02773216})
+}
+
+func TestBatch(t *testing.T) {
+	ts := httptest.NewServer(newCalculatorHandler())
+	defer ts.Close()
+	c := New(&Config{BaseURL: ts.URL, BatchConcurrency: 2})
+
+	invalid := ownEggsRequest()
+	invalid.Age = 12
+	donor := ownEggsRequest()
+	donor.EggSource = EggSourceDonor
+	results := c.Batch(context.Background(), []*Request{ownEggsRequest(), invalid, donor})
+
+	require.Len(t, results, 3)
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, 62.21, results[0].Result.SuccessRate)
+	assert.True(t, errors.Is(results[1].Err, ErrInvalidInput))
+	assert.Nil(t, results[1].Result)
+	require.NoError(t, results[2].Err)
+	assert.Equal(t, 60.91, results[2].Result.SuccessRate)
+}
+
+func TestCalculateInvalidInput(t *testing.T) {
+	var calls int32
+	handler := newCalculatorHandler()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	c := New(&Config{BaseURL: ts.URL, MaxRetries: 3})
+
+	req := ownEggsRequest()
+	req.Age = 12
+	_, err := c.Calculate(context.Background(), req)
+
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrInvalidInput))
+	assert.False(t, errors.Is(err, ErrServer))
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "age must be between 20 and 50. Got 12", apiErr.Message)
+	assert.NotEmpty(t, apiErr.RequestID)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "client errors must not be retried")
+}
+
+func TestCalculateRetriesServerErrors(t *testing.T) {
+	var calls int32
+	handler := newCalculatorHandler()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	t.Run("Succeeds within the retry budget", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		c := New(&Config{BaseURL: ts.URL, MaxRetries: 2, Backoff: time.Millisecond})
+
+		result, err := c.Calculate(context.Background(), ownEggsRequest())
+
+		require.NoError(t, err)
+		assert.Equal(t, 62.21, result.SuccessRate)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("Gives up after the retry budget", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		c := New(&Config{BaseURL: ts.URL, MaxRetries: 1, Backoff: time.Millisecond})
+
+		_, err := c.Calculate(context.Background(), ownEggsRequest())
+
+		assert.True(t, errors.Is(err, ErrServer))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestCalculateContextCanceled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	c := New(&Config{BaseURL: ts.URL, MaxRetries: 5, Backoff: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.Calculate(ctx, ownEggsRequest())
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors returned by the server, matched by status code. Use errors.Is to check for them. ErrInvalidInput is also
+// returned without calling the server when a required number is missing from the request.
+var (
+	ErrInvalidInput     = errors.New("invalid input")
+	ErrMethodNotAllowed = errors.New("method not allowed")
+	ErrServer           = errors.New("server error")
+)
+
+// Error is a non-200 response from the calculator.
+type Error struct {
+	StatusCode int
+	// Message is the error text sent by the server, e.g. "gravida is required".
+	Message string
+	// RequestID is the X-Request-ID of the failed request, useful to find it in the server logs.
+	RequestID string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ivf calculator returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Is matches the error against ErrInvalidInput, ErrMethodNotAllowed and ErrServer.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrInvalidInput:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrMethodNotAllowed:
+		return e.StatusCode == http.StatusMethodNotAllowed
+	case ErrServer:
+		return e.StatusCode >= 500
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+// Command ivfbatch scores a CSV export of historical patients into an output CSV.
+//
+//	ivfbatch -in=cohort.csv -out=cohort_scored.csv -workers=8
+//
+// Input columns are named after the /calculate parameters. The output repeats them and adds
+// success_rate, lower and upper (the confidence interval, with -covariance), cdc_formula and error. A summary of the
+// failures is printed when the job is done.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+
+	"ivf_calculator/internal/batch"
+	"ivf_calculator/pkg/ivf"
+)
+
+func main() {
+	inPath := flag.String("in", "", "input CSV file")
+	outPath := flag.String("out", "", "output CSV file")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of rows scored in parallel")
+	formulasPath := flag.String("formulas", "internal/repo/data/ivf_success_formulas.csv", "path to the CDC formula CSV file, or .json file of formulas defined as term lists")
+	covariancePath := flag.String("covariance", "", "optional CSV file with coefficient standard errors or covariances, adds the confidence interval columns")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "[ivfbatch]: ", log.LstdFlags)
+	if *inPath == "" || *outPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	in, err := os.Open(*inPath)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer in.Close()
+	out, err := os.Create(*outPath)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer out.Close()
+
+	formulas, err := ivf.LoadFormulas(*formulasPath)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if *covariancePath != "" {
+		if err := ivf.LoadCovariance(*covariancePath, formulas); err != nil {
+			logger.Fatal(err)
+		}
+	}
+	// a single model can't clash with another one
+	models, _ := ivf.NewRegistry(ivf.NewCDCModel(ivf.FormulaSet(formulas)))
+	scorer := batch.NewScorer(&batch.Config{
+		Models:  models,
+		Workers: *workers,
+	})
+
+	summary, err := scorer.Run(in, out)
+	if summary != nil {
+		fmt.Fprintf(os.Stderr, "rows: %d, scored: %d, failed: %d\n", summary.Rows, summary.Scored, summary.Failed)
+		for _, message := range summary.TopFailures() {
+			fmt.Fprintf(os.Stderr, "%8d  %s\n", summary.Failures[message], message)
+		}
+	}
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
@@ -0,0 +1,128 @@
+// Command ivfcalc calculates IVF success rates offline, without starting the HTTP server.
+//
+// Score one patient from flags named after the /calculate parameters:
+//
+//	ivfcalc -age=32 -weight=150 -feet=5 -inches=8 -ivf_used=0 -gravida=1 -previous_live_births=1 \
+//		-tubal_factor=No -male_factor_infertility=No -endometriosis=Yes -ovulatory_disorder=Yes \
+//		-diminished_ovarian_reserve=No -uterine_factor=No -other_reason=No -unexplained_infertility=No \
+//		-donotknow=No -eggSource=Own
+//
+// Or score every patient of a CSV file, with a header of parameter names, or a JSON array of objects:
+//
+//	ivfcalc -input=csv -format=csv < patients.csv
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"ivf_calculator/internal/batch"
+	"ivf_calculator/pkg/ivf"
+)
+
+// record is the outcome of scoring one patient.
+type record struct {
+	Row         int
+	Explanation *ivf.Explanation
+	Err         error
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command with the given arguments and returns its exit status: 1 when a patient failed, 2 when the
+// command failed.
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("ivfcalc", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	formulasPath := fs.String("formulas", "internal/repo/data/ivf_success_formulas.csv", "path to the CDC formula CSV file, or .json file of formulas defined as term lists")
+	covariancePath := fs.String("covariance", "", "optional CSV file with coefficient standard errors or covariances")
+	input := fs.String("input", "", "read patients from stdin instead of flags: csv or json")
+	format := fs.String("format", "table", "output format: table, json or csv")
+	explain := fs.Bool("explain", false, "include the contribution of every formula term")
+
+	patientFlags := map[string]*string{}
+	for _, p := range ivf.Params() {
+		patientFlags[p.Name] = fs.String(p.Name, "", p.Description)
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	formulas, err := ivf.LoadFormulas(*formulasPath)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	if *covariancePath != "" {
+		if err := ivf.LoadCovariance(*covariancePath, formulas); err != nil {
+			return fail(stderr, err)
+		}
+	}
+
+	flagValues := url.Values{}
+	for name, value := range patientFlags {
+		if *value != "" {
+			flagValues.Set(name, *value)
+		}
+	}
+	patients, err := readPatients(*input, flagValues, stdin)
+	if err != nil {
+		return fail(stderr, err)
+	}
+
+	records := scoreAll(formulas, patients)
+	if err := writeRecords(stdout, *format, records, *explain); err != nil {
+		return fail(stderr, err)
+	}
+	for _, rec := range records {
+		if rec.Err != nil {
+			return 1
+		}
+	}
+	return 0
+}
+
+// readPatients returns the patient given by the flags, or the patients read from r in the input format.
+func readPatients(input string, flagValues url.Values, r io.Reader) ([]url.Values, error) {
+	switch input {
+	case "":
+		return []url.Values{flagValues}, nil
+	case "csv":
+		return batch.ReadCSV(r)
+	case "json":
+		return batch.ReadJSON(r)
+	default:
+		return nil, fmt.Errorf("unknown input %q, expected csv or json", input)
+	}
+}
+
+// scoreAll scores every patient, numbering the records from 1.
+func scoreAll(formulas []*ivf.Formula, patients []url.Values) []record {
+	model := ivf.NewCDCModel(ivf.FormulaSet(formulas))
+	records := make([]record, 0, len(patients))
+	for i, values := range patients {
+		rec := record{Row: i + 1}
+		rec.Explanation, rec.Err = score(model, values)
+		records = append(records, rec)
+	}
+	return records
+}
+
+// score predicts the success rate of the patient with the CDC model, as /calculate does, and returns its term
+// breakdown.
+func score(model ivf.PredictionModel, values url.Values) (*ivf.Explanation, error) {
+	result, err := model.Predict(values)
+	if err != nil {
+		return nil, err
+	}
+	return result.Explanation, nil
+}
+
+func fail(stderr io.Writer, err error) int {
+	fmt.Fprintln(stderr, "ivfcalc:", err)
+	return 2
+}
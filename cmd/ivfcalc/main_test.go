@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const formulasPath = "../../internal/repo/data/ivf_success_formulas.csv"
+
+var patientArgs = []string{"-age=32", "-weight=150", "-feet=5", "-inches=8", "-ivf_used=0", "-gravida=1",
+	"-previous_live_births=1", "-tubal_factor=No", "-male_factor_infertility=No", "-endometriosis=Yes",
+	"-ovulatory_disorder=Yes", "-diminished_ovarian_reserve=No", "-uterine_factor=No", "-other_reason=No",
+	"-unexplained_infertility=No", "-donotknow=No", "-eggSource=Own"}
+
+const patientsCSV = "age,weight,feet,inches,ivf_used,gravida,previous_live_births,tubal_factor," +
+	"male_factor_infertility,endometriosis,ovulatory_disorder,diminished_ovarian_reserve,uterine_factor,other_reason," +
+	"unexplained_infertility,donotknow,eggSource\n" +
+	"32,150,5,8,0,1,1,No,No,Yes,Yes,No,No,No,No,No,Own\n" +
+	"60,150,5,8,0,1,1,No,No,Yes,Yes,No,No,No,No,No,Own\n"
+
+func runCommand(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	status := run(append([]string{"-formulas=" + formulasPath}, args...), strings.NewReader(stdin), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestRunFlags(t *testing.T) {
+	status, stdout, stderr := runCommand("", patientArgs...)
+
+	assert.Equal(t, 0, status, stderr)
+	assert.Equal(t, "ROW  SUCCESS RATE  FORMULA  ERROR\n1    62.21%        1-3      \n", stdout)
+}
+
+func TestRunCSVInput(t *testing.T) {
+	status, stdout, _ := runCommand(patientsCSV, "-input=csv", "-format=json")
+
+	assert.Equal(t, 1, status, "a failed patient sets the exit status")
+	assert.JSONEq(t, `[{"row": 1, "success_rate": 62.21, "cdc_formula": "1-3"},
+		{"row": 2, "error": "age must be between 20 and 50. Got 60"}]`, stdout)
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		error string
+	}{
+		{"unknown input", []string{"-input=xml"}, `ivfcalc: unknown input "xml", expected csv or json`},
+		{"unknown format", append([]string{"-format=yaml"}, patientArgs...),
+			`ivfcalc: unknown format "yaml", expected table, json or csv`},
+		{"missing formulas", []string{"-formulas=missing.csv"}, "ivfcalc: "},
+		{"unknown flag", []string{"-weight_kg=70"}, "flag provided but not defined: -weight_kg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _, stderr := runCommand("", tt.args...)
+			assert.Equal(t, 2, status)
+			assert.Contains(t, stderr, tt.error)
+		})
+	}
+}
+
+func TestReadPatients(t *testing.T) {
+	flagValues := url.Values{"age": {"32"}}
+	patients, err := readPatients("", flagValues, strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Equal(t, []url.Values{flagValues}, patients)
+
+	patients, err = readPatients("csv", nil, strings.NewReader("age,weight,gravida\n32,150,\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []url.Values{{"age": {"32"}, "weight": {"150"}}}, patients, "empty columns are left out")
+
+	patients, err = readPatients("json", nil, strings.NewReader(`[{"age": 32, "eggSource": "Own"}]`))
+	require.NoError(t, err)
+	assert.Equal(t, []url.Values{{"age": {"32"}, "eggSource": {"Own"}}}, patients)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"ivf_calculator/pkg/ivf"
+)
+
+// writeRecords writes the records in the table, json or csv format.
+func writeRecords(w io.Writer, format string, records []record, explain bool) error {
+	switch format {
+	case "table":
+		return writeTable(w, records, explain)
+	case "json":
+		return writeJSON(w, records, explain)
+	case "csv":
+		return writeCSV(w, records, explain)
+	default:
+		return fmt.Errorf("unknown format %q, expected table, json or csv", format)
+	}
+}
+
+func writeTable(w io.Writer, records []record, explain bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROW\tSUCCESS RATE\tFORMULA\tERROR")
+	for _, rec := range records {
+		if rec.Err != nil {
+			fmt.Fprintf(tw, "%d\t\t\t%s\n", rec.Row, rec.Err)
+			continue
+		}
+		rate := fmt.Sprintf("%.2f%%", rec.Explanation.SuccessRate)
+		if i := rec.Explanation.Interval; i != nil {
+			rate += fmt.Sprintf(" (%.2f-%.2f)", i.Lower, i.Upper)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t\n", rec.Row, rate, rec.Explanation.CDCFormula)
+		if explain {
+			for _, t := range rec.Explanation.Terms {
+				fmt.Fprintf(tw, "\t  %s\t%s\t%+.6f\n", t.Name, t.Input, t.Contribution)
+			}
+			fmt.Fprintf(tw, "\t  score\t\t%+.6f\n", rec.Explanation.Score)
+		}
+	}
+	return tw.Flush()
+}
+
+// jsonRecord is the JSON output of a single patient.
+type jsonRecord struct {
+	Row         int           `json:"row"`
+	SuccessRate *float64      `json:"success_rate,omitempty"`
+	Interval    *ivf.Interval `json:"confidence_interval,omitempty"`
+	CDCFormula  string        `json:"cdc_formula,omitempty"`
+	BMI         *float64      `json:"bmi,omitempty"`
+	Score       *float64      `json:"score,omitempty"`
+	Terms       []ivf.Term    `json:"terms,omitempty"`
+	Error       string        `json:"error,omitempty"`
+}
+
+func writeJSON(w io.Writer, records []record, explain bool) error {
+	out := make([]jsonRecord, 0, len(records))
+	for _, rec := range records {
+		jr := jsonRecord{Row: rec.Row}
+		if rec.Err != nil {
+			jr.Error = rec.Err.Error()
+		} else {
+			e := rec.Explanation
+			jr.SuccessRate = &e.SuccessRate
+			jr.Interval = e.Interval
+			jr.CDCFormula = e.CDCFormula
+			if explain {
+				jr.BMI = &e.BMI
+				jr.Score = &e.Score
+				jr.Terms = e.Terms
+			}
+		}
+		out = append(out, jr)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// writeCSV writes one line per patient. With explain, every term gets its own contribution column.
+func writeCSV(w io.Writer, records []record, explain bool) error {
+	header := []string{"row", "success_rate", "lower", "upper", "cdc_formula", "error"}
+	var termNames []string
+	if explain {
+		termNames = collectTermNames(records)
+		header = append(header, "bmi", "score")
+		header = append(header, termNames...)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, rec := range records {
+		line := []string{strconv.Itoa(rec.Row), "", "", "", "", ""}
+		if rec.Err != nil {
+			line[5] = rec.Err.Error()
+		} else {
+			line[1] = strconv.FormatFloat(rec.Explanation.SuccessRate, 'f', -1, 64)
+			if i := rec.Explanation.Interval; i != nil {
+				line[2] = strconv.FormatFloat(i.Lower, 'f', -1, 64)
+				line[3] = strconv.FormatFloat(i.Upper, 'f', -1, 64)
+			}
+			line[4] = rec.Explanation.CDCFormula
+		}
+		if explain {
+			line = append(line, explainColumns(rec.Explanation, termNames)...)
+		}
+		if err := writer.Write(line); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// collectTermNames returns the term names of all explanations in the order they first appear.
+func collectTermNames(records []record) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, rec := range records {
+		if rec.Explanation == nil {
+			continue
+		}
+		for _, t := range rec.Explanation.Terms {
+			if !seen[t.Name] {
+				seen[t.Name] = true
+				names = append(names, t.Name)
+			}
+		}
+	}
+	return names
+}
+
+func explainColumns(e *ivf.Explanation, termNames []string) []string {
+	columns := make([]string, 2+len(termNames))
+	if e == nil {
+		return columns
+	}
+	columns[0] = strconv.FormatFloat(e.BMI, 'f', -1, 64)
+	columns[1] = strconv.FormatFloat(e.Score, 'f', -1, 64)
+	for _, t := range e.Terms {
+		for i, name := range termNames {
+			if t.Name == name {
+				columns[2+i] = strconv.FormatFloat(t.Contribution, 'f', -1, 64)
+			}
+		}
+	}
+	return columns
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRecords() []record {
+	return []record{
+		{Row: 1, Explanation: &ivf.Explanation{CDCFormula: "1-3", BMI: 22.8, Score: 0.5, SuccessRate: 62.21,
+			Interval: &ivf.Interval{Lower: 58.3, Upper: 66, Level: 0.95},
+			Terms:    []ivf.Term{{Name: "intercept", Contribution: -6.8}, {Name: "age_linear", Input: "32", Contribution: 7.3}}}},
+		{Row: 2, Err: errors.New("gravida is required")},
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, writeRecords(&out, "table", testRecords(), true))
+
+	assert.Equal(t, ""+
+		"ROW  SUCCESS RATE          FORMULA  ERROR\n"+
+		"1    62.21% (58.30-66.00)  1-3      \n"+
+		"       intercept                    -6.800000\n"+
+		"       age_linear          32       +7.300000\n"+
+		"       score                        +0.500000\n"+
+		"2                                   gravida is required\n", out.String())
+}
+
+func TestWriteJSON(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, writeRecords(&out, "json", testRecords(), false))
+
+	assert.JSONEq(t, `[
+		{"row": 1, "success_rate": 62.21, "confidence_interval": {"lower": 58.3, "upper": 66, "level": 0.95},
+			"cdc_formula": "1-3"},
+		{"row": 2, "error": "gravida is required"}
+	]`, out.String())
+
+	out.Reset()
+	require.NoError(t, writeRecords(&out, "json", testRecords(), true))
+	assert.Contains(t, out.String(), `"score": 0.5`)
+	assert.Contains(t, out.String(), `"name": "age_linear"`)
+}
+
+func TestWriteCSV(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, writeRecords(&out, "csv", testRecords(), true))
+
+	assert.Equal(t, ""+
+		"row,success_rate,lower,upper,cdc_formula,error,bmi,score,intercept,age_linear\n"+
+		"1,62.21,58.3,66,1-3,,22.8,0.5,-6.8,7.3\n"+
+		"2,,,,,gravida is required,,,,\n", out.String())
+}
+
+func TestWriteRecordsUnknownFormat(t *testing.T) {
+	assert.EqualError(t, writeRecords(&bytes.Buffer{}, "yaml", testRecords(), false),
+		`unknown format "yaml", expected table, json or csv`)
+}
@@ -0,0 +1,43 @@
+// Command ivfimport imports a formula file into the SQLite formula database as a new version of a formula set.
+//
+//	ivfimport -db=formulas.db -formulas=internal/repo/data/ivf_success_formulas.csv -set=cdc
+//
+// The database is created and migrated when missing. The imported version becomes the active one.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"ivf_calculator/internal/repo"
+	"ivf_calculator/pkg/ivf"
+)
+
+func main() {
+	dbPath := flag.String("db", "formulas.db", "SQLite formula database")
+	formulasPath := flag.String("formulas", "internal/repo/data/ivf_success_formulas.csv",
+		"formula CSV file in the CDC layout, or .json file of formulas defined as term lists")
+	set := flag.String("set", repo.DefaultFormulaSet, "name of the formula set to import into")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "[ivfimport]: ", log.LstdFlags)
+
+	formulas, err := ivf.LoadFormulas(*formulasPath)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	db, err := repo.NewSQLiteFormula(&repo.SQLiteConfig{Path: *dbPath, FormulaSet: *set, Logger: logger})
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+
+	version, err := db.ImportFormulas(*set, *formulasPath, formulas)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	fmt.Printf("imported %d formulas into %s version %d\n", len(formulas), *set, version)
+}
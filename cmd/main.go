@@ -1,30 +1,218 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"ivf_calculator/api"
 	"ivf_calculator/internal/repo"
 	"ivf_calculator/internal/server"
+	"ivf_calculator/pkg/ivf"
 )
 
 func main() {
+	backend := flag.String("backend", "csv", "formula backend: csv reads -formulas, sqlite reads -db")
+	formulasPath := flag.String("formulas", "internal/repo/data/ivf_success_formulas.csv",
+		"formula CSV file in the CDC layout, or .json file of formulas defined as term lists")
+	dbPath := flag.String("db", "formulas.db", "SQLite formula database, see cmd/ivfimport")
+	formulaSet := flag.String("formula-set", repo.DefaultFormulaSet, "formula set read from the SQLite database")
+	covariancePath := flag.String("covariance", "", "optional CSV file with coefficient standard errors or covariances, "+
+		"enables confidence intervals")
+	corsOrigins := flag.String("cors-origins", "", "comma-separated list of origins allowed to call the API from a browser, or * for any")
+	corsHeaders := flag.String("cors-headers", "", "comma-separated list of request headers allowed in CORS requests")
+	corsMaxAge := flag.Int("cors-max-age", 600, "seconds browsers may cache a CORS preflight response")
+	adminTokensPath := flag.String("admin-tokens", "", "file of actor:token lines allowed to call the /admin "+
+		"endpoints: formula sets with the sqlite backend, the shadow report with a shadow candidate")
+	referenceCasesPath := flag.String("reference-cases", "internal/repo/data/reference_cases.json",
+		"patients with known success rates formula uploads are dry-run against")
+	shadowFormulas := flag.String("shadow-formulas", "", "candidate formula CSV or .json file evaluated in shadow mode, "+
+		"see GET /admin/shadow")
+	shadowVersion := flag.Int("shadow-version", 0, "candidate version of -formula-set evaluated in shadow mode with "+
+		"the sqlite backend")
+	historyPath := flag.String("history-db", "", "SQLite database recording every /calculate result, see "+
+		"GET /calculations/{id}. Can be the -db file. Empty disables the history")
+	historyTokensPath := flag.String("history-tokens", "", "file of actor:token lines allowed to read "+
+		"GET /calculations/{id}, which the -admin-tokens can read too. -history-db needs one of them")
+	historyRetention := flag.Duration("history-retention", 0, "how long calculations are kept in the history, "+
+		"e.g. 8760h. 0 keeps them forever")
+	modelsPath := flag.String("models", "", "optional JSON file of logistic prediction models served next to the CDC "+
+		"model, selected with the model input")
+	cacheSize := flag.Int("cache-size", 1000, "number of /calculate results kept in the LRU cache, 0 disables the "+
+		"cache and the ETags")
+	flag.Parse()
+
 	logger := log.New(os.Stdout, "[ivf_calculator]: ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
-	ivfRepo := repo.NewIVFFormula(&repo.Config{
-		FilePath: "internal/repo/data/ivf_success_formulas.csv",
-		Logger:   logger,
-	})
+	var ivfRepo server.FormulaGetter
+	var admin api.FormulaAdministrator
+	var shadow server.FormulaGetter
+	shadowName := *shadowFormulas
+	switch *backend {
+	case "csv":
+		ivfRepo = repo.NewIVFFormula(&repo.Config{
+			FilePath:       *formulasPath,
+			CovariancePath: *covariancePath,
+			Logger:         logger,
+		})
+	case "sqlite":
+		db, err := repo.NewSQLiteFormula(&repo.SQLiteConfig{
+			Path:           *dbPath,
+			FormulaSet:     *formulaSet,
+			CovariancePath: *covariancePath,
+			Logger:         logger,
+		})
+		if err != nil {
+			logger.Fatal(err)
+		}
+		defer db.Close()
+		ivfRepo = db
+		if *shadowVersion != 0 {
+			shadow = db.Version(*shadowVersion)
+			shadowName = fmt.Sprintf("%s version %d", *formulaSet, *shadowVersion)
+		}
+		if *adminTokensPath != "" {
+			referenceCases, err := repo.LoadReferenceCases(*referenceCasesPath)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			admin = server.NewFormulaAdmin(&server.AdminConfig{
+				Store:          db,
+				ReferenceCases: referenceCases,
+				Logger:         logger,
+			})
+		}
+	default:
+		logger.Fatalf("unknown backend %s, expected csv or sqlite", *backend)
+	}
+	if *shadowVersion != 0 && shadow == nil {
+		logger.Fatal("-shadow-version needs the sqlite backend, use -shadow-formulas with the csv backend")
+	}
+	if *shadowFormulas != "" {
+		if shadow != nil {
+			logger.Fatal("set either -shadow-formulas or -shadow-version")
+		}
+		shadow = repo.NewIVFFormula(&repo.Config{
+			FilePath:       *shadowFormulas,
+			CovariancePath: *covariancePath,
+			Logger:         logger,
+		})
+	}
+	if *adminTokensPath != "" && admin == nil && shadow == nil {
+		logger.Fatal("the admin endpoints need the sqlite backend or a shadow candidate")
+	}
+	adminTokens, err := readTokens(*adminTokensPath)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	historyTokens, err := readTokens(*historyTokensPath)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	var history server.CalculationStore
+	if *historyPath != "" {
+		if len(historyTokens) == 0 && len(adminTokens) == 0 {
+			logger.Fatal("the calculation history holds patient data, -history-db needs -history-tokens or -admin-tokens")
+		}
+		db, err := repo.NewSQLiteHistory(&repo.HistoryConfig{Path: *historyPath, Logger: logger})
+		if err != nil {
+			logger.Fatal(err)
+		}
+		defer db.Close()
+		history = db
+	}
+	models, err := ivf.NewRegistry(ivf.NewCDCModel(ivfRepo))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if *modelsPath != "" {
+		logisticModels, err := ivf.LoadLogisticModels(*modelsPath)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		for _, m := range logisticModels {
+			if err := models.Register(m); err != nil {
+				logger.Fatal(err)
+			}
+		}
+	}
 	ivfService := server.NewSuccessCalculator(&server.Config{
-		Logger: logger,
-		Repo:   ivfRepo,
+		Logger:     logger,
+		Repo:       ivfRepo,
+		Models:     models,
+		Shadow:     shadow,
+		ShadowName: shadowName,
+		History:    history,
+		Retention:  *historyRetention,
+		CacheSize:  *cacheSize,
 	})
 
+	var shadowReport api.ShadowReporter
+	if shadow != nil {
+		shadowReport = ivfService
+	}
+	var cache api.CalculationCache
+	if *cacheSize > 0 {
+		cache = ivfService
+	}
+	var calculationHistory api.CalculationHistory
+	if history != nil {
+		calculationHistory = ivfService
+	}
+
 	s := api.New(&api.Config{
 		Port:       ":8080",
 		Logger:     logger,
 		IVFService: ivfService,
+		History:    calculationHistory,
+		Cache:      cache,
+		CORS: api.CORSConfig{
+			AllowedOrigins: splitList(*corsOrigins),
+			AllowedHeaders: splitList(*corsHeaders),
+			MaxAge:         *corsMaxAge,
+		},
+		Admin:         admin,
+		Shadow:        shadowReport,
+		AdminTokens:   adminTokens,
+		HistoryTokens: historyTokens,
 	})
 
 	s.Start()
 }
+
+// readTokens reads the actor:token lines of an admin or history token file. Blank lines and lines starting with # are
+// skipped.
+func readTokens(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading tokens: %w", err)
+	}
+	tokens := map[string]string{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		actor, token, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(actor) == "" || strings.TrimSpace(token) == "" {
+			return nil, fmt.Errorf("%s line %d: expected actor:token", path, i+1)
+		}
+		tokens[strings.TrimSpace(token)] = strings.TrimSpace(actor)
+	}
+	return tokens, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
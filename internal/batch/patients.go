@@ -0,0 +1,69 @@
+package batch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+)
+
+// RowValues returns a CSV row as /calculate parameters named by the header. Empty columns are left out.
+func RowValues(header []string, fields []string) url.Values {
+	values := url.Values{}
+	for i, name := range header {
+		if i < len(fields) && fields[i] != "" {
+			values.Set(name, fields[i])
+		}
+	}
+	return values
+}
+
+// ReadCSV reads patients from CSV data whose header row holds the parameter names.
+func ReadCSV(r io.Reader) ([]url.Values, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading headers: %w", err)
+	}
+
+	var patients []url.Values
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return patients, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading records: %w", err)
+		}
+		patients = append(patients, RowValues(header, fields))
+	}
+}
+
+// ReadJSON reads patients from a JSON array of objects keyed by parameter name.
+// Values may be strings or numbers.
+func ReadJSON(r io.Reader) ([]url.Values, error) {
+	var objects []map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&objects); err != nil {
+		return nil, fmt.Errorf("error decoding patients: %w", err)
+	}
+
+	patients := make([]url.Values, 0, len(objects))
+	for _, object := range objects {
+		values := url.Values{}
+		for name, value := range object {
+			switch v := value.(type) {
+			case string:
+				values.Set(name, v)
+			case float64:
+				values.Set(name, strconv.FormatFloat(v, 'f', -1, 64))
+			case nil:
+			default:
+				return nil, fmt.Errorf("%s has unsupported value %v", name, value)
+			}
+		}
+		patients = append(patients, values)
+	}
+	return patients, nil
+}
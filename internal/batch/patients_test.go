@@ -0,0 +1,33 @@
+package batch
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCSV(t *testing.T) {
+	patients, err := ReadCSV(strings.NewReader("age,weight,gravida\n32,150,\n40,,2+\n"))
+
+	require.NoError(t, err)
+	assert.Equal(t, []url.Values{
+		{"age": {"32"}, "weight": {"150"}},
+		{"age": {"40"}, "gravida": {"2+"}},
+	}, patients, "empty columns are left out")
+
+	_, err = ReadCSV(strings.NewReader("age,weight\n32\n"))
+	assert.ErrorContains(t, err, "error reading records")
+}
+
+func TestReadJSON(t *testing.T) {
+	patients, err := ReadJSON(strings.NewReader(`[{"age": 32, "eggSource": "Own", "gravida": null}]`))
+
+	require.NoError(t, err)
+	assert.Equal(t, []url.Values{{"age": {"32"}, "eggSource": {"Own"}}}, patients)
+
+	_, err = ReadJSON(strings.NewReader(`[{"age": [32]}]`))
+	assert.EqualError(t, err, "age has unsupported value [32]")
+}
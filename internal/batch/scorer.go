@@ -0,0 +1,198 @@
+package batch
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+
+	"ivf_calculator/pkg/ivf"
+)
+
+type Config struct {
+	// Models are the prediction models the rows are scored with. A model column selects the model as the /calculate
+	// model parameter does; rows without one use the default model.
+	Models *ivf.Registry
+	// Workers is the number of rows scored in parallel. It defaults to 1.
+	Workers int
+}
+
+// Scorer scores a CSV file of patients, with columns named after the /calculate parameters,
+// into an output CSV with the success rate, formula id and validation error of every row.
+type Scorer struct {
+	*Config
+}
+
+func NewScorer(config *Config) *Scorer {
+	return &Scorer{
+		config,
+	}
+}
+
+// Summary counts the scored rows and groups the failures by error message.
+type Summary struct {
+	Rows     int
+	Scored   int
+	Failed   int
+	Failures map[string]int
+}
+
+// TopFailures returns the error messages ordered by the number of rows that failed with them.
+func (s *Summary) TopFailures() []string {
+	messages := make([]string, 0, len(s.Failures))
+	for message := range s.Failures {
+		messages = append(messages, message)
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		if s.Failures[messages[i]] != s.Failures[messages[j]] {
+			return s.Failures[messages[i]] > s.Failures[messages[j]]
+		}
+		return messages[i] < messages[j]
+	})
+	return messages
+}
+
+type row struct {
+	index    int
+	fields   []string
+	rate     float64
+	interval *ivf.Interval
+	cdc      string
+	err      error
+}
+
+var outputColumns = []string{"success_rate", "lower", "upper", "cdc_formula", "error"}
+
+// Run streams rows from in to out. Rows are scored by a pool of workers and written in input order,
+// each with the original columns followed by success_rate, the lower and upper bounds of its confidence interval,
+// cdc_formula and error.
+func (s *Scorer) Run(in io.Reader, out io.Writer) (*Summary, error) {
+	reader := csv.NewReader(in)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading headers: %w", err)
+	}
+
+	writer := csv.NewWriter(out)
+	if err := writer.Write(append(append([]string{}, header...), outputColumns...)); err != nil {
+		return nil, err
+	}
+
+	workers := s.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	rows := make(chan *row, workers*2)
+	scored := make(chan *row, workers*2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range rows {
+				s.score(header, r)
+				scored <- r
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(scored)
+	}()
+
+	var readErr error
+	go func() {
+		defer close(rows)
+		for index := 0; ; index++ {
+			fields, err := reader.Read()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				readErr = fmt.Errorf("error reading record %d: %w", index+1, err)
+				return
+			}
+			rows <- &row{index: index, fields: fields}
+		}
+	}()
+
+	summary := &Summary{Failures: map[string]int{}}
+	var writeErr error
+	pending := map[int]*row{}
+	next := 0
+	for r := range scored {
+		pending[r.index] = r
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			summary.add(r)
+			if writeErr == nil {
+				writeErr = writer.Write(r.output(len(header)))
+			}
+		}
+	}
+
+	writer.Flush()
+	if readErr != nil {
+		return summary, readErr
+	}
+	if writeErr != nil {
+		return summary, writeErr
+	}
+	return summary, writer.Error()
+}
+
+// score predicts the success rate of the row with its model, exactly as /calculate does.
+func (s *Scorer) score(header []string, r *row) {
+	if len(r.fields) != len(header) {
+		r.err = fmt.Errorf("row has %d columns, expected %d", len(r.fields), len(header))
+		return
+	}
+
+	params := RowValues(header, r.fields)
+	m, err := s.Models.Model(params.Get("model"))
+	if err != nil {
+		r.err = err
+		return
+	}
+	result, err := m.Predict(params)
+	if err != nil {
+		r.err = err
+		return
+	}
+	r.rate = result.SuccessRate
+	r.interval = result.Interval
+	r.cdc = result.CDCFormula
+}
+
+func (r *row) output(columns int) []string {
+	line := make([]string, columns, columns+len(outputColumns))
+	copy(line, r.fields)
+	if r.err != nil {
+		return append(line, "", "", "", "", r.err.Error())
+	}
+	lower, upper := "", ""
+	if r.interval != nil {
+		lower = strconv.FormatFloat(r.interval.Lower, 'f', -1, 64)
+		upper = strconv.FormatFloat(r.interval.Upper, 'f', -1, 64)
+	}
+	return append(line, strconv.FormatFloat(r.rate, 'f', -1, 64), lower, upper, r.cdc, "")
+}
+
+func (s *Summary) add(r *row) {
+	s.Rows++
+	if r.err != nil {
+		s.Failed++
+		s.Failures[r.err.Error()]++
+	} else {
+		s.Scored++
+	}
+}
@@ -0,0 +1,119 @@
+package batch
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ageModel returns the age as the success rate, after a delay that shuffles the order workers finish in.
+type ageModel struct{}
+
+func (m *ageModel) Name() string { return "age" }
+
+func (m *ageModel) Params() []ivf.Param { return ivf.Params() }
+
+func (m *ageModel) Predict(values url.Values) (*ivf.Result, error) {
+	params, err := ivf.ParseInput(values)
+	if err != nil {
+		return nil, err
+	}
+	time.Sleep(time.Duration(params.Age%3) * time.Millisecond)
+	if params.Age == 49 {
+		return nil, errors.New("no matching formula found for the given parameters")
+	}
+	return &ivf.Result{SuccessRate: float64(params.Age), CDCFormula: "1-3"}, nil
+}
+
+const header = "age,weight,feet,inches,ivf_used,gravida,tubal_factor,male_factor_infertility,endometriosis," +
+	"ovulatory_disorder,diminished_ovarian_reserve,uterine_factor,other_reason,unexplained_infertility,donotknow," +
+	"eggSource,previous_live_births"
+
+func patientRow(age int) string {
+	return fmt.Sprintf("%d,150,5,8,0,1,No,No,Yes,Yes,No,No,No,No,No,Own,1", age)
+}
+
+func TestRun(t *testing.T) {
+	lines := []string{header}
+	for age := 18; age <= 50; age++ {
+		lines = append(lines, patientRow(age))
+	}
+	lines = append(lines, "32,150,5,8,0,1,No,No,Yes,Yes,No,No,No,No,No,Own,1,extra")
+
+	var out bytes.Buffer
+	models, err := ivf.NewRegistry(&ageModel{})
+	require.NoError(t, err)
+	scorer := NewScorer(&Config{Models: models, Workers: 4})
+	summary, err := scorer.Run(strings.NewReader(strings.Join(lines, "\n")), &out)
+
+	require.NoError(t, err)
+	assert.Equal(t, 34, summary.Rows)
+	assert.Equal(t, 30, summary.Scored)
+	assert.Equal(t, 4, summary.Failed)
+	assert.Equal(t, []string{
+		"age must be between 20 and 50. Got 18",
+		"age must be between 20 and 50. Got 19",
+		"no matching formula found for the given parameters",
+		"row has 18 columns, expected 17",
+	}, summary.TopFailures())
+	assert.Equal(t, 1, summary.Failures["no matching formula found for the given parameters"])
+
+	records, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 35)
+	assert.Equal(t, []string{"eggSource", "previous_live_births", "success_rate", "lower", "upper", "cdc_formula", "error"},
+		records[0][15:])
+	for i, record := range records[1:34] {
+		age := fmt.Sprint(18 + i)
+		assert.Equal(t, age, record[0], "rows must be written in input order")
+		switch {
+		case age == "18" || age == "19":
+			assert.Equal(t, []string{"", "", "", "", "age must be between 20 and 50. Got " + age}, record[17:])
+		case age == "49":
+			assert.Equal(t, "no matching formula found for the given parameters", record[21])
+		default:
+			assert.Equal(t, []string{age, "", "", "1-3", ""}, record[17:])
+		}
+	}
+}
+
+// TestRunCDCModel scores rows with the formulas loaded once, the same way as /calculate: the precision column and
+// the covariance apply, and a model column selects the model.
+func TestRunCDCModel(t *testing.T) {
+	formulas, err := ivf.LoadFormulas("../repo/data/ivf_success_formulas.csv")
+	require.NoError(t, err)
+	require.NoError(t, ivf.LoadCovariance("../../pkg/ivf/testdata/standard_errors.csv", formulas))
+	models, err := ivf.NewRegistry(ivf.NewCDCModel(ivf.FormulaSet(formulas)), &ageModel{})
+	require.NoError(t, err)
+
+	in := header + ",precision,model\n" +
+		patientRow(32) + ",,\n" +
+		patientRow(32) + ",exact,cdc\n" +
+		patientRow(32) + ",,age\n" +
+		patientRow(32) + ",,sart\n"
+	var out bytes.Buffer
+	summary, err := NewScorer(&Config{Models: models, Workers: 2}).Run(strings.NewReader(in), &out)
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, summary.Scored)
+	records, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 5)
+	assert.Equal(t, "62.21", records[1][19])
+	assert.NotEmpty(t, records[1][20], "the covariance gives a confidence interval")
+	assert.NotEmpty(t, records[1][21])
+	assert.Equal(t, "1-3", records[1][22])
+	assert.True(t, strings.HasPrefix(records[2][19], "62.2054"), "exact precision: %s", records[2][19])
+	assert.Equal(t, "32", records[3][19])
+	assert.Equal(t, "model has invalid value sart", records[4][23])
+}
@@ -0,0 +1,408 @@
+package fhir
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"ivf_calculator/pkg/ivf"
+)
+
+const (
+	poundsPerKilogram  = 2.20462262185
+	centimetersPerInch = 2.54
+)
+
+// LOINC codes of the observations read from a Bundle.
+const (
+	loincAge            = "30525-0"
+	loincBodyWeight     = "29463-7"
+	loincBodyWeightMeas = "3141-9"
+	loincBodyHeight     = "8302-2"
+	loincPregnancies    = "11996-6"
+	loincLiveBirths     = "11636-8"
+)
+
+// diagnoses maps the ICD-10-CM code prefixes of the infertility diagnoses onto the calculator inputs.
+var diagnoses = []struct {
+	prefix string
+	param  string
+}{
+	{"N97.0", "ovulatory_disorder"},
+	{"E28.2", "ovulatory_disorder"},
+	{"N97.1", "tubal_factor"},
+	{"N97.2", "uterine_factor"},
+	{"N97.8", "other_reason"},
+	{"N97.9", "unexplained_infertility"},
+	{"N46", "male_factor_infertility"},
+	{"N80", "endometriosis"},
+	{"E28.3", "diminished_ovarian_reserve"},
+}
+
+// knownReasons are the inputs a diagnosis sets to Yes, unexplained_infertility excluded.
+var knownReasons = []string{"tubal_factor", "male_factor_infertility", "endometriosis", "ovulatory_disorder",
+	"diminished_ovarian_reserve", "uterine_factor", "other_reason"}
+
+// Input is the calculator input read from a FHIR resource.
+type Input struct {
+	// Values are the /calculate query parameters.
+	Values url.Values
+	// Subject is the patient the prediction is about.
+	Subject Reference
+	// Basis references the resources the values were read from.
+	Basis []Reference
+}
+
+// ReadInput maps a QuestionnaireResponse, or a Bundle of a Patient with Observation and Condition resources, onto
+// the calculator input. now is the date the age is calculated at from the birth date when the Bundle has no
+// timestamp. Resources that can't be mapped are reported as *ivf.InputError; the values themselves are validated by
+// the prediction model.
+func ReadInput(data []byte, now time.Time) (*Input, error) {
+	var r resource
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, &ivf.InputError{Message: fmt.Sprintf("invalid FHIR resource: %v", err)}
+	}
+	switch r.ResourceType {
+	case "QuestionnaireResponse":
+		var qr QuestionnaireResponse
+		if err := json.Unmarshal(data, &qr); err != nil {
+			return nil, &ivf.InputError{Message: fmt.Sprintf("invalid QuestionnaireResponse: %v", err)}
+		}
+		return readQuestionnaireResponse(&qr)
+	case "Bundle":
+		var b Bundle
+		if err := json.Unmarshal(data, &b); err != nil {
+			return nil, &ivf.InputError{Message: fmt.Sprintf("invalid Bundle: %v", err)}
+		}
+		return readBundle(&b, now)
+	default:
+		return nil, &ivf.InputError{Message: fmt.Sprintf("resourceType must be QuestionnaireResponse or Bundle. Got %q",
+			r.ResourceType)}
+	}
+}
+
+// readQuestionnaireResponse reads the answers of the items whose linkId is a calculator input. A height item with
+// a quantity answer sets feet and inches, and the items the form skipped get their notAsked value.
+func readQuestionnaireResponse(qr *QuestionnaireResponse) (*Input, error) {
+	input := &Input{Values: url.Values{}}
+	if qr.Subject != nil {
+		input.Subject = *qr.Subject
+	}
+	if qr.ID != "" {
+		input.Basis = append(input.Basis, Reference{Reference: "QuestionnaireResponse/" + qr.ID})
+	}
+
+	var read func(items []QuestionnaireResponseItem) error
+	read = func(items []QuestionnaireResponseItem) error {
+		for _, item := range items {
+			if len(item.Answer) > 0 {
+				if err := input.setAnswer(item.LinkID, item.Answer[0]); err != nil {
+					return err
+				}
+			}
+			if err := read(item.Item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := read(qr.Item); err != nil {
+		return nil, err
+	}
+	for name, s := range skipped {
+		if input.Values.Get(name) == "" && input.Values.Get(s.question) == s.answer {
+			input.Values.Set(name, s.notAsked)
+		}
+	}
+	return input, nil
+}
+
+func (input *Input) setAnswer(linkID string, answer QuestionnaireResponseAnswer) error {
+	if answer.ValueQuantity != nil {
+		return input.setQuantity(linkID, answer.ValueQuantity)
+	}
+	if !isParam(linkID) {
+		return nil
+	}
+	switch {
+	case answer.ValueBoolean != nil:
+		input.Values.Set(linkID, yesNo(*answer.ValueBoolean))
+	case answer.ValueInteger != nil:
+		input.Values.Set(linkID, countValue(linkID, *answer.ValueInteger))
+	case answer.ValueDecimal != nil:
+		input.Values.Set(linkID, strconv.FormatFloat(*answer.ValueDecimal, 'f', -1, 64))
+	case answer.ValueString != nil:
+		input.Values.Set(linkID, *answer.ValueString)
+	case answer.ValueCoding != nil:
+		input.Values.Set(linkID, answer.ValueCoding.Code)
+	}
+	return nil
+}
+
+// setQuantity sets the age, weight or height from a quantity, converting the units.
+func (input *Input) setQuantity(param string, q *Quantity) error {
+	unit := q.Code
+	if unit == "" {
+		unit = q.Unit
+	}
+	switch param {
+	case "age":
+		if unit != "a" && unit != "yr" && unit != "years" {
+			return unitError(param, unit)
+		}
+		input.Values.Set("age", strconv.Itoa(int(math.Floor(q.Value))))
+	case "weight":
+		var pounds float64
+		switch unit {
+		case "[lb_av]", "lb", "lbs":
+			pounds = q.Value
+		case "kg":
+			pounds = q.Value * poundsPerKilogram
+		case "g":
+			pounds = q.Value / 1000 * poundsPerKilogram
+		default:
+			return unitError(param, unit)
+		}
+		input.Values.Set("weight", strconv.Itoa(int(math.Round(pounds))))
+	case "height":
+		var inches float64
+		switch unit {
+		case "[in_i]", "in":
+			inches = q.Value
+		case "cm":
+			inches = q.Value / centimetersPerInch
+		case "m":
+			inches = q.Value * 100 / centimetersPerInch
+		default:
+			return unitError(param, unit)
+		}
+		total := int(math.Round(inches))
+		input.Values.Set("feet", strconv.Itoa(total/12))
+		input.Values.Set("inches", strconv.Itoa(total%12))
+	default:
+		if isParam(param) {
+			input.Values.Set(param, strconv.FormatFloat(q.Value, 'f', -1, 64))
+		}
+	}
+	return nil
+}
+
+// readBundle reads the Patient birth date, the coded Observations and the infertility diagnoses. Reasons without a
+// diagnosis are No, and the reason is not known when there is no diagnosis at all.
+func readBundle(b *Bundle, now time.Time) (*Input, error) {
+	input := &Input{Values: url.Values{}}
+	birthDate := ""
+	for i, entry := range b.Entry {
+		var r resource
+		if err := json.Unmarshal(entry.Resource, &r); err != nil {
+			return nil, &ivf.InputError{Message: fmt.Sprintf("Bundle entry %d: %v", i, err)}
+		}
+		var err error
+		switch r.ResourceType {
+		case "Patient":
+			var p Patient
+			if err = json.Unmarshal(entry.Resource, &p); err == nil {
+				birthDate = p.BirthDate
+				if p.ID != "" {
+					input.Subject = Reference{Reference: "Patient/" + p.ID}
+				}
+			}
+		case "Observation":
+			var o Observation
+			if err = json.Unmarshal(entry.Resource, &o); err == nil {
+				err = input.readObservation(&o)
+			}
+		case "Condition":
+			var c Condition
+			if err = json.Unmarshal(entry.Resource, &c); err == nil {
+				input.readCondition(&c)
+			}
+		}
+		if err != nil {
+			if _, ok := err.(*ivf.InputError); ok {
+				return nil, err
+			}
+			return nil, &ivf.InputError{Message: fmt.Sprintf("Bundle entry %d: %v", i, err)}
+		}
+	}
+
+	if input.Values.Get("age") == "" && birthDate != "" {
+		at := now
+		if b.Timestamp != "" {
+			t, err := time.Parse(time.RFC3339, b.Timestamp)
+			if err != nil {
+				return nil, &ivf.InputError{Message: fmt.Sprintf("invalid Bundle timestamp %q", b.Timestamp)}
+			}
+			at = t
+		}
+		age, err := ageAt(birthDate, at)
+		if err != nil {
+			return nil, err
+		}
+		input.Values.Set("age", strconv.Itoa(age))
+	}
+
+	reasonKnown := false
+	for _, reason := range append(knownReasons, "unexplained_infertility") {
+		if input.Values.Get(reason) == "" {
+			input.Values.Set(reason, "No")
+		}
+		reasonKnown = reasonKnown || input.Values.Get(reason) == "Yes"
+	}
+	if input.Values.Get("donotknow") == "" {
+		input.Values.Set("donotknow", yesNo(!reasonKnown))
+	}
+	return input, nil
+}
+
+func (input *Input) readObservation(o *Observation) error {
+	if o.Status == "entered-in-error" || o.Status == "cancelled" {
+		return nil
+	}
+	param := observationParam(o.Code)
+	if param == "" {
+		if o.ValueCodeableConcept != nil && input.readDiagnosis(*o.ValueCodeableConcept) {
+			input.addBasis("Observation", o.ID)
+		}
+		return nil
+	}
+	input.addBasis("Observation", o.ID)
+
+	switch {
+	case o.ValueQuantity != nil:
+		if param == "gravida" || param == "previous_live_births" {
+			input.Values.Set(param, countValue(param, int(math.Round(o.ValueQuantity.Value))))
+			return nil
+		}
+		return input.setQuantity(param, o.ValueQuantity)
+	case o.ValueInteger != nil:
+		input.Values.Set(param, countValue(param, *o.ValueInteger))
+	case o.ValueBoolean != nil:
+		input.Values.Set(param, yesNo(*o.ValueBoolean))
+	case o.ValueString != nil:
+		input.Values.Set(param, *o.ValueString)
+	case o.ValueCodeableConcept != nil && len(o.ValueCodeableConcept.Coding) > 0:
+		input.Values.Set(param, o.ValueCodeableConcept.Coding[0].Code)
+	}
+	return nil
+}
+
+// observationParam returns the calculator input the observation code stands for, empty when there is none.
+func observationParam(code CodeableConcept) string {
+	for _, c := range code.Coding {
+		switch {
+		case c.System == CodeSystem && isParam(c.Code), c.System == CodeSystem && c.Code == "height":
+			return c.Code
+		case c.System != LOINC:
+			continue
+		}
+		switch c.Code {
+		case loincAge:
+			return "age"
+		case loincBodyWeight, loincBodyWeightMeas:
+			return "weight"
+		case loincBodyHeight:
+			return "height"
+		case loincPregnancies:
+			return "gravida"
+		case loincLiveBirths:
+			return "previous_live_births"
+		}
+	}
+	return ""
+}
+
+func (input *Input) readCondition(c *Condition) {
+	if c.VerificationStatus != nil && hasCode(*c.VerificationStatus, "refuted", "entered-in-error") {
+		return
+	}
+	if input.readDiagnosis(c.Code) {
+		input.addBasis("Condition", c.ID)
+	}
+}
+
+// readDiagnosis sets the infertility reason of an ICD-10-CM coded diagnosis and reports whether it had one.
+func (input *Input) readDiagnosis(code CodeableConcept) bool {
+	found := false
+	for _, c := range code.Coding {
+		if c.System != ICD10CM {
+			continue
+		}
+		for _, d := range diagnoses {
+			if strings.HasPrefix(strings.ToUpper(c.Code), d.prefix) {
+				input.Values.Set(d.param, "Yes")
+				found = true
+			}
+		}
+	}
+	return found
+}
+
+func (input *Input) addBasis(resourceType string, id string) {
+	if id != "" {
+		input.Basis = append(input.Basis, Reference{Reference: resourceType + "/" + id})
+	}
+}
+
+// ageAt returns the age in whole years at the date.
+func ageAt(birthDate string, at time.Time) (int, error) {
+	birth, err := time.Parse("2006-01-02", birthDate)
+	if err != nil {
+		return 0, &ivf.InputError{Param: "age", Message: fmt.Sprintf("invalid Patient birthDate %q", birthDate)}
+	}
+	age := at.Year() - birth.Year()
+	if at.Month() < birth.Month() || (at.Month() == birth.Month() && at.Day() < birth.Day()) {
+		age--
+	}
+	return age, nil
+}
+
+// countValue converts a count into the value of the input's answer options, e.g. 3 into 2+ for gravida.
+func countValue(param string, n int) string {
+	value := strconv.Itoa(n)
+	for _, p := range ivf.Params() {
+		if p.Name != param || len(p.Enum) == 0 {
+			continue
+		}
+		last := p.Enum[len(p.Enum)-1]
+		if min, err := strconv.Atoi(strings.TrimSuffix(last, "+")); err == nil && strings.HasSuffix(last, "+") && n >= min {
+			return last
+		}
+	}
+	return value
+}
+
+func isParam(name string) bool {
+	for _, p := range ivf.Params() {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasCode(concept CodeableConcept, codes ...string) bool {
+	for _, c := range concept.Coding {
+		for _, code := range codes {
+			if c.Code == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func unitError(param string, unit string) error {
+	return &ivf.InputError{Param: param, Message: fmt.Sprintf("%s has unsupported unit %q", param, unit)}
+}
+
+func yesNo(v bool) string {
+	if v {
+		return "Yes"
+	}
+	return "No"
+}
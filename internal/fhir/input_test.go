@@ -0,0 +1,126 @@
+package fhir
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readmeExample1 is the first README sample request, which the metric Bundle fixture describes.
+const readmeExample1 = "age=32&weight=150&feet=5&inches=8&ivf_used=0&gravida=1&tubal_factor=No&male_factor_infertility=No" +
+	"&endometriosis=Yes&ovulatory_disorder=Yes&diminished_ovarian_reserve=No&uterine_factor=No&other_reason=No" +
+	"&unexplained_infertility=No&donotknow=No&eggSource=Own&previous_live_births=1"
+
+func readFixture(t *testing.T, name string) *Input {
+	data, err := os.ReadFile("testdata/" + name)
+	require.NoError(t, err)
+	input, err := ReadInput(data, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	return input
+}
+
+func TestReadBundle(t *testing.T) {
+	input := readFixture(t, "bundle_metric.json")
+
+	expected, err := url.ParseQuery(readmeExample1)
+	require.NoError(t, err)
+	assert.Equal(t, expected, input.Values)
+	assert.Equal(t, Reference{Reference: "Patient/patient-1"}, input.Subject)
+	assert.Equal(t, []Reference{
+		{Reference: "Observation/weight"},
+		{Reference: "Observation/height"},
+		{Reference: "Observation/pregnancies"},
+		{Reference: "Observation/births"},
+		{Reference: "Observation/ivf-cycles"},
+		{Reference: "Observation/egg-source"},
+		{Reference: "Condition/endometriosis"},
+		{Reference: "Condition/anovulation"},
+	}, input.Basis, "the refuted diagnosis is not part of the basis")
+}
+
+func TestReadBundleWithoutDiagnosis(t *testing.T) {
+	input, err := ReadInput([]byte(`{"resourceType": "Bundle", "entry": [
+		{"resource": {"resourceType": "Patient", "birthDate": "1990-06-15"}},
+		{"resource": {"resourceType": "Observation", "status": "final",
+			"code": {"coding": [{"system": "http://loinc.org", "code": "11996-6"}]}, "valueInteger": 3}},
+		{"resource": {"resourceType": "Observation", "status": "entered-in-error",
+			"code": {"coding": [{"system": "http://loinc.org", "code": "30525-0"}]},
+			"valueQuantity": {"value": 20, "code": "a"}}}
+	]}`), time.Date(2024, 6, 14, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	assert.Equal(t, "33", input.Values.Get("age"), "the age is calculated at now without a Bundle timestamp")
+	assert.Equal(t, "2+", input.Values.Get("gravida"))
+	assert.Equal(t, "Yes", input.Values.Get("donotknow"))
+	assert.Equal(t, "No", input.Values.Get("unexplained_infertility"))
+	assert.Equal(t, "No", input.Values.Get("tubal_factor"))
+	assert.Empty(t, input.Subject.Reference)
+}
+
+func TestReadQuestionnaireResponse(t *testing.T) {
+	input := readFixture(t, "questionnaire_response.json")
+
+	assert.Equal(t, url.Values{
+		"age":                        {"32"},
+		"weight":                     {"150"},
+		"feet":                       {"5"},
+		"inches":                     {"8"},
+		"ivf_used":                   {"3+"},
+		"gravida":                    {"1"},
+		"previous_live_births":       {"1"},
+		"tubal_factor":               {"Yes"},
+		"male_factor_infertility":    {"No"},
+		"endometriosis":              {"No"},
+		"ovulatory_disorder":         {"No"},
+		"diminished_ovarian_reserve": {"Yes"},
+		"uterine_factor":             {"No"},
+		"other_reason":               {"No"},
+		"unexplained_infertility":    {"No"},
+		"donotknow":                  {"No"},
+		"eggSource":                  {"Donor"},
+	}, input.Values, "nested items are read and unknown linkIds are ignored")
+	assert.Equal(t, Reference{Reference: "Patient/patient-2"}, input.Subject)
+	assert.Equal(t, []Reference{{Reference: "QuestionnaireResponse/intake-1"}}, input.Basis)
+
+	t.Run("Skipped item", func(t *testing.T) {
+		input, err := ReadInput([]byte(`{"resourceType": "QuestionnaireResponse", "item": [
+			{"linkId": "eggSource", "answer": [{"valueCoding": {"code": "Donor"}}]}]}`), time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, url.Values{"eggSource": {"Donor"}, "ivf_used": {"0"}}, input.Values,
+			"ivf_used isn't asked with donor eggs")
+	})
+}
+
+func TestReadInputErrors(t *testing.T) {
+	data, err := os.ReadFile("testdata/bundle_invalid_unit.json")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		data    string
+		param   string
+		message string
+	}{
+		{"unsupported unit", string(data), "weight", `weight has unsupported unit "[stone_av]"`},
+		{"other resource", `{"resourceType": "Patient"}`, "", `resourceType must be QuestionnaireResponse or Bundle. Got "Patient"`},
+		{"invalid birth date", `{"resourceType": "Bundle", "entry": [{"resource": {"resourceType": "Patient", "birthDate": "1990"}}]}`,
+			"age", `invalid Patient birthDate "1990"`},
+		{"not JSON", `<Bundle/>`, "", "invalid FHIR resource: invalid character '<' looking for beginning of value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadInput([]byte(tt.data), time.Now())
+			var inputErr *ivf.InputError
+			require.True(t, errors.As(err, &inputErr), "%v is not an input error", err)
+			assert.Equal(t, tt.param, inputErr.Param)
+			assert.Equal(t, tt.message, inputErr.Message)
+		})
+	}
+}
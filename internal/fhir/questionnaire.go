@@ -0,0 +1,149 @@
+package fhir
+
+import (
+	"fmt"
+	"strings"
+
+	"ivf_calculator/pkg/ivf"
+)
+
+// QuestionnaireID is the id of the calculator intake Questionnaire, and QuestionnaireURL its canonical URL.
+const (
+	QuestionnaireID  = "ivf-success"
+	QuestionnaireURL = "http://sunfish.example.com/fhir/Questionnaire/" + QuestionnaireID
+)
+
+// Extensions of the Questionnaire items and constraints.
+const (
+	minValueExtension   = "http://hl7.org/fhir/StructureDefinition/minValue"
+	maxValueExtension   = "http://hl7.org/fhir/StructureDefinition/maxValue"
+	constraintExtension = "http://hl7.org/fhir/StructureDefinition/questionnaire-constraint"
+)
+
+// settings are the inputs that configure the calculation rather than describe the patient. They are not asked.
+var settings = map[string]bool{"precision": true}
+
+// skipped are the inputs the form doesn't ask for some answers of another question. ParseInput still requires them
+// and ignores their value then, so readQuestionnaireResponse answers them with notAsked.
+var skipped = map[string]struct {
+	question string
+	answer   string
+	notAsked string
+}{
+	// donor eggs don't depend on prior IVF cycles
+	"ivf_used": {question: "eggSource", answer: ivf.EggSourceDonor, notAsked: "0"},
+}
+
+// NewQuestionnaire returns the intake form of the CDC model as a Questionnaire. The items, answer options and
+// constraints are derived from the rules of ivf.ParseInput, the enableWhen rules from skipped, and every item linkId is the input name, so a
+// QuestionnaireResponse to it can be posted to the RiskAssessment endpoint as is.
+func NewQuestionnaire() *Questionnaire {
+	q := &Questionnaire{
+		ResourceType: "Questionnaire",
+		ID:           QuestionnaireID,
+		URL:          QuestionnaireURL,
+		Name:         "IVFSuccessIntake",
+		Title:        "IVF success calculator intake",
+		Status:       "active",
+		SubjectType:  []string{"Patient"},
+	}
+
+	choices := ivf.Choices()
+	groups := map[string]int{}
+	for _, p := range ivf.Params() {
+		if settings[p.Name] {
+			continue
+		}
+		item := questionnaireItem(p)
+		choice := choiceOf(choices, p.Name)
+		if choice == nil {
+			q.Item = append(q.Item, item)
+			continue
+		}
+		i, ok := groups[choice.Name]
+		if !ok {
+			q.Item = append(q.Item, QuestionnaireItem{LinkID: choice.Name, Text: choice.Description, Type: "group",
+				Required: true})
+			i = len(q.Item) - 1
+			groups[choice.Name] = i
+		}
+		q.Item[i].Item = append(q.Item[i].Item, item)
+	}
+
+	for _, c := range choices {
+		options := make([]string, len(c.Options))
+		for i, option := range c.Options {
+			options[i] = fmt.Sprintf("descendants().where(%s and answer.value.code = 'Yes').exists().toInteger()",
+				linkIDIn(option))
+		}
+		q.Extension = append(q.Extension, constraint(c.Name, c.Description, strings.Join(options, " + ")+" = 1"))
+	}
+	for _, p := range ivf.Params() {
+		if p.AtMost != "" {
+			q.Extension = append(q.Extension, constraint(p.Name+"-at-most-"+p.AtMost,
+				fmt.Sprintf("%s can't be greater than %s.", p.Name, p.AtMost),
+				fmt.Sprintf("%s <= %s", answerCode(p.Name), answerCode(p.AtMost))))
+		}
+	}
+	return q
+}
+
+// questionnaireItem asks the input. Inputs with an enum are choices coded in CodeSystem.
+func questionnaireItem(p ivf.Param) QuestionnaireItem {
+	item := QuestionnaireItem{LinkID: p.Name, Text: p.Description, Type: "string", Required: p.Required}
+	switch {
+	case len(p.Enum) > 0:
+		item.Type = "choice"
+		for _, v := range p.Enum {
+			item.AnswerOption = append(item.AnswerOption,
+				QuestionnaireItemAnswerOption{ValueCoding: Coding{System: CodeSystem, Code: v, Display: v}})
+		}
+	case p.Type == "integer":
+		item.Type = "integer"
+		if p.Minimum != nil {
+			item.Extension = append(item.Extension, Extension{URL: minValueExtension, ValueInteger: p.Minimum})
+		}
+		if p.Maximum != nil {
+			item.Extension = append(item.Extension, Extension{URL: maxValueExtension, ValueInteger: p.Maximum})
+		}
+	}
+	if s, ok := skipped[p.Name]; ok {
+		item.EnableWhen = []QuestionnaireItemEnableWhen{{Question: s.question, Operator: "!=",
+			AnswerCoding: &Coding{System: CodeSystem, Code: s.answer}}}
+	}
+	return item
+}
+
+func choiceOf(choices []ivf.Choice, name string) *ivf.Choice {
+	for i, c := range choices {
+		for _, option := range c.Options {
+			for _, n := range option {
+				if n == name {
+					return &choices[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// constraint returns the questionnaire-constraint extension of the FHIRPath expression.
+func constraint(key string, human string, expression string) Extension {
+	return Extension{URL: constraintExtension, Extension: []Extension{
+		{URL: "key", ValueID: key},
+		{URL: "severity", ValueCode: "error"},
+		{URL: "expression", ValueString: &expression},
+		{URL: "human", ValueString: &human},
+	}}
+}
+
+func linkIDIn(names []string) string {
+	if len(names) == 1 {
+		return fmt.Sprintf("linkId = '%s'", names[0])
+	}
+	return fmt.Sprintf("linkId in ('%s')", strings.Join(names, "' | '"))
+}
+
+func answerCode(name string) string {
+	return fmt.Sprintf("descendants().where(linkId = '%s').answer.value.code", name)
+}
@@ -0,0 +1,99 @@
+package fhir
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewQuestionnaire(t *testing.T) {
+	q := NewQuestionnaire()
+	assert.Equal(t, QuestionnaireURL, q.URL)
+
+	items := map[string]QuestionnaireItem{}
+	var linkIDs []string
+	for _, item := range q.Item {
+		if item.Type == "group" {
+			assert.Equal(t, "infertility_reason", item.LinkID)
+			for _, child := range item.Item {
+				items[child.LinkID] = child
+				linkIDs = append(linkIDs, child.LinkID)
+			}
+			continue
+		}
+		items[item.LinkID] = item
+		linkIDs = append(linkIDs, item.LinkID)
+	}
+
+	var expected []string
+	for _, p := range ivf.Params() {
+		if p.Name == "precision" {
+			continue
+		}
+		expected = append(expected, p.Name)
+		item := items[p.Name]
+		assert.Equal(t, p.Required, item.Required, "required of %s", p.Name)
+		assert.Len(t, item.AnswerOption, len(p.Enum), "answer options of %s", p.Name)
+	}
+	assert.Equal(t, expected, linkIDs, "every input is asked in the order of the form")
+
+	assert.Equal(t, "choice", items["gravida"].Type)
+	assert.Equal(t, Coding{System: CodeSystem, Code: "2+", Display: "2+"}, items["gravida"].AnswerOption[2].ValueCoding)
+	assert.Equal(t, "integer", items["age"].Type)
+	min, max := 20, 50
+	assert.Equal(t, []Extension{{URL: minValueExtension, ValueInteger: &min}, {URL: maxValueExtension, ValueInteger: &max}},
+		items["age"].Extension)
+	assert.Equal(t, []QuestionnaireItemEnableWhen{{Question: "eggSource", Operator: "!=",
+		AnswerCoding: &Coding{System: CodeSystem, Code: "Donor"}}}, items["ivf_used"].EnableWhen)
+
+	require.Len(t, q.Extension, 2)
+	assert.Equal(t, "descendants().where(linkId in ('tubal_factor' | 'male_factor_infertility' | 'endometriosis' | "+
+		"'ovulatory_disorder' | 'diminished_ovarian_reserve' | 'uterine_factor' | 'other_reason') and "+
+		"answer.value.code = 'Yes').exists().toInteger() + "+
+		"descendants().where(linkId = 'unexplained_infertility' and answer.value.code = 'Yes').exists().toInteger() + "+
+		"descendants().where(linkId = 'donotknow' and answer.value.code = 'Yes').exists().toInteger() = 1",
+		*q.Extension[0].Extension[2].ValueString)
+	assert.Equal(t, "descendants().where(linkId = 'previous_live_births').answer.value.code <= "+
+		"descendants().where(linkId = 'gravida').answer.value.code", *q.Extension[1].Extension[2].ValueString)
+}
+
+// TestQuestionnaireResponseRoundTrip answers the Questionnaire with the README example and reads the answers back.
+func TestQuestionnaireResponseRoundTrip(t *testing.T) {
+	values, err := url.ParseQuery(readmeExample1)
+	require.NoError(t, err)
+
+	var answer func(items []QuestionnaireItem) []QuestionnaireResponseItem
+	answer = func(items []QuestionnaireItem) []QuestionnaireResponseItem {
+		var answered []QuestionnaireResponseItem
+		for _, item := range items {
+			r := QuestionnaireResponseItem{LinkID: item.LinkID, Item: answer(item.Item)}
+			switch item.Type {
+			case "choice":
+				r.Answer = []QuestionnaireResponseAnswer{{ValueCoding: &Coding{System: CodeSystem, Code: values.Get(item.LinkID)}}}
+			case "integer":
+				v, err := strconv.Atoi(values.Get(item.LinkID))
+				require.NoError(t, err)
+				r.Answer = []QuestionnaireResponseAnswer{{ValueInteger: &v}}
+			}
+			answered = append(answered, r)
+		}
+		return answered
+	}
+	qr := QuestionnaireResponse{ResourceType: "QuestionnaireResponse", Questionnaire: QuestionnaireURL,
+		Status: "completed", Item: answer(NewQuestionnaire().Item)}
+	data, err := json.Marshal(qr)
+	require.NoError(t, err)
+
+	input, err := ReadInput(data, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, values, input.Values)
+	_, err = ivf.ParseInput(input.Values)
+	assert.NoError(t, err)
+}
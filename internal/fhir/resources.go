@@ -0,0 +1,192 @@
+package fhir
+
+import "encoding/json"
+
+// The FHIR R4 resources and data types the calculator reads and writes. Only the elements it uses are declared.
+
+// CodeSystem is the system of the codes this calculator defines, e.g. an Observation coded with a calculator input.
+const CodeSystem = "http://sunfish.example.com/fhir/CodeSystem/ivf-calculator"
+
+// FormulaCodeSystem is the system of the CDC formula ids a RiskAssessment method references.
+const FormulaCodeSystem = "http://sunfish.example.com/fhir/CodeSystem/cdc-formula"
+
+// Code systems of the coded observations and diagnoses.
+const (
+	LOINC   = "http://loinc.org"
+	ICD10CM = "http://hl7.org/fhir/sid/icd-10-cm"
+	UCUM    = "http://unitsofmeasure.org"
+)
+
+// MimeType is the media type of FHIR JSON resources.
+const MimeType = "application/fhir+json"
+
+type Coding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+type CodeableConcept struct {
+	Coding []Coding `json:"coding,omitempty"`
+	Text   string   `json:"text,omitempty"`
+}
+
+type Reference struct {
+	Reference string `json:"reference,omitempty"`
+	Display   string `json:"display,omitempty"`
+}
+
+type Quantity struct {
+	Value  float64 `json:"value"`
+	Unit   string  `json:"unit,omitempty"`
+	System string  `json:"system,omitempty"`
+	Code   string  `json:"code,omitempty"`
+}
+
+type Annotation struct {
+	Text string `json:"text"`
+}
+
+// resource holds the elements shared by all resources.
+type resource struct {
+	ResourceType string `json:"resourceType"`
+	ID           string `json:"id,omitempty"`
+}
+
+type Bundle struct {
+	ResourceType string        `json:"resourceType"`
+	ID           string        `json:"id,omitempty"`
+	Type         string        `json:"type,omitempty"`
+	Timestamp    string        `json:"timestamp,omitempty"`
+	Entry        []BundleEntry `json:"entry,omitempty"`
+}
+
+type BundleEntry struct {
+	FullURL  string          `json:"fullUrl,omitempty"`
+	Resource json.RawMessage `json:"resource"`
+}
+
+type Patient struct {
+	ResourceType string `json:"resourceType"`
+	ID           string `json:"id,omitempty"`
+	BirthDate    string `json:"birthDate,omitempty"`
+}
+
+type Observation struct {
+	ResourceType         string           `json:"resourceType"`
+	ID                   string           `json:"id,omitempty"`
+	Status               string           `json:"status,omitempty"`
+	Code                 CodeableConcept  `json:"code"`
+	ValueQuantity        *Quantity        `json:"valueQuantity,omitempty"`
+	ValueCodeableConcept *CodeableConcept `json:"valueCodeableConcept,omitempty"`
+	ValueString          *string          `json:"valueString,omitempty"`
+	ValueBoolean         *bool            `json:"valueBoolean,omitempty"`
+	ValueInteger         *int             `json:"valueInteger,omitempty"`
+}
+
+// Condition is read as an infertility diagnosis.
+type Condition struct {
+	ResourceType       string           `json:"resourceType"`
+	ID                 string           `json:"id,omitempty"`
+	ClinicalStatus     *CodeableConcept `json:"clinicalStatus,omitempty"`
+	VerificationStatus *CodeableConcept `json:"verificationStatus,omitempty"`
+	Code               CodeableConcept  `json:"code"`
+}
+
+type QuestionnaireResponse struct {
+	ResourceType  string                      `json:"resourceType"`
+	ID            string                      `json:"id,omitempty"`
+	Questionnaire string                      `json:"questionnaire,omitempty"`
+	Status        string                      `json:"status,omitempty"`
+	Subject       *Reference                  `json:"subject,omitempty"`
+	Item          []QuestionnaireResponseItem `json:"item,omitempty"`
+}
+
+type QuestionnaireResponseItem struct {
+	LinkID string                        `json:"linkId"`
+	Answer []QuestionnaireResponseAnswer `json:"answer,omitempty"`
+	Item   []QuestionnaireResponseItem   `json:"item,omitempty"`
+}
+
+type QuestionnaireResponseAnswer struct {
+	ValueBoolean  *bool     `json:"valueBoolean,omitempty"`
+	ValueInteger  *int      `json:"valueInteger,omitempty"`
+	ValueDecimal  *float64  `json:"valueDecimal,omitempty"`
+	ValueString   *string   `json:"valueString,omitempty"`
+	ValueCoding   *Coding   `json:"valueCoding,omitempty"`
+	ValueQuantity *Quantity `json:"valueQuantity,omitempty"`
+}
+
+type RiskAssessment struct {
+	ResourceType       string                     `json:"resourceType"`
+	Status             string                     `json:"status"`
+	Method             *CodeableConcept           `json:"method,omitempty"`
+	Code               *CodeableConcept           `json:"code,omitempty"`
+	Subject            Reference                  `json:"subject"`
+	OccurrenceDateTime string                     `json:"occurrenceDateTime,omitempty"`
+	Basis              []Reference                `json:"basis,omitempty"`
+	Prediction         []RiskAssessmentPrediction `json:"prediction"`
+	Note               []Annotation               `json:"note,omitempty"`
+}
+
+type RiskAssessmentPrediction struct {
+	Outcome            *CodeableConcept `json:"outcome,omitempty"`
+	ProbabilityDecimal float64          `json:"probabilityDecimal"`
+	Rationale          string           `json:"rationale,omitempty"`
+}
+
+type OperationOutcome struct {
+	ResourceType string                  `json:"resourceType"`
+	Issue        []OperationOutcomeIssue `json:"issue"`
+}
+
+type OperationOutcomeIssue struct {
+	Severity    string   `json:"severity"`
+	Code        string   `json:"code"`
+	Diagnostics string   `json:"diagnostics,omitempty"`
+	Expression  []string `json:"expression,omitempty"`
+}
+
+// Extension carries the elements FHIR R4 has no field for, e.g. the minimum value of a Questionnaire item.
+type Extension struct {
+	URL          string      `json:"url"`
+	ValueInteger *int        `json:"valueInteger,omitempty"`
+	ValueString  *string     `json:"valueString,omitempty"`
+	ValueCode    string      `json:"valueCode,omitempty"`
+	ValueID      string      `json:"valueId,omitempty"`
+	Extension    []Extension `json:"extension,omitempty"`
+}
+
+type Questionnaire struct {
+	ResourceType string              `json:"resourceType"`
+	ID           string              `json:"id,omitempty"`
+	Extension    []Extension         `json:"extension,omitempty"`
+	URL          string              `json:"url,omitempty"`
+	Name         string              `json:"name,omitempty"`
+	Title        string              `json:"title,omitempty"`
+	Status       string              `json:"status"`
+	SubjectType  []string            `json:"subjectType,omitempty"`
+	Item         []QuestionnaireItem `json:"item,omitempty"`
+}
+
+type QuestionnaireItem struct {
+	Extension      []Extension                     `json:"extension,omitempty"`
+	LinkID         string                          `json:"linkId"`
+	Text           string                          `json:"text,omitempty"`
+	Type           string                          `json:"type"`
+	EnableWhen     []QuestionnaireItemEnableWhen   `json:"enableWhen,omitempty"`
+	EnableBehavior string                          `json:"enableBehavior,omitempty"`
+	Required       bool                            `json:"required,omitempty"`
+	AnswerOption   []QuestionnaireItemAnswerOption `json:"answerOption,omitempty"`
+	Item           []QuestionnaireItem             `json:"item,omitempty"`
+}
+
+type QuestionnaireItemEnableWhen struct {
+	Question     string  `json:"question"`
+	Operator     string  `json:"operator"`
+	AnswerCoding *Coding `json:"answerCoding,omitempty"`
+}
+
+type QuestionnaireItemAnswerOption struct {
+	ValueCoding Coding `json:"valueCoding"`
+}
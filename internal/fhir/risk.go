@@ -0,0 +1,69 @@
+package fhir
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"ivf_calculator/pkg/ivf"
+)
+
+// NewRiskAssessment returns the prediction as a RiskAssessment of the input's subject. The method is coded with the
+// prediction model and the CDC formula the success rate was calculated with.
+func NewRiskAssessment(result *ivf.Result, input *Input, at time.Time) *RiskAssessment {
+	method := &CodeableConcept{
+		Coding: []Coding{{System: CodeSystem, Code: result.Model, Display: "Prediction model " + result.Model}},
+	}
+	rationale := fmt.Sprintf("Calculated with the %s prediction model", result.Model)
+	if result.CDCFormula != "" {
+		method.Coding = append(method.Coding, Coding{System: FormulaCodeSystem, Code: result.CDCFormula,
+			Display: "CDC formula " + result.CDCFormula})
+		rationale += " and the CDC formula " + result.CDCFormula
+	}
+	method.Text = rationale
+
+	subject := input.Subject
+	if subject.Reference == "" && subject.Display == "" {
+		subject.Display = "Unidentified patient"
+	}
+
+	ra := &RiskAssessment{
+		ResourceType:       "RiskAssessment",
+		Status:             "final",
+		Method:             method,
+		Code:               &CodeableConcept{Text: "Chance of a live birth with one IVF cycle"},
+		Subject:            subject,
+		OccurrenceDateTime: at.UTC().Format(time.RFC3339),
+		Basis:              input.Basis,
+		Prediction: []RiskAssessmentPrediction{{
+			Outcome:            &CodeableConcept{Text: "Live birth"},
+			ProbabilityDecimal: probability(result.SuccessRate),
+			Rationale:          rationale,
+		}},
+	}
+	if result.Interval != nil {
+		ra.Note = append(ra.Note, Annotation{Text: fmt.Sprintf("%g%% confidence interval of the probability: %g to %g",
+			result.Interval.Level*100, probability(result.Interval.Lower), probability(result.Interval.Upper))})
+	}
+	return ra
+}
+
+// probability converts a success rate in percent into a probability, without the float noise of the division.
+func probability(rate float64) float64 {
+	return math.Round(rate*1e6) / 1e8
+}
+
+// NewOperationOutcome reports the error. An *ivf.InputError is an invalid issue located at its input, anything else
+// an exception.
+func NewOperationOutcome(err error) *OperationOutcome {
+	issue := OperationOutcomeIssue{Severity: "error", Code: "exception", Diagnostics: err.Error()}
+	var inputErr *ivf.InputError
+	if errors.As(err, &inputErr) {
+		issue.Code = "invalid"
+		if inputErr.Param != "" {
+			issue.Expression = []string{inputErr.Param}
+		}
+	}
+	return &OperationOutcome{ResourceType: "OperationOutcome", Issue: []OperationOutcomeIssue{issue}}
+}
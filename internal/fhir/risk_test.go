@@ -0,0 +1,43 @@
+package fhir
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRiskAssessment(t *testing.T) {
+	input := &Input{Subject: Reference{Reference: "Patient/1"}, Basis: []Reference{{Reference: "Observation/age"}}}
+	result := &ivf.Result{Model: ivf.CDCModelName, SuccessRate: 62.21, CDCFormula: "1-3",
+		Interval: &ivf.Interval{Lower: 58.3, Upper: 66, Level: 0.95}}
+
+	ra := NewRiskAssessment(result, input, time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC))
+
+	assert.Equal(t, "RiskAssessment", ra.ResourceType)
+	assert.Equal(t, "final", ra.Status)
+	assert.Equal(t, "2024-06-01T09:00:00Z", ra.OccurrenceDateTime)
+	assert.Equal(t, input.Subject, ra.Subject)
+	assert.Equal(t, input.Basis, ra.Basis)
+	assert.Contains(t, ra.Method.Coding, Coding{System: FormulaCodeSystem, Code: "1-3", Display: "CDC formula 1-3"})
+	assert.Equal(t, 0.6221, ra.Prediction[0].ProbabilityDecimal)
+	assert.Equal(t, []Annotation{{Text: "95% confidence interval of the probability: 0.583 to 0.66"}}, ra.Note)
+
+	ra = NewRiskAssessment(&ivf.Result{Model: "amh", SuccessRate: 40}, &Input{}, time.Now())
+	assert.Len(t, ra.Method.Coding, 1, "models without a CDC formula only code the model")
+	assert.Equal(t, "Unidentified patient", ra.Subject.Display)
+	assert.Empty(t, ra.Note)
+}
+
+func TestNewOperationOutcome(t *testing.T) {
+	outcome := NewOperationOutcome(&ivf.InputError{Param: "age", Message: "age must be between 20 and 50"})
+	assert.Equal(t, []OperationOutcomeIssue{{Severity: "error", Code: "invalid",
+		Diagnostics: "age must be between 20 and 50", Expression: []string{"age"}}}, outcome.Issue)
+
+	outcome = NewOperationOutcome(errors.New("no formula"))
+	assert.Equal(t, []OperationOutcomeIssue{{Severity: "error", Code: "exception", Diagnostics: "no formula"}},
+		outcome.Issue)
+}
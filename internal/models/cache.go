@@ -0,0 +1,14 @@
+package models
+
+// CacheStats reports the use of the calculation result cache.
+type CacheStats struct {
+	Size     int `json:"size"`
+	Capacity int `json:"capacity"`
+	Hits     int `json:"hits"`
+	Misses   int `json:"misses"`
+	// HitRate is Hits over the number of lookups, 0 before the first lookup.
+	HitRate   float64 `json:"hit_rate"`
+	Evictions int     `json:"evictions"`
+	// Invalidations counts the times the cache was emptied because the formulas changed.
+	Invalidations int `json:"invalidations"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"ivf_calculator/pkg/ivf"
+)
+
+// Calculation is a prediction as it was shown, stored in the calculation history.
+type Calculation struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Model     string    `json:"model"`
+	// Inputs are the normalized inputs the model read.
+	Inputs map[string]string `json:"inputs"`
+	// FormulaVersion identifies the formulas the prediction was calculated with.
+	FormulaVersion string           `json:"formula_version,omitempty"`
+	CDCFormula     string           `json:"cdc_formula,omitempty"`
+	SuccessRate    float64          `json:"success_rate"`
+	Precision      ivf.Precision    `json:"precision,omitempty"`
+	Interval       *ivf.Interval    `json:"confidence_interval,omitempty"`
+	Explanation    *ivf.Explanation `json:"explanation,omitempty"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "errors"
+
+// ErrNotFound is returned when a formula set or version doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is returned when an action doesn't apply to the current state, e.g. a rollback without an older version.
+var ErrConflict = errors.New("conflict")
+
+// FormulaVersion describes a stored version of a formula set.
+type FormulaVersion struct {
+	Version int `json:"version"`
+	// Source is where the formulas come from, e.g. the uploaded file name.
+	Source    string `json:"source"`
+	CreatedAt string `json:"created_at"`
+	Formulas  int    `json:"formulas"`
+	Active    bool   `json:"active"`
+}
+
+// AuditEntry records one admin action.
+type AuditEntry struct {
+	ID         int64  `json:"id"`
+	At         string `json:"at"`
+	Actor      string `json:"actor"`
+	Action     string `json:"action"`
+	FormulaSet string `json:"formula_set"`
+	Version    int    `json:"version,omitempty"`
+	Details    string `json:"details"`
+}
+
+// ReferenceCase is a patient with a known success rate, used to check formula sets.
+type ReferenceCase struct {
+	Name string `json:"name"`
+	// Query holds the /calculate query parameters of the patient.
+	Query       string  `json:"query"`
+	SuccessRate float64 `json:"success_rate"`
+}
+
+// ReferenceResult is the outcome of a reference case under a candidate formula set.
+type ReferenceResult struct {
+	Name       string  `json:"name"`
+	CDCFormula string  `json:"cdc_formula,omitempty"`
+	Expected   float64 `json:"expected"`
+	// Candidate is the success rate with the candidate formulas, Active the one with the active version.
+	Candidate float64  `json:"candidate"`
+	Active    *float64 `json:"active,omitempty"`
+	Passed    bool     `json:"passed"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// ValidationReport is the outcome of validating a formula set upload.
+type ValidationReport struct {
+	Valid          bool              `json:"valid"`
+	Formulas       int               `json:"formulas"`
+	Errors         []string          `json:"errors"`
+	Warnings       []string          `json:"warnings"`
+	ReferenceCases []ReferenceResult `json:"reference_cases"`
+	// Version is the version the upload was stored as, 0 when it wasn't stored.
+	Version int `json:"version,omitempty"`
+}
@@ -1,13 +1,6 @@
 package models
 
+import "ivf_calculator/pkg/ivf"
+
 // IVFInput holds the calculator request input.
-type IVFInput struct {
-	Age          int
-	Weight       int
-	Feet         int
-	Inches       int
-	IVFUsed      string
-	Coefficients map[string]interface{}
-	ReasonKnown  string
-	UseOwnEggs   string
-}
+type IVFInput = ivf.Input
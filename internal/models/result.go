@@ -0,0 +1,6 @@
+package models
+
+import "ivf_calculator/pkg/ivf"
+
+// IVFResult holds the calculated success rate and the formula used to calculate it.
+type IVFResult = ivf.Result
@@ -0,0 +1,34 @@
+package models
+
+// ShadowReport summarizes how the success rates of a candidate formula set differ from the active ones.
+type ShadowReport struct {
+	// Candidate describes the candidate formula set, e.g. its file or version.
+	Candidate string `json:"candidate"`
+	Since     string `json:"since"`
+	Requests  int    `json:"requests"`
+	// Errors counts the requests the candidate couldn't calculate.
+	Errors   int             `json:"errors"`
+	Formulas []ShadowFormula `json:"formulas"`
+}
+
+// ShadowFormula is the distribution of the differences, candidate minus active in percentage points, of the requests
+// the active formula calculated.
+type ShadowFormula struct {
+	CDCFormula             string  `json:"cdc_formula"`
+	Requests               int     `json:"requests"`
+	MeanDifference         float64 `json:"mean_difference"`
+	MeanAbsoluteDifference float64 `json:"mean_absolute_difference"`
+	StdDevDifference       float64 `json:"stddev_difference"`
+	MinDifference          float64 `json:"min_difference"`
+	MaxDifference          float64 `json:"max_difference"`
+	// CandidateFormulas counts the candidate formulas used, which differ from CDCFormula when the candidate set
+	// selects formulas differently.
+	CandidateFormulas map[string]int `json:"candidate_formulas"`
+	Histogram         []ShadowBucket `json:"histogram"`
+}
+
+// ShadowBucket counts the differences in a range of percentage points.
+type ShadowBucket struct {
+	Range string `json:"range"`
+	Count int    `json:"count"`
+}
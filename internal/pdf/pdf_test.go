@@ -0,0 +1,101 @@
+package pdf
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWidths(t *testing.T) {
+	assert.Len(t, helveticaWidths, 0xff-' '+1)
+	assert.Len(t, helveticaBoldWidths, 0xff-' '+1)
+	assert.InDelta(t, 5.56, TextWidth("0", Helvetica, 10), 1e-9)
+	assert.InDelta(t, 6.11, TextWidth("b", HelveticaBold, 10), 1e-9)
+	assert.InDelta(t, 5.56, TextWidth("é", Helvetica, 10), 1e-9)
+	assert.InDelta(t, 10, TextWidth("—", HelveticaBold, 10), 1e-9)
+	assert.Equal(t, TextWidth("?", Helvetica, 10), TextWidth("✓", Helvetica, 10), "other characters count as ?")
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+		err  string
+	}{
+		{"ASCII", "Age 32", "Age 32", ""},
+		{"delimiters", `(a\b)`, `\(a\\b\)`, ""},
+		{"Latin-1", "Élodie", `\311lodie`, ""},
+		{"windows-1252", "€ – ’", `\200 \226 \222`, ""},
+		{"no WinAnsi code", "ok ✓", "", `pdf: '✓' has no WinAnsi code`},
+		{"control character", "a\tb", "", `pdf: '\t' has no WinAnsi code`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := escape(tt.s)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				assert.ErrorIs(t, err, ErrNotWinAnsi)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	lines := Wrap("one two three four", Helvetica, 10, TextWidth("one two", Helvetica, 10))
+	assert.Equal(t, []string{"one two", "three", "four"}, lines)
+	assert.Equal(t, []string{"unbreakable"}, Wrap("unbreakable", Helvetica, 10, 1))
+	assert.Empty(t, Wrap("  ", Helvetica, 10, 100))
+}
+
+func TestWriteTo(t *testing.T) {
+	d := New()
+	d.Text(72, 72, HelveticaBold, 12, `Risk (50\50)`)
+	d.Line(72, 80, 540, 80, 0.5)
+	d.AddPage()
+	d.FillRect(72, 72, 100, 20, 0.9)
+	assert.Equal(t, 2, d.Pages())
+
+	var out bytes.Buffer
+	_, err := d.WriteTo(&out)
+	require.NoError(t, err)
+	data := out.Bytes()
+
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(data, []byte("%%EOF\n")))
+	assert.Contains(t, string(data), "BT /F2 12 Tf 72 720 Td (Risk \\(50\\\\50\\)) Tj ET")
+	assert.Contains(t, string(data), "0.5 w 72 712 m 540 712 l S")
+	assert.Contains(t, string(data), "0.9 g 72 700 100 20 re f 0 g")
+	assert.Contains(t, string(data), "/Kids [5 0 R 7 0 R] /Count 2")
+
+	// every cross-reference entry points at its object
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+	require.NotNil(t, startxref)
+	xref, err := strconv.Atoi(string(startxref[1]))
+	require.NoError(t, err)
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1)
+	require.Len(t, entries, 8)
+	for i, entry := range entries {
+		offset, err := strconv.Atoi(string(entry[1]))
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(data[offset:], []byte(strconv.Itoa(i+1)+" 0 obj")), "object %d", i+1)
+	}
+}
+
+func TestWriteToNotWinAnsi(t *testing.T) {
+	d := New()
+	d.Text(72, 72, Helvetica, 12, "Done ✓")
+	d.Text(72, 90, Helvetica, 12, "→")
+
+	var out bytes.Buffer
+	_, err := d.WriteTo(&out)
+	assert.EqualError(t, err, `pdf: '✓' has no WinAnsi code`, "the first text that couldn't be encoded")
+	assert.Zero(t, out.Len())
+}
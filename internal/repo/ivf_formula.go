@@ -1,120 +1,126 @@
 package repo
 
 import (
-	"encoding/csv"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
 
 	"ivf_calculator/internal/models"
-	"ivf_calculator/internal/utils"
+	"ivf_calculator/pkg/ivf"
 )
 
 type Config struct {
 	FilePath string
-	Logger   *log.Logger
+	// CovariancePath is the optional CSV file with the coefficient standard errors or covariances.
+	CovariancePath string
+	Logger         *log.Logger
 }
 
 type IVFFormula struct {
 	*Config
+
+	mu sync.Mutex
+	// stamps are the modification times and sizes of the files formulas and version were loaded from.
+	stamps   []fileStamp
+	formulas []*models.Formula
+	version  string
+}
+
+// fileStamp tells whether a file changed since it was read.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
 }
 
 func NewIVFFormula(config *Config) *IVFFormula {
 	return &IVFFormula{
-		config,
+		Config: config,
 	}
 }
 
 // GetFormula reads the CSV file and returns matching formula
 func (f *IVFFormula) GetFormula(usingOwnEggs string, attemptedIVFPreviously string, isReasonKnown string) (*models.Formula, error) {
-	file, err := os.Open(f.FilePath)
+	formulas, err := f.GetFormulas()
 	if err != nil {
-		return nil, fmt.Errorf("error opening file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
+	return ivf.FindFormula(formulas, usingOwnEggs, attemptedIVFPreviously, isReasonKnown)
+}
 
-	// Skip headers
-	_, err = reader.Read()
-	if err != nil {
-		return nil, fmt.Errorf("error reading headers: %w", err)
+// GetFormulas returns all formulas of the CSV file. The file is read again only when it changed.
+func (f *IVFFormula) GetFormulas() ([]*models.Formula, error) {
+	formulas, _, err := f.load()
+	return formulas, err
+}
+
+// FormulaVersion identifies the content of the formula and covariance files, e.g. ivf_success_formulas.csv@1a2b3c4d5e6f.
+// It is hashed when the files are loaded and changes when they are edited.
+func (f *IVFFormula) FormulaVersion() (string, error) {
+	_, version, err := f.load()
+	return version, err
+}
+
+// load returns the formulas and the version of the files, reading them only when their modification time or size
+// changed since the last load.
+func (f *IVFFormula) load() ([]*models.Formula, string, error) {
+	paths := []string{f.FilePath, f.CovariancePath}
+	stamps := make([]fileStamp, len(paths))
+	for i, path := range paths {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("error opening file: %w", err)
+		}
+		stamps[i] = fileStamp{modTime: info.ModTime(), size: info.Size()}
 	}
 
-	records, err := reader.ReadAll()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.formulas != nil && slices.Equal(stamps, f.stamps) {
+		return f.formulas, f.version, nil
+	}
+
+	data, err := os.ReadFile(f.FilePath)
 	if err != nil {
-		return nil, fmt.Errorf("error reading records: %w", err)
+		return nil, "", fmt.Errorf("error opening file: %w", err)
+	}
+	hash := sha256.New()
+	hash.Write(data)
+	var formulas []*models.Formula
+	if filepath.Ext(f.FilePath) == ".json" {
+		formulas, err = ivf.ReadFormulaDefinitions(bytes.NewReader(data))
+	} else {
+		formulas, err = ivf.ReadFormulas(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, "", err
 	}
 
-	for _, record := range records {
-		formula := models.Formula{}
-		if usingOwnEggs == record[0] && attemptedIVFPreviously == record[1] && isReasonKnown == record[2] {
-
-			// Parse boolean fields
-			formula.UsingOwnEggs = usingOwnEggs
-			formula.AttemptedIVFPreviously = attemptedIVFPreviously
-			formula.IsReasonForInfertilityKnown = isReasonKnown
-
-			formula.CDCFormula = record[3]
-
-			// Parse coefficients
-			formula.Coefficients.Intercept = utils.ParseFloat(record[4])
-			formula.Coefficients.AgeLinear = utils.ParseFloat(record[5])
-			formula.Coefficients.AgePower = utils.ParseFloat(record[6])
-			formula.Coefficients.AgePowerFactor = utils.ParseFloat(record[7])
-			formula.Coefficients.BMILinear = utils.ParseFloat(record[8])
-			formula.Coefficients.BMIPower = utils.ParseFloat(record[9])
-			formula.Coefficients.BMIPowerFactor = utils.ParseFloat(record[10])
-
-			// Initialize maps for boolean coefficients
-			formula.Coefficients.TubalFactor = map[bool]float64{
-				true:  utils.ParseFloat(record[11]),
-				false: utils.ParseFloat(record[12]),
-			}
-			formula.Coefficients.MaleFactorInfertility = map[bool]float64{
-				true:  utils.ParseFloat(record[13]),
-				false: utils.ParseFloat(record[14]),
-			}
-			formula.Coefficients.Endometriosis = map[bool]float64{
-				true:  utils.ParseFloat(record[15]),
-				false: utils.ParseFloat(record[16]),
-			}
-			formula.Coefficients.OvulatoryDisorder = map[bool]float64{
-				true:  utils.ParseFloat(record[17]),
-				false: utils.ParseFloat(record[18]),
-			}
-			formula.Coefficients.DiminishedOvarianReserve = map[bool]float64{
-				true:  utils.ParseFloat(record[19]),
-				false: utils.ParseFloat(record[20]),
-			}
-			formula.Coefficients.UterineFactor = map[bool]float64{
-				true:  utils.ParseFloat(record[21]),
-				false: utils.ParseFloat(record[22]),
-			}
-			formula.Coefficients.OtherReason = map[bool]float64{
-				true:  utils.ParseFloat(record[23]),
-				false: utils.ParseFloat(record[24]),
-			}
-			formula.Coefficients.UnexplainedInfertility = map[bool]float64{
-				true:  utils.ParseFloat(record[25]),
-				false: utils.ParseFloat(record[26]),
-			}
-
-			// Initialize maps for numeric coefficients
-			formula.Coefficients.PriorPregnancies = map[string]float64{
-				"0":  utils.ParseFloat(record[27]),
-				"1":  utils.ParseFloat(record[28]),
-				"2+": utils.ParseFloat(record[29]),
-			}
-			formula.Coefficients.PriorLiveBirths = map[string]float64{
-				"0":  utils.ParseFloat(record[30]),
-				"1":  utils.ParseFloat(record[31]),
-				"2+": utils.ParseFloat(record[32]),
-			}
-
-			return &formula, nil
+	if f.CovariancePath != "" {
+		data, err := os.ReadFile(f.CovariancePath)
+		if err != nil {
+			return nil, "", fmt.Errorf("error loading covariance: %w", err)
+		}
+		hash.Write(data)
+		if err := ivf.ReadCovariance(bytes.NewReader(data), formulas); err != nil {
+			return nil, "", fmt.Errorf("error loading covariance: %w", err)
 		}
 	}
 
-	return nil, fmt.Errorf("no matching formula found for the given parameters")
+	f.stamps, f.formulas = stamps, formulas
+	f.version = filepath.Base(f.FilePath) + "@" + hex.EncodeToString(hash.Sum(nil))[:12]
+	for _, formula := range formulas {
+		formula.Version = f.version
+	}
+	return f.formulas, f.version, nil
 }
@@ -0,0 +1,44 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIVFFormulaVersion(t *testing.T) {
+	data, err := os.ReadFile(formulasPath)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "formulas.csv")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	repo := NewIVFFormula(&Config{FilePath: path})
+
+	version, err := repo.FormulaVersion()
+	require.NoError(t, err)
+	assert.Regexp(t, `^formulas\.csv@[0-9a-f]{12}$`, version)
+	formulas, err := repo.GetFormulas()
+	require.NoError(t, err)
+
+	t.Run("Unchanged files are not read again", func(t *testing.T) {
+		again, err := repo.GetFormulas()
+		require.NoError(t, err)
+		assert.Same(t, formulas[0], again[0])
+	})
+
+	t.Run("Edited files are loaded and hashed again", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, append(data, '\n'), 0o600))
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(path, later, later))
+
+		edited, err := repo.FormulaVersion()
+		require.NoError(t, err)
+		assert.NotEqual(t, version, edited)
+		again, err := repo.GetFormulas()
+		require.NoError(t, err)
+		assert.NotSame(t, formulas[0], again[0])
+	})
+}
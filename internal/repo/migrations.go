@@ -0,0 +1,113 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migrations are applied in order and recorded in schema_migrations. Never edit an applied migration, add a new one.
+var migrations = []string{
+	// 1: formula sets, their versions and the formula terms of every version
+	`CREATE TABLE formula_sets (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		active_version_id INTEGER REFERENCES formula_set_versions (id),
+		created_at TEXT NOT NULL
+	);
+	CREATE TABLE formula_set_versions (
+		id INTEGER PRIMARY KEY,
+		formula_set_id INTEGER NOT NULL REFERENCES formula_sets (id),
+		version INTEGER NOT NULL,
+		source TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		UNIQUE (formula_set_id, version)
+	);
+	CREATE TABLE formulas (
+		id INTEGER PRIMARY KEY,
+		version_id INTEGER NOT NULL REFERENCES formula_set_versions (id),
+		cdc_formula TEXT NOT NULL,
+		using_own_eggs TEXT NOT NULL,
+		attempted_ivf_previously TEXT NOT NULL,
+		is_reason_for_infertility_known TEXT NOT NULL,
+		UNIQUE (version_id, cdc_formula)
+	);
+	CREATE TABLE formula_terms (
+		formula_id INTEGER NOT NULL REFERENCES formulas (id),
+		position INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		input TEXT NOT NULL,
+		coefficient REAL NOT NULL,
+		exponent REAL NOT NULL,
+		coefficient_name TEXT NOT NULL,
+		PRIMARY KEY (formula_id, position)
+	);
+	CREATE TABLE term_levels (
+		formula_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		level TEXT NOT NULL,
+		coefficient REAL NOT NULL,
+		PRIMARY KEY (formula_id, position, level),
+		FOREIGN KEY (formula_id, position) REFERENCES formula_terms (formula_id, position)
+	);`,
+	// 2: audit trail of the admin actions
+	`CREATE TABLE audit_log (
+		id INTEGER PRIMARY KEY,
+		at TEXT NOT NULL,
+		actor TEXT NOT NULL,
+		action TEXT NOT NULL,
+		formula_set TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		details TEXT NOT NULL
+	);`,
+	// 3: calculation history, the calculation is stored as JSON
+	`CREATE TABLE calculations (
+		id TEXT PRIMARY KEY,
+		created_at TEXT NOT NULL,
+		data TEXT NOT NULL
+	);
+	CREATE INDEX calculations_created_at ON calculations (created_at);`,
+}
+
+// Migrate applies the migrations the database doesn't have yet.
+func Migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("error creating schema_migrations: %w", err)
+	}
+
+	var applied int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&applied); err != nil {
+		return fmt.Errorf("error reading schema_migrations: %w", err)
+	}
+	if applied > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than this build, which knows %d", applied, len(migrations))
+	}
+
+	for i := applied; i < len(migrations); i++ {
+		if err := applyMigration(db, i+1, migrations[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, version int, migration string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error applying migration %d: %w", version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(migration); err != nil {
+		return fmt.Errorf("error applying migration %d: %w", version, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version,
+		time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("error applying migration %d: %w", version, err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,180 @@
+package repo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"ivf_calculator/internal/models"
+)
+
+// Versions lists the versions of the formula set, oldest first.
+func (f *SQLiteFormula) Versions(set string) ([]models.FormulaVersion, error) {
+	rows, err := f.db.Query(`SELECT v.version, v.source, v.created_at, COUNT(f.id), v.id = s.active_version_id
+		FROM formula_sets s JOIN formula_set_versions v ON v.formula_set_id = s.id
+		LEFT JOIN formulas f ON f.version_id = v.id
+		WHERE s.name = ? GROUP BY v.id ORDER BY v.version`, set)
+	if err != nil {
+		return nil, fmt.Errorf("error reading versions of %s: %w", set, err)
+	}
+	defer rows.Close()
+
+	var versions []models.FormulaVersion
+	for rows.Next() {
+		var v models.FormulaVersion
+		var active sql.NullBool
+		if err := rows.Scan(&v.Version, &v.Source, &v.CreatedAt, &v.Formulas, &active); err != nil {
+			return nil, fmt.Errorf("error reading versions of %s: %w", set, err)
+		}
+		v.Active = active.Bool
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading versions of %s: %w", set, err)
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("formula set %s: %w", set, models.ErrNotFound)
+	}
+	return versions, nil
+}
+
+// ActiveVersion returns the active version of the formula set, 0 when no version is active.
+func (f *SQLiteFormula) ActiveVersion(set string) (int, error) {
+	var version sql.NullInt64
+	err := f.db.QueryRow(`SELECT v.version FROM formula_sets s
+		LEFT JOIN formula_set_versions v ON v.id = s.active_version_id WHERE s.name = ?`, set).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("formula set %s: %w", set, models.ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error reading formula set %s: %w", set, err)
+	}
+	return int(version.Int64), nil
+}
+
+// VersionFormulas reads the formulas of a version of the formula set.
+func (f *SQLiteFormula) VersionFormulas(set string, version int) ([]*models.Formula, error) {
+	tx, err := f.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error reading formulas: %w", err)
+	}
+	defer tx.Rollback()
+
+	versionID, err := versionID(tx, set, version)
+	if err != nil {
+		return nil, err
+	}
+	return readVersionFormulas(tx, versionID, fmt.Sprintf("%s@%d", set, version))
+}
+
+// AddVersion stores the formulas as a new, inactive version of the formula set, creating the set when missing.
+func (f *SQLiteFormula) AddVersion(set string, source string, formulas []*models.Formula) (int, error) {
+	tx, err := f.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error storing formulas: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, version, err := insertVersion(tx, set, source, formulas)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error storing formulas: %w", err)
+	}
+	return version, nil
+}
+
+// ActivateVersion makes the version the active version of the formula set.
+func (f *SQLiteFormula) ActivateVersion(set string, version int) error {
+	tx, err := f.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error activating version %d: %w", version, err)
+	}
+	defer tx.Rollback()
+
+	id, err := versionID(tx, set, version)
+	if err != nil {
+		return err
+	}
+	if err := activateVersion(tx, set, id, version); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error activating version %d: %w", version, err)
+	}
+	return nil
+}
+
+// AddAuditEntry appends the entry to the audit trail. ID and At are set by the database.
+func (f *SQLiteFormula) AddAuditEntry(entry *models.AuditEntry) error {
+	entry.At = time.Now().UTC().Format(time.RFC3339Nano)
+	res, err := f.db.Exec(`INSERT INTO audit_log (at, actor, action, formula_set, version, details)
+		VALUES (?, ?, ?, ?, ?, ?)`, entry.At, entry.Actor, entry.Action, entry.FormulaSet, entry.Version, entry.Details)
+	if err != nil {
+		return fmt.Errorf("error writing audit entry: %w", err)
+	}
+	entry.ID, err = res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error writing audit entry: %w", err)
+	}
+	return nil
+}
+
+// AuditLog returns the latest audit entries, newest first.
+func (f *SQLiteFormula) AuditLog(limit int) ([]models.AuditEntry, error) {
+	rows, err := f.db.Query(`SELECT id, at, actor, action, formula_set, version, details FROM audit_log
+		ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error reading audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		if err := rows.Scan(&e.ID, &e.At, &e.Actor, &e.Action, &e.FormulaSet, &e.Version, &e.Details); err != nil {
+			return nil, fmt.Errorf("error reading audit log: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading audit log: %w", err)
+	}
+	return entries, nil
+}
+
+func versionID(tx *sql.Tx, set string, version int) (int64, error) {
+	var id int64
+	err := tx.QueryRow(`SELECT v.id FROM formula_set_versions v JOIN formula_sets s ON s.id = v.formula_set_id
+		WHERE s.name = ? AND v.version = ?`, set, version).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("formula set %s version %d: %w", set, version, models.ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error reading formula set %s version %d: %w", set, version, err)
+	}
+	return id, nil
+}
+
+func activateVersion(tx *sql.Tx, set string, versionID int64, version int) error {
+	if _, err := tx.Exec(`UPDATE formula_sets SET active_version_id = ? WHERE name = ?`, versionID, set); err != nil {
+		return fmt.Errorf("error activating version %d: %w", version, err)
+	}
+	return nil
+}
+
+// LoadReferenceCases reads reference cases from a JSON file: an array of objects with name, query and success_rate.
+func LoadReferenceCases(path string) ([]models.ReferenceCase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	var cases []models.ReferenceCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		return nil, fmt.Errorf("error reading reference cases: %w", err)
+	}
+	return cases, nil
+}
@@ -0,0 +1,351 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/pkg/ivf"
+
+	_ "modernc.org/sqlite"
+)
+
+// DefaultFormulaSet is the name of the formula set imported from the CDC CSV.
+const DefaultFormulaSet = "cdc"
+
+type SQLiteConfig struct {
+	// Path is the SQLite database file. It is created and migrated when missing.
+	Path string
+	// FormulaSet is the name of the formula set to read. Defaults to DefaultFormulaSet.
+	FormulaSet string
+	// CovariancePath is the optional CSV file with the coefficient standard errors or covariances.
+	CovariancePath string
+	Logger         *log.Logger
+}
+
+// SQLiteFormula reads the active version of a formula set from an SQLite database.
+type SQLiteFormula struct {
+	*SQLiteConfig
+	db *sql.DB
+
+	mu sync.Mutex
+	// versionID and covariance are the active version and the covariance file formulas were read from.
+	versionID  int64
+	covariance fileStamp
+	formulas   []*models.Formula
+}
+
+// NewSQLiteFormula opens the database and applies the pending migrations.
+func NewSQLiteFormula(config *SQLiteConfig) (*SQLiteFormula, error) {
+	if config.FormulaSet == "" {
+		config.FormulaSet = DefaultFormulaSet
+	}
+	db, err := openSQLite(config.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteFormula{SQLiteConfig: config, db: db}, nil
+}
+
+// openSQLite opens the database and applies the pending migrations.
+func openSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Close closes the database.
+func (f *SQLiteFormula) Close() error {
+	return f.db.Close()
+}
+
+// GetFormula reads the active formula set and returns the matching formula
+func (f *SQLiteFormula) GetFormula(usingOwnEggs string, attemptedIVFPreviously string, isReasonKnown string) (*models.Formula, error) {
+	formulas, err := f.GetFormulas()
+	if err != nil {
+		return nil, err
+	}
+
+	return ivf.FindFormula(formulas, usingOwnEggs, attemptedIVFPreviously, isReasonKnown)
+}
+
+// GetFormulas returns all formulas of the active version of the formula set. They are read again only when another
+// version is activated or the covariance file changed.
+func (f *SQLiteFormula) GetFormulas() ([]*models.Formula, error) {
+	var covariance fileStamp
+	if f.CovariancePath != "" {
+		info, err := os.Stat(f.CovariancePath)
+		if err != nil {
+			return nil, fmt.Errorf("error loading covariance: %w", err)
+		}
+		covariance = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	tx, err := f.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error reading formulas: %w", err)
+	}
+	defer tx.Rollback()
+
+	versionID, version, err := activeVersion(tx, f.FormulaSet)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.formulas != nil && versionID == f.versionID && covariance == f.covariance {
+		return f.formulas, nil
+	}
+
+	formulas, err := readVersionFormulas(tx, versionID, fmt.Sprintf("%s@%d", f.FormulaSet, version))
+	if err != nil {
+		return nil, err
+	}
+	if f.CovariancePath != "" {
+		if err := ivf.LoadCovariance(f.CovariancePath, formulas); err != nil {
+			return nil, fmt.Errorf("error loading covariance: %w", err)
+		}
+	}
+	f.versionID, f.covariance, f.formulas = versionID, covariance, formulas
+	return formulas, nil
+}
+
+// FormulaVersion identifies the active version of the formula set, e.g. cdc@3.
+func (f *SQLiteFormula) FormulaVersion() (string, error) {
+	version, err := f.ActiveVersion(f.FormulaSet)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s@%d", f.FormulaSet, version), nil
+}
+
+// activeVersion returns the row id and the number of the active version of the formula set.
+func activeVersion(tx *sql.Tx, set string) (int64, int, error) {
+	var versionID, version sql.NullInt64
+	err := tx.QueryRow(`SELECT s.active_version_id, v.version FROM formula_sets s
+		LEFT JOIN formula_set_versions v ON v.id = s.active_version_id WHERE s.name = ?`, set).Scan(&versionID, &version)
+	if err == sql.ErrNoRows || (err == nil && !versionID.Valid) {
+		return 0, 0, fmt.Errorf("formula set %s has no active version", set)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("error reading formula set %s: %w", set, err)
+	}
+	return versionID.Int64, int(version.Int64), nil
+}
+
+// readVersionFormulas reads and compiles the formulas of a formula set version. label is their Version, e.g. cdc@3.
+func readVersionFormulas(tx *sql.Tx, versionID int64, label string) ([]*models.Formula, error) {
+	rows, err := tx.Query(`SELECT id, cdc_formula, using_own_eggs, attempted_ivf_previously, is_reason_for_infertility_known
+		FROM formulas WHERE version_id = ? ORDER BY id`, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading formulas: %w", err)
+	}
+	var formulas []*models.Formula
+	byID := map[int64]*models.Formula{}
+	for rows.Next() {
+		var id int64
+		f := &models.Formula{Version: label}
+		if err := rows.Scan(&id, &f.CDCFormula, &f.UsingOwnEggs, &f.AttemptedIVFPreviously,
+			&f.IsReasonForInfertilityKnown); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error reading formulas: %w", err)
+		}
+		formulas = append(formulas, f)
+		byID[id] = f
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading formulas: %w", err)
+	}
+
+	rows, err = tx.Query(`SELECT t.formula_id, t.position, t.name, t.kind, t.input, t.coefficient, t.exponent,
+		t.coefficient_name FROM formula_terms t JOIN formulas f ON f.id = t.formula_id
+		WHERE f.version_id = ? ORDER BY t.formula_id, t.position`, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading formula terms: %w", err)
+	}
+	for rows.Next() {
+		var formulaID int64
+		var position int
+		var t ivf.FormulaTerm
+		if err := rows.Scan(&formulaID, &position, &t.Name, &t.Kind, &t.Input, &t.Coefficient, &t.Exponent,
+			&t.CoefficientName); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error reading formula terms: %w", err)
+		}
+		// positions start at 0 and have no gaps, so they are the indexes of the terms
+		f := byID[formulaID]
+		if position != len(f.Terms) {
+			rows.Close()
+			return nil, fmt.Errorf("formula %s: term %s is at position %d, expected %d", f.CDCFormula, t.Name,
+				position, len(f.Terms))
+		}
+		f.Terms = append(f.Terms, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading formula terms: %w", err)
+	}
+
+	rows, err = tx.Query(`SELECT l.formula_id, l.position, l.level, l.coefficient FROM term_levels l
+		JOIN formulas f ON f.id = l.formula_id WHERE f.version_id = ?`, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading term levels: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var formulaID int64
+		var position int
+		var level string
+		var coefficient float64
+		if err := rows.Scan(&formulaID, &position, &level, &coefficient); err != nil {
+			return nil, fmt.Errorf("error reading term levels: %w", err)
+		}
+		f := byID[formulaID]
+		if position < 0 || position >= len(f.Terms) {
+			return nil, fmt.Errorf("formula %s: level %s of term position %d, which doesn't exist", f.CDCFormula, level,
+				position)
+		}
+		t := &f.Terms[position]
+		if t.Levels == nil {
+			t.Levels = map[string]float64{}
+		}
+		t.Levels[level] = coefficient
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading term levels: %w", err)
+	}
+
+	for _, f := range formulas {
+		if err := f.Compile(); err != nil {
+			return nil, fmt.Errorf("formula %s: %w", f.CDCFormula, err)
+		}
+	}
+	return formulas, nil
+}
+
+// ImportFormulas stores the formulas as a new version of the formula set, creating the set when missing, and
+// makes it the active version. source describes where the formulas come from, e.g. the imported file name.
+func (f *SQLiteFormula) ImportFormulas(set string, source string, formulas []*models.Formula) (int, error) {
+	tx, err := f.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error importing formulas: %w", err)
+	}
+	defer tx.Rollback()
+
+	versionID, version, err := insertVersion(tx, set, source, formulas)
+	if err != nil {
+		return 0, err
+	}
+	if err := activateVersion(tx, set, versionID, version); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error importing formulas: %w", err)
+	}
+	return version, nil
+}
+
+// insertVersion stores the formulas as the next version of the set and returns the version row id and number.
+func insertVersion(tx *sql.Tx, set string, source string, formulas []*models.Formula) (int64, int, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.Exec(`INSERT INTO formula_sets (name, created_at) VALUES (?, ?) ON CONFLICT (name) DO NOTHING`,
+		set, now); err != nil {
+		return 0, 0, fmt.Errorf("error creating formula set %s: %w", set, err)
+	}
+	var setID int64
+	var version int
+	if err := tx.QueryRow(`SELECT s.id, COALESCE(MAX(v.version), 0) + 1 FROM formula_sets s
+		LEFT JOIN formula_set_versions v ON v.formula_set_id = s.id WHERE s.name = ? GROUP BY s.id`, set).
+		Scan(&setID, &version); err != nil {
+		return 0, 0, fmt.Errorf("error reading formula set %s: %w", set, err)
+	}
+
+	res, err := tx.Exec(`INSERT INTO formula_set_versions (formula_set_id, version, source, created_at) VALUES (?, ?, ?, ?)`,
+		setID, version, source, now)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error creating version %d: %w", version, err)
+	}
+	versionID, err := res.LastInsertId()
+	if err != nil {
+		return 0, 0, fmt.Errorf("error creating version %d: %w", version, err)
+	}
+
+	for _, formula := range formulas {
+		if err := formula.Compile(); err != nil {
+			return 0, 0, fmt.Errorf("formula %s: %w", formula.CDCFormula, err)
+		}
+		res, err := tx.Exec(`INSERT INTO formulas (version_id, cdc_formula, using_own_eggs, attempted_ivf_previously,
+			is_reason_for_infertility_known) VALUES (?, ?, ?, ?, ?)`, versionID, formula.CDCFormula, formula.UsingOwnEggs,
+			formula.AttemptedIVFPreviously, formula.IsReasonForInfertilityKnown)
+		if err != nil {
+			return 0, 0, fmt.Errorf("error storing formula %s: %w", formula.CDCFormula, err)
+		}
+		formulaID, err := res.LastInsertId()
+		if err != nil {
+			return 0, 0, fmt.Errorf("error storing formula %s: %w", formula.CDCFormula, err)
+		}
+
+		for position, t := range formula.Terms {
+			if _, err := tx.Exec(`INSERT INTO formula_terms (formula_id, position, name, kind, input, coefficient,
+				exponent, coefficient_name) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, formulaID, position, t.Name, string(t.Kind),
+				t.Input, t.Coefficient, t.Exponent, t.CoefficientName); err != nil {
+				return 0, 0, fmt.Errorf("error storing formula %s term %s: %w", formula.CDCFormula, t.Name, err)
+			}
+			for level, coefficient := range t.Levels {
+				if _, err := tx.Exec(`INSERT INTO term_levels (formula_id, position, level, coefficient) VALUES (?, ?, ?, ?)`,
+					formulaID, position, level, coefficient); err != nil {
+					return 0, 0, fmt.Errorf("error storing formula %s term %s: %w", formula.CDCFormula, t.Name, err)
+				}
+			}
+		}
+	}
+	return versionID, version, nil
+}
+
+// SQLiteVersion reads a given version of a formula set, active or not, e.g. a candidate in shadow mode.
+type SQLiteVersion struct {
+	formulas *SQLiteFormula
+	version  int
+}
+
+// Version returns the reader of a version of the formula set.
+func (f *SQLiteFormula) Version(version int) *SQLiteVersion {
+	return &SQLiteVersion{formulas: f, version: version}
+}
+
+// GetFormula reads the version and returns the matching formula
+func (v *SQLiteVersion) GetFormula(usingOwnEggs string, attemptedIVFPreviously string, isReasonKnown string) (*models.Formula, error) {
+	formulas, err := v.GetFormulas()
+	if err != nil {
+		return nil, err
+	}
+
+	return ivf.FindFormula(formulas, usingOwnEggs, attemptedIVFPreviously, isReasonKnown)
+}
+
+// GetFormulas reads all formulas of the version
+func (v *SQLiteVersion) GetFormulas() ([]*models.Formula, error) {
+	formulas, err := v.formulas.VersionFormulas(v.formulas.FormulaSet, v.version)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.formulas.CovariancePath != "" {
+		if err := ivf.LoadCovariance(v.formulas.CovariancePath, formulas); err != nil {
+			return nil, fmt.Errorf("error loading covariance: %w", err)
+		}
+	}
+	return formulas, nil
+}
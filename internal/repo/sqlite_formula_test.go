@@ -0,0 +1,148 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const formulasPath = "data/ivf_success_formulas.csv"
+
+func newTestSQLiteFormula(t *testing.T) *SQLiteFormula {
+	db, err := NewSQLiteFormula(&SQLiteConfig{Path: filepath.Join(t.TempDir(), "formulas.db")})
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLiteFormula(t *testing.T) {
+	csvFormulas, err := ivf.LoadFormulas(formulasPath)
+	require.NoError(t, err)
+
+	t.Run("No active version before the import", func(t *testing.T) {
+		db := newTestSQLiteFormula(t)
+
+		_, err := db.GetFormulas()
+
+		assert.EqualError(t, err, "formula set cdc has no active version")
+	})
+
+	t.Run("Imported CSV calculates the same as the CSV", func(t *testing.T) {
+		db := newTestSQLiteFormula(t)
+		version, err := db.ImportFormulas(DefaultFormulaSet, formulasPath, csvFormulas)
+		require.NoError(t, err)
+		assert.Equal(t, 1, version)
+
+		formulas, err := db.GetFormulas()
+		require.NoError(t, err)
+		require.Len(t, formulas, len(csvFormulas))
+
+		input, err := ivf.NewInput(&ivf.Patient{Age: 32, Weight: 150, Feet: 5, Inches: 8, IVFUsed: "0", Gravida: "1",
+			PreviousLiveBirths: "1", Endometriosis: true, OvulatoryDisorder: true, EggSource: ivf.EggSourceOwn})
+		require.NoError(t, err)
+		for i, f := range formulas {
+			assert.Equal(t, csvFormulas[i].CDCFormula, f.CDCFormula)
+			assert.Equal(t, csvFormulas[i].Terms, f.Terms)
+			expected, err := ivf.Explain(csvFormulas[i], input)
+			require.NoError(t, err)
+			actual, err := ivf.Explain(f, input)
+			require.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		}
+
+		f, err := db.GetFormula(input.UseOwnEggs, input.IVFUsed, input.ReasonKnown)
+		require.NoError(t, err)
+		rate, err := ivf.CalculateSuccess(f, input)
+		require.NoError(t, err)
+		assert.Equal(t, 62.21, rate)
+	})
+
+	t.Run("Import activates a new version", func(t *testing.T) {
+		db := newTestSQLiteFormula(t)
+		_, err := db.ImportFormulas(DefaultFormulaSet, formulasPath, csvFormulas)
+		require.NoError(t, err)
+
+		version, err := db.ImportFormulas(DefaultFormulaSet, "one formula", csvFormulas[:1])
+		require.NoError(t, err)
+		assert.Equal(t, 2, version)
+
+		formulas, err := db.GetFormulas()
+		require.NoError(t, err)
+		assert.Len(t, formulas, 1)
+
+		formulas, err = db.Version(1).GetFormulas()
+		require.NoError(t, err)
+		assert.Len(t, formulas, len(csvFormulas), "older versions stay readable")
+	})
+
+	t.Run("Formulas are read once per active version", func(t *testing.T) {
+		db := newTestSQLiteFormula(t)
+		_, err := db.ImportFormulas(DefaultFormulaSet, formulasPath, csvFormulas)
+		require.NoError(t, err)
+
+		first, err := db.GetFormulas()
+		require.NoError(t, err)
+		again, err := db.GetFormulas()
+		require.NoError(t, err)
+		assert.Same(t, first[0], again[0])
+		assert.Equal(t, "cdc@1", first[0].Version)
+
+		_, err = db.ImportFormulas(DefaultFormulaSet, "one formula", csvFormulas[:1])
+		require.NoError(t, err)
+		activated, err := db.GetFormulas()
+		require.NoError(t, err)
+		assert.Len(t, activated, 1, "the new active version is read")
+		assert.Equal(t, "cdc@2", activated[0].Version)
+	})
+
+	// the updates go through a connection without foreign keys, as a hand edited database could
+	for _, tt := range []struct {
+		name   string
+		update string
+		error  string
+	}{
+		{"Gap in the term positions", `UPDATE formula_terms SET position = position + 100 WHERE position = 1`,
+			"is at position 2, expected 1"},
+		{"Level of a missing term", `UPDATE term_levels SET position = position + 100`,
+			"which doesn't exist"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestSQLiteFormula(t)
+			_, err := db.ImportFormulas(DefaultFormulaSet, formulasPath, csvFormulas)
+			require.NoError(t, err)
+			raw, err := sql.Open("sqlite", db.Path)
+			require.NoError(t, err)
+			defer raw.Close()
+			_, err = raw.Exec(tt.update)
+			require.NoError(t, err)
+
+			_, err = db.GetFormulas()
+			assert.ErrorContains(t, err, tt.error)
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "formulas.db")
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, Migrate(db))
+	require.NoError(t, Migrate(db), "migrations are applied once")
+
+	var applied int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied))
+	assert.Equal(t, len(migrations), applied)
+
+	_, err = db.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, '')`, len(migrations)+1)
+	require.NoError(t, err)
+	assert.EqualError(t, Migrate(db), fmt.Sprintf("database schema version %d is newer than this build, which knows %d",
+		len(migrations)+1, len(migrations)))
+}
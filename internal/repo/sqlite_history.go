@@ -0,0 +1,80 @@
+package repo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"ivf_calculator/internal/models"
+)
+
+// createdAtFormat has a fixed width so that the created_at column sorts by time.
+const createdAtFormat = "2006-01-02T15:04:05.000000000Z"
+
+type HistoryConfig struct {
+	// Path is the SQLite database file. It can be the formula database.
+	Path   string
+	Logger *log.Logger
+}
+
+// SQLiteHistory stores the calculation history in an SQLite database.
+type SQLiteHistory struct {
+	*HistoryConfig
+	db *sql.DB
+}
+
+// NewSQLiteHistory opens the database and applies the pending migrations.
+func NewSQLiteHistory(config *HistoryConfig) (*SQLiteHistory, error) {
+	db, err := openSQLite(config.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteHistory{HistoryConfig: config, db: db}, nil
+}
+
+// Close closes the database.
+func (h *SQLiteHistory) Close() error {
+	return h.db.Close()
+}
+
+// SaveCalculation stores the calculation under its ID.
+func (h *SQLiteHistory) SaveCalculation(c *models.Calculation) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("error storing calculation %s: %w", c.ID, err)
+	}
+	if _, err := h.db.Exec(`INSERT INTO calculations (id, created_at, data) VALUES (?, ?, ?)`, c.ID,
+		c.CreatedAt.UTC().Format(createdAtFormat), string(data)); err != nil {
+		return fmt.Errorf("error storing calculation %s: %w", c.ID, err)
+	}
+	return nil
+}
+
+// GetCalculation returns the calculation with the ID, models.ErrNotFound when there is none.
+func (h *SQLiteHistory) GetCalculation(id string) (*models.Calculation, error) {
+	var data string
+	err := h.db.QueryRow(`SELECT data FROM calculations WHERE id = ?`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("calculation %s: %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading calculation %s: %w", id, err)
+	}
+
+	c := &models.Calculation{}
+	if err := json.Unmarshal([]byte(data), c); err != nil {
+		return nil, fmt.Errorf("error reading calculation %s: %w", id, err)
+	}
+	return c, nil
+}
+
+// DeleteCalculationsBefore deletes the calculations created before the time and returns how many were deleted.
+func (h *SQLiteHistory) DeleteCalculationsBefore(t time.Time) (int64, error) {
+	res, err := h.db.Exec(`DELETE FROM calculations WHERE created_at < ?`, t.UTC().Format(createdAtFormat))
+	if err != nil {
+		return 0, fmt.Errorf("error deleting calculations: %w", err)
+	}
+	return res.RowsAffected()
+}
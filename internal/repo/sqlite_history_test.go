@@ -0,0 +1,41 @@
+package repo
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteHistory(t *testing.T) {
+	history, err := NewSQLiteHistory(&HistoryConfig{Path: filepath.Join(t.TempDir(), "history.db")})
+	require.NoError(t, err)
+	defer history.Close()
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+	saved := &models.Calculation{ID: "new", CreatedAt: now, Model: "cdc", Inputs: map[string]string{"age": "32"},
+		FormulaVersion: "cdc@1", CDCFormula: "1-3", SuccessRate: 62.21, Precision: ivf.PrecisionCDC,
+		Explanation: &ivf.Explanation{CDCFormula: "1-3", Terms: []ivf.Term{{Name: "intercept", Contribution: -6.8}}}}
+	require.NoError(t, history.SaveCalculation(saved))
+	require.NoError(t, history.SaveCalculation(&models.Calculation{ID: "old", CreatedAt: now.Add(-48 * time.Hour)}))
+
+	c, err := history.GetCalculation("new")
+	require.NoError(t, err)
+	assert.Equal(t, saved, c)
+
+	_, err = history.GetCalculation("missing")
+	assert.ErrorIs(t, err, models.ErrNotFound)
+
+	deleted, err := history.DeleteCalculationsBefore(now.Add(-24 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	_, err = history.GetCalculation("old")
+	assert.ErrorIs(t, err, models.ErrNotFound)
+	_, err = history.GetCalculation("new")
+	assert.NoError(t, err)
+}
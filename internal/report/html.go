@@ -0,0 +1,23 @@
+package report
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+)
+
+//go:embed report.html
+var htmlTemplate string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent":   func(v float64) float64 { return v * 100 },
+	"attempted": attempted,
+}).Parse(htmlTemplate))
+
+// WriteHTML writes the report as a printable HTML page.
+func WriteHTML(w io.Writer, r *Report) error {
+	return reportTemplate.Execute(w, struct {
+		*Report
+		Caveats []string
+	}{r, Caveats})
+}
@@ -0,0 +1,184 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"ivf_calculator/internal/pdf"
+)
+
+const (
+	margin       = 54.0
+	contentWidth = pdf.PageWidth - 2*margin
+	textSize     = 9.0
+	lineHeight   = 13.0
+	// headingHeight is the space a heading takes, with the space above it and the rule below it.
+	headingHeight = 28.0
+	caveatSize    = 8.0
+	caveatHeight  = 10.0
+)
+
+// column is a table column. Right aligned columns are positioned by their right edge.
+type column struct {
+	header string
+	x      float64
+	right  bool
+}
+
+// layout writes the report top down on a single page.
+type layout struct {
+	doc *pdf.Document
+	y   float64
+}
+
+// WritePDF writes the report as a one-page PDF. The caveats always fit: when there are too many inputs or cycles, the
+// last inputs are summed up in one line and the projection keeps its first cycles and the last one. The HTML report
+// has them all.
+func WritePDF(w io.Writer, r *Report) error {
+	l := &layout{doc: pdf.New(), y: margin}
+
+	l.text(margin, pdf.HelveticaBold, 18, "Your IVF success estimate", 22)
+	meta := r.CreatedAt.Format("January 2, 2006")
+	if r.CalculationID != "" {
+		meta += " - Calculation " + r.CalculationID
+	}
+	l.text(margin, pdf.Helvetica, textSize, meta, lineHeight)
+
+	if res := r.Result; res != nil {
+		l.y += 6
+		l.text(margin, pdf.HelveticaBold, 13, fmt.Sprintf("Chance of having a baby with one IVF cycle: %.2f%%",
+			res.SuccessRate), 18)
+		if i := res.Interval; i != nil {
+			l.text(margin, pdf.Helvetica, 10, fmt.Sprintf("%.0f%% confidence interval: %.2f%% to %.2f%%",
+				i.Level*100, i.Lower, i.Upper), lineHeight)
+		}
+		model := "Prediction model " + res.Model
+		if res.CDCFormula != "" {
+			model += ", CDC formula " + res.CDCFormula
+		}
+		l.text(margin, pdf.Helvetica, textSize, model+".", lineHeight)
+	}
+
+	var caveats [][]string
+	caveatsHeight := headingHeight
+	for _, caveat := range Caveats {
+		lines := pdf.Wrap(caveat, pdf.Helvetica, caveatSize, contentWidth-10)
+		caveats = append(caveats, lines)
+		caveatsHeight += float64(len(lines))*caveatHeight + 2
+	}
+	// space left for the inputs once the scenarios and up to 3 cycles of the projection fit
+	reserved := caveatsHeight
+	if len(r.Scenarios) > 0 {
+		reserved += headingHeight + float64(len(r.Scenarios)+1)*lineHeight
+	}
+	if p := r.Projection; p != nil {
+		reserved += headingHeight + float64(min(len(p.Cycles), 3)+1)*lineHeight
+	}
+
+	l.heading("Your information")
+	inputs := r.Inputs
+	if rows := l.rows(reserved); len(inputs) > 2*rows {
+		more := len(inputs) - (2*rows - 1)
+		inputs = append(inputs[:2*rows-1:2*rows-1], Input{Label: "More inputs",
+			Value: fmt.Sprintf("%d in the HTML report", more)})
+	}
+	half := (len(inputs) + 1) / 2
+	columnWidth := (contentWidth - 24) / 2
+	top := l.y
+	for i, input := range inputs {
+		x, y := margin, top+float64(i)*lineHeight
+		if i >= half {
+			x, y = margin+columnWidth+24, top+float64(i-half)*lineHeight
+		}
+		l.doc.Text(x, y+textSize, pdf.Helvetica, textSize, input.Label)
+		l.doc.Text(x+columnWidth-pdf.TextWidth(input.Value, pdf.HelveticaBold, textSize), y+textSize,
+			pdf.HelveticaBold, textSize, input.Value)
+		l.doc.Line(x, y+lineHeight-2, x+columnWidth, y+lineHeight-2, 0.25)
+	}
+	l.y = top + float64(half)*lineHeight
+
+	if len(r.Scenarios) > 0 {
+		l.heading("Own and donor eggs")
+		var rows [][]string
+		for _, s := range r.Scenarios {
+			eggs := s.EggSource
+			if s.MatchesInput {
+				eggs += " (your plan)"
+			}
+			rows = append(rows, []string{eggs, attempted(s), fmt.Sprintf("%.2f%%", s.SuccessRate)})
+		}
+		l.table([]column{{"Eggs", margin, false}, {"Cycle", margin + 170, false},
+			{"Chance of a baby", margin + contentWidth, true}}, rows)
+	}
+
+	if p := r.Projection; p != nil {
+		l.heading("Over several cycles")
+		cycles := p.Cycles
+		// the header takes one row
+		if fit := l.rows(caveatsHeight) - 1; len(cycles) > fit {
+			cycles = append(cycles[:fit-1:fit-1], cycles[len(cycles)-1])
+		}
+		var rows [][]string
+		for _, c := range cycles {
+			rows = append(rows, []string{strconv.Itoa(c.Cycle), strconv.Itoa(c.Age),
+				fmt.Sprintf("%.2f%%", c.SuccessRate), fmt.Sprintf("%.2f%%", c.CumulativeSuccessRate)})
+		}
+		l.table([]column{{"Cycle", margin, false}, {"Age", margin + 80, false},
+			{"Chance in this cycle", margin + 330, true},
+			{"Chance by the end of this cycle", margin + contentWidth, true}}, rows)
+	}
+
+	l.heading("About this estimate")
+	for _, lines := range caveats {
+		l.doc.Text(margin, l.y+caveatSize, pdf.Helvetica, caveatSize, "-")
+		for _, line := range lines {
+			l.text(margin+10, pdf.Helvetica, caveatSize, line, caveatHeight)
+		}
+		l.y += 2
+	}
+
+	_, err := l.doc.WriteTo(w)
+	return err
+}
+
+// text writes a line and moves down by height.
+func (l *layout) text(x float64, font pdf.Font, size float64, s string, height float64) {
+	l.doc.Text(x, l.y+size, font, size, s)
+	l.y += height
+}
+
+func (l *layout) heading(s string) {
+	l.y += 10
+	l.text(margin, pdf.HelveticaBold, 11, s, 14)
+	l.doc.Line(margin, l.y, margin+contentWidth, l.y, 0.75)
+	l.y += 4
+}
+
+func (l *layout) table(columns []column, rows [][]string) {
+	l.doc.FillRect(margin, l.y, contentWidth, lineHeight, 0.9)
+	l.row(columns, pdf.HelveticaBold, func(i int) string { return columns[i].header })
+	for _, row := range rows {
+		l.row(columns, pdf.Helvetica, func(i int) string { return row[i] })
+	}
+}
+
+func (l *layout) row(columns []column, font pdf.Font, cell func(i int) string) {
+	for i, c := range columns {
+		x := c.x
+		if c.right {
+			x -= pdf.TextWidth(cell(i), font, textSize) + 4
+		} else {
+			x += 4
+		}
+		l.doc.Text(x, l.y+textSize+1, font, textSize, cell(i))
+	}
+	l.y += lineHeight
+	l.doc.Line(margin, l.y, margin+contentWidth, l.y, 0.25)
+}
+
+// rows returns the number of lines that fit on the page, keeping reserved for what follows. There is always room for
+// two.
+func (l *layout) rows(reserved float64) int {
+	return max(int((pdf.PageHeight-margin-reserved-l.y)/lineHeight), 2)
+}
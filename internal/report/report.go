@@ -0,0 +1,69 @@
+// Package report renders the one-page patient summary of a calculation as HTML or PDF.
+package report
+
+import (
+	"net/url"
+	"time"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/pkg/ivf"
+)
+
+// Caveats are the standard notes of the CDC IVF success estimator printed on every report.
+var Caveats = []string{
+	"This estimate is based on national data reported to the CDC by U.S. fertility clinics. It describes the " +
+		"outcomes of patients with similar characteristics on average and is not a guarantee of your outcome.",
+	"The estimate is the chance of having a baby from one complete IVF cycle: one egg retrieval and the transfer of " +
+		"all embryos from it. It does not include cycles using frozen embryos from earlier retrievals.",
+	"Many factors that affect success are not part of the estimate, such as the clinic, embryo quality, ovarian " +
+		"reserve test results or the health of the sperm provider.",
+	"The multi-cycle projection assumes that every cycle is independent and that you start each one at the age you " +
+		"will then be. Many patients stop treatment before completing several cycles.",
+	"This report is not medical advice. Please discuss these results and your treatment options with your doctor.",
+}
+
+// Input is a calculator input as printed on the report.
+type Input struct {
+	Label string
+	Value string
+}
+
+// Report is the content of the summary.
+type Report struct {
+	// CalculationID is set when the report is of a saved calculation.
+	CalculationID string
+	CreatedAt     time.Time
+	Inputs        []Input
+	Result        *models.IVFResult
+	// Scenarios compares the chance with own and donor eggs, and Projection is the chance over several cycles. Both
+	// are only set for the CDC model.
+	Scenarios  []ivf.Scenario
+	Projection *ivf.Projection
+}
+
+// Inputs lists the values of the model params in their order. Other values are left out, so the report only shows
+// what the model read. Params without a label are shown by name.
+func Inputs(params []ivf.Param, values url.Values) []Input {
+	var inputs []Input
+	for _, p := range params {
+		if v := values.Get(p.Name); v != "" {
+			label := p.Label
+			if label == "" {
+				label = p.Name
+			}
+			inputs = append(inputs, Input{Label: label, Value: v})
+		}
+	}
+	return inputs
+}
+
+// attempted describes the prior IVF of a scenario.
+func attempted(s ivf.Scenario) string {
+	switch s.AttemptedIVFPreviously {
+	case "Yes":
+		return "After a previous IVF cycle"
+	case "No":
+		return "First IVF cycle"
+	}
+	return "Any"
+}
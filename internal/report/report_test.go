@@ -0,0 +1,132 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/internal/pdf"
+	"ivf_calculator/pkg/ivf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReport() *Report {
+	return &Report{
+		CalculationID: "c0ffee",
+		CreatedAt:     time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC),
+		Inputs:        []Input{{Label: "Age", Value: "32"}, {Label: "Egg source", Value: "Own"}},
+		Result: &models.IVFResult{Model: ivf.CDCModelName, SuccessRate: 62.21, CDCFormula: "1-3",
+			Interval: &ivf.Interval{Lower: 58.3, Upper: 66, Level: 0.95}},
+		Scenarios: []ivf.Scenario{
+			{EggSource: "Own", AttemptedIVFPreviously: "No", SuccessRate: 62.21, MatchesInput: true},
+			{EggSource: "Donor", AttemptedIVFPreviously: "N/A", SuccessRate: 60.91},
+		},
+		Projection: &ivf.Projection{CumulativeSuccessRate: 83.98, Cycles: []ivf.CycleProjection{
+			{Cycle: 1, Age: 32, SuccessRate: 62.21, CumulativeSuccessRate: 62.21},
+			{Cycle: 2, Age: 32, SuccessRate: 57.6, CumulativeSuccessRate: 83.98},
+		}},
+	}
+}
+
+func TestInputs(t *testing.T) {
+	values := url.Values{"eggSource": {"Own"}, "age": {"32"}, "amh": {"1.2"}, "gravida": {""},
+		"Diagnosis": {"Anything"}}
+	assert.Equal(t, []Input{{"Age", "32"}, {"Egg source", "Own"}}, Inputs(ivf.Params(), values),
+		"the inputs are in the form order, empty values and values of other params are left out")
+
+	params := []ivf.Param{{Name: "amh", Type: "number"}, {Name: "age", Label: "Age", Type: "integer"}}
+	assert.Equal(t, []Input{{"amh", "1.2"}, {"Age", "32"}}, Inputs(params, values),
+		"params without a label are shown by name")
+}
+
+func TestWriteHTML(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, WriteHTML(&out, testReport()))
+	page := out.String()
+
+	assert.Contains(t, page, "June 1, 2024 &middot; Calculation c0ffee")
+	assert.Contains(t, page, "<strong>62.21%</strong>")
+	assert.Contains(t, page, "95% confidence interval: 58.30% to 66.00%")
+	assert.Contains(t, page, "<div><dt>Egg source</dt><dd>Own</dd></div>")
+	assert.Contains(t, page, `<tr><td>Own (your plan)</td><td>First IVF cycle</td><td class="number">62.21%</td></tr>`)
+	assert.Contains(t, page, `<tr><td>2</td><td>32</td><td class="number">57.60%</td><td class="number">83.98%</td></tr>`)
+	for _, caveat := range Caveats {
+		assert.Contains(t, page, "<li>"+caveat+"</li>")
+	}
+}
+
+func TestWritePDF(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, WritePDF(&out, testReport()))
+	data := out.String()
+
+	assert.True(t, strings.HasPrefix(data, "%PDF-"))
+	assert.Contains(t, data, "/Count 1 >>", "the report fits on one page")
+	assert.Contains(t, data, "(Chance of having a baby with one IVF cycle: 62.21%)")
+	assert.Contains(t, data, "(June 1, 2024 - Calculation c0ffee)")
+	assert.Contains(t, data, "(Own \\(your plan\\))")
+	assert.Contains(t, data, "(83.98%)")
+	assert.Contains(t, data, "(This report is not medical advice.")
+}
+
+func TestWritePDFOnePage(t *testing.T) {
+	full := testReport()
+	full.Inputs = nil
+	for _, p := range ivf.Params() {
+		full.Inputs = append(full.Inputs, Input{Label: p.Label, Value: "1"})
+	}
+	full.Projection.Cycles = nil
+	for i := 1; i <= 3; i++ {
+		full.Projection.Cycles = append(full.Projection.Cycles, ivf.CycleProjection{Cycle: i, Age: 40 + i})
+	}
+
+	long := testReport()
+	long.Inputs = nil
+	for i := 1; i <= 60; i++ {
+		long.Inputs = append(long.Inputs, Input{Label: fmt.Sprintf("input %d", i), Value: "1"})
+	}
+	long.Projection.Cycles = nil
+	for i := 1; i <= 40; i++ {
+		long.Projection.Cycles = append(long.Projection.Cycles, ivf.CycleProjection{Cycle: i, Age: 100 + i})
+	}
+
+	tests := []struct {
+		name       string
+		report     *Report
+		contains   []string
+		notContain []string
+	}{
+		{"all the CDC inputs", full, []string{"(" + ivf.Params()[len(ivf.Params())-1].Label + ")", "(43)"},
+			[]string{"(More inputs)"}},
+		{"too many inputs and cycles", long, []string{"(input 1)", "(More inputs)", "(101)", "(102)", "(140)"},
+			[]string{"(input 60)", "(139)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			require.NoError(t, WritePDF(&out, tt.report))
+			data := out.String()
+
+			assert.Contains(t, data, "/Count 1 >>")
+			assert.Contains(t, data, "(This report is not medical advice.")
+			for _, s := range tt.contains {
+				assert.Contains(t, data, s)
+			}
+			for _, s := range tt.notContain {
+				assert.NotContains(t, data, s)
+			}
+		})
+	}
+}
+
+func TestWritePDFNotWinAnsi(t *testing.T) {
+	r := testReport()
+	r.Inputs = append(r.Inputs, Input{Label: "Note", Value: "Élodie ✓"})
+	assert.ErrorIs(t, WritePDF(&bytes.Buffer{}, r), pdf.ErrNotWinAnsi)
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"container/list"
+	"net/url"
+	"sync"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/pkg/ivf"
+)
+
+// resultCache is a bounded LRU cache of the successful predictions. It is emptied when the formula version changes.
+type resultCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	// order holds the keys, most recently used first
+	order   *list.List
+	version string
+	stats   models.CacheStats
+}
+
+type cacheEntry struct {
+	key    string
+	result *models.IVFResult
+}
+
+func newResultCache(capacity int) *resultCache {
+	return &resultCache{
+		capacity: capacity,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+		stats:    models.CacheStats{Capacity: capacity},
+	}
+}
+
+func (c *resultCache) get(key string) (*models.IVFResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(e)
+	// callers get their own copy of the result
+	result := *e.Value.(*cacheEntry).result
+	return &result, true
+}
+
+func (c *resultCache) add(key string, result *models.IVFResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stored := *result
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*cacheEntry).result = &stored
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, result: &stored})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.stats.Evictions++
+	}
+}
+
+// setVersion empties the cache when the formulas were reloaded with another version.
+func (c *resultCache) setVersion(version string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if version == c.version {
+		return
+	}
+	if c.version != "" {
+		c.stats.Invalidations++
+	}
+	c.version = version
+	c.entries = map[string]*list.Element{}
+	c.order.Init()
+}
+
+func (c *resultCache) snapshot() models.CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.order.Len()
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+// CalculationKey identifies the prediction of the values: the model, the version of its formulas and the normalized
+// inputs. Equal keys give the same result. The key is empty when the prediction can't be cached because the formula
+// version isn't known.
+func (s *SuccessCalculator) CalculationKey(model string, values url.Values) (string, error) {
+	m, err := s.models.Model(model)
+	if err != nil {
+		return "", err
+	}
+	return s.calculationKey(m, values), nil
+}
+
+func (s *SuccessCalculator) calculationKey(m ivf.PredictionModel, values url.Values) string {
+	version, err := s.formulaVersion(m.Name())
+	if err != nil || version == "" {
+		return ""
+	}
+	if s.cache != nil {
+		s.cache.setVersion(version)
+	}
+	return m.Name() + "|" + version + "|" + ivf.NormalizeValues(m.Params(), values).Encode()
+}
+
+// CacheStats returns the hit rate and size of the result cache.
+func (s *SuccessCalculator) CacheStats() models.CacheStats {
+	if s.cache == nil {
+		return models.CacheStats{}
+	}
+	return s.cache.snapshot()
+}
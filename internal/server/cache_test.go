@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/internal/repo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultCache(t *testing.T) {
+	data, err := os.ReadFile(formulasPath)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "formulas.csv")
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+	calc := NewSuccessCalculator(&Config{Repo: repo.NewIVFFormula(&repo.Config{FilePath: path}), CacheSize: 2})
+
+	patient := func(age string) url.Values {
+		values, err := url.ParseQuery(readmeQuery)
+		require.NoError(t, err)
+		values.Set("age", age)
+		return values
+	}
+
+	first, err := calc.Predict("", patient("32"))
+	require.NoError(t, err)
+	values := patient("32")
+	values.Set("utm_source", "widget")
+	cached, err := calc.Predict("cdc", values)
+	require.NoError(t, err)
+	assert.Equal(t, first, cached, "ignored params and the default model share the key")
+	assert.Equal(t, models.CacheStats{Size: 1, Capacity: 2, Hits: 1, Misses: 1, HitRate: 0.5}, calc.CacheStats())
+
+	for i := 0; i < 2; i++ {
+		_, err = calc.Predict("", patient("51"))
+		assert.Error(t, err)
+	}
+	assert.Equal(t, 1, calc.CacheStats().Size, "errors are never cached")
+
+	_, err = calc.Predict("", patient("33"))
+	require.NoError(t, err)
+	_, err = calc.Predict("", patient("34"))
+	require.NoError(t, err)
+	stats := calc.CacheStats()
+	assert.Equal(t, 2, stats.Size)
+	assert.Equal(t, 1, stats.Evictions)
+
+	t.Run("Formula reload empties the cache", func(t *testing.T) {
+		key, err := calc.CalculationKey("", patient("34"))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, []byte(string(data)+"\n"), 0o644))
+		reloadedKey, err := calc.CalculationKey("", patient("34"))
+		require.NoError(t, err)
+		assert.NotEqual(t, key, reloadedKey)
+
+		_, err = calc.Predict("", patient("34"))
+		require.NoError(t, err)
+		stats := calc.CacheStats()
+		assert.Equal(t, 1, stats.Size)
+		assert.Equal(t, 1, stats.Invalidations)
+	})
+
+	t.Run("Repos without a version aren't cached", func(t *testing.T) {
+		formulas, err := repo.NewIVFFormula(&repo.Config{FilePath: formulasPath}).GetFormulas()
+		require.NoError(t, err)
+		getter := new(MockFormulaGetter)
+		getter.On("GetFormula", mock.Anything, mock.Anything, mock.Anything).Return(formulas[0], nil)
+		calc := NewSuccessCalculator(&Config{Repo: getter, CacheSize: 2})
+
+		for i := 0; i < 2; i++ {
+			_, err := calc.Predict("", patient("32"))
+			require.NoError(t, err)
+		}
+		getter.AssertNumberOfCalls(t, "GetFormula", 2)
+		assert.Equal(t, models.CacheStats{Capacity: 2}, calc.CacheStats())
+	})
+}
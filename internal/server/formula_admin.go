@@ -0,0 +1,290 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"strconv"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/pkg/ivf"
+)
+
+// referenceTolerance is the largest difference with the expected success rate a reference case passes with.
+const referenceTolerance = 0.005
+
+// FormulaStore stores versioned formula sets and the admin audit trail.
+type FormulaStore interface {
+	Versions(set string) ([]models.FormulaVersion, error)
+	ActiveVersion(set string) (int, error)
+	VersionFormulas(set string, version int) ([]*models.Formula, error)
+	AddVersion(set string, source string, formulas []*models.Formula) (int, error)
+	ActivateVersion(set string, version int) error
+	AddAuditEntry(entry *models.AuditEntry) error
+	AuditLog(limit int) ([]models.AuditEntry, error)
+}
+
+type AdminConfig struct {
+	Store FormulaStore
+	// ReferenceCases are the patients uploads are dry-run against.
+	ReferenceCases []models.ReferenceCase
+	Logger         *log.Logger
+}
+
+// FormulaAdmin reviews, uploads, activates and rolls back formula set versions. Every action is written to the
+// audit trail with the actor that performed it.
+type FormulaAdmin struct {
+	*AdminConfig
+}
+
+func NewFormulaAdmin(config *AdminConfig) *FormulaAdmin {
+	return &FormulaAdmin{
+		config,
+	}
+}
+
+// Versions lists the versions of the formula set.
+func (a *FormulaAdmin) Versions(actor string, set string) ([]models.FormulaVersion, error) {
+	versions, err := a.Store.Versions(set)
+	return versions, a.audit(actor, "list_versions", set, 0, err, fmt.Sprintf("%d versions", len(versions)))
+}
+
+// Formulas returns the formulas of a version of the formula set, of the active version when version is 0.
+func (a *FormulaAdmin) Formulas(actor string, set string, version int) ([]*models.Formula, error) {
+	formulas, version, err := a.versionFormulas(set, version)
+	return formulas, a.audit(actor, "list_formulas", set, version, err, fmt.Sprintf("%d formulas", len(formulas)))
+}
+
+// Validate checks an upload without storing it and dry-runs it against the reference cases.
+func (a *FormulaAdmin) Validate(actor string, set string, format string, data []byte) (*models.ValidationReport, error) {
+	report, _, err := a.validate(set, format, data)
+	return report, a.audit(actor, "validate", set, 0, err, reportDetails(report))
+}
+
+// Upload validates the formulas and, when they are valid, stores them as a new inactive version of the set.
+// An invalid upload is not stored; the report tells why.
+func (a *FormulaAdmin) Upload(actor string, set string, source string, format string, data []byte) (*models.ValidationReport, error) {
+	report, formulas, err := a.validate(set, format, data)
+	if err == nil && report.Valid {
+		report.Version, err = a.Store.AddVersion(set, source, formulas)
+	}
+	return report, a.audit(actor, "upload", set, reportVersion(report), err, source+": "+reportDetails(report))
+}
+
+// Activate makes the version the active version of the formula set.
+func (a *FormulaAdmin) Activate(actor string, set string, version int) error {
+	previous, err := a.Store.ActiveVersion(set)
+	if err == nil {
+		err = a.Store.ActivateVersion(set, version)
+	}
+	return a.audit(actor, "activate", set, version, err, fmt.Sprintf("previous version %d", previous))
+}
+
+// Rollback activates the newest version older than the active one and returns it.
+func (a *FormulaAdmin) Rollback(actor string, set string) (int, error) {
+	active, target, err := a.rollbackTarget(set)
+	if err == nil {
+		err = a.Store.ActivateVersion(set, target)
+	}
+	return target, a.audit(actor, "rollback", set, target, err, fmt.Sprintf("previous version %d", active))
+}
+
+// AuditLog returns the latest audit entries, newest first.
+func (a *FormulaAdmin) AuditLog(limit int) ([]models.AuditEntry, error) {
+	return a.Store.AuditLog(limit)
+}
+
+func (a *FormulaAdmin) rollbackTarget(set string) (int, int, error) {
+	versions, err := a.Store.Versions(set)
+	if err != nil {
+		return 0, 0, err
+	}
+	active, target := 0, 0
+	for _, v := range versions {
+		if v.Active {
+			active = v.Version
+		}
+	}
+	for _, v := range versions {
+		if v.Version < active && v.Version > target {
+			target = v.Version
+		}
+	}
+	if target == 0 {
+		return active, 0, fmt.Errorf("formula set %s has no version older than %d: %w", set, active, models.ErrConflict)
+	}
+	return active, target, nil
+}
+
+func (a *FormulaAdmin) versionFormulas(set string, version int) ([]*models.Formula, int, error) {
+	if version == 0 {
+		active, err := a.Store.ActiveVersion(set)
+		if err != nil {
+			return nil, 0, err
+		}
+		if active == 0 {
+			return nil, 0, fmt.Errorf("formula set %s has no active version: %w", set, models.ErrNotFound)
+		}
+		version = active
+	}
+	formulas, err := a.Store.VersionFormulas(set, version)
+	return formulas, version, err
+}
+
+// validate parses the upload, checks it against the active version and runs the reference cases.
+// Problems with the upload are reported, the error is only set when the check itself fails.
+func (a *FormulaAdmin) validate(set string, format string, data []byte) (*models.ValidationReport, []*models.Formula, error) {
+	report := &models.ValidationReport{Errors: []string{}, Warnings: []string{}, ReferenceCases: []models.ReferenceResult{}}
+
+	var formulas []*models.Formula
+	var err error
+	switch format {
+	case "csv":
+		formulas, err = ivf.ReadFormulas(bytes.NewReader(data))
+		if err == nil {
+			report.Errors = append(report.Errors, csvNumberErrors(data)...)
+		}
+	case "json":
+		formulas, err = ivf.ReadFormulaDefinitions(bytes.NewReader(data))
+	default:
+		return nil, nil, fmt.Errorf("unknown format %s, expected csv or json", format)
+	}
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report, nil, nil
+	}
+	report.Formulas = len(formulas)
+	if len(formulas) == 0 {
+		report.Errors = append(report.Errors, "the upload has no formulas")
+	}
+
+	ids := map[string]bool{}
+	selectors := map[string]string{}
+	for _, f := range formulas {
+		if ids[f.CDCFormula] {
+			report.Errors = append(report.Errors, fmt.Sprintf("formula %s is defined twice", f.CDCFormula))
+		}
+		ids[f.CDCFormula] = true
+		selector := formulaSelector(f)
+		if other, exists := selectors[selector]; exists {
+			report.Errors = append(report.Errors, fmt.Sprintf("formulas %s and %s both apply to %s", other, f.CDCFormula, selector))
+		}
+		selectors[selector] = f.CDCFormula
+	}
+
+	active, _, err := a.versionFormulas(set, 0)
+	if errors.Is(err, models.ErrNotFound) {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("formula set %s has no active version to compare with", set))
+	} else if err != nil {
+		return nil, nil, err
+	}
+	for _, f := range active {
+		if _, exists := selectors[formulaSelector(f)]; !exists {
+			report.Errors = append(report.Errors, fmt.Sprintf("no formula applies to %s, the active formula %s does",
+				formulaSelector(f), f.CDCFormula))
+		}
+	}
+
+	for _, c := range a.ReferenceCases {
+		result := referenceResult(c, formulas, active)
+		if !result.Passed {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("reference case %q doesn't match", c.Name))
+		}
+		report.ReferenceCases = append(report.ReferenceCases, result)
+	}
+
+	report.Valid = len(report.Errors) == 0
+	return report, formulas, nil
+}
+
+// referenceResult calculates the reference case with the candidate formulas and, when set, the active ones.
+func referenceResult(c models.ReferenceCase, candidate []*models.Formula, active []*models.Formula) models.ReferenceResult {
+	result := models.ReferenceResult{Name: c.Name, Expected: c.SuccessRate}
+	values, err := url.ParseQuery(c.Query)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	input, err := ivf.ParseInput(values)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	if f, err := ivf.FindFormula(active, input.UseOwnEggs, input.IVFUsed, input.ReasonKnown); err == nil {
+		if rate, err := ivf.CalculateSuccess(f, input); err == nil {
+			result.Active = &rate
+		}
+	}
+	f, err := ivf.FindFormula(candidate, input.UseOwnEggs, input.IVFUsed, input.ReasonKnown)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.CDCFormula = f.CDCFormula
+	if result.Candidate, err = ivf.CalculateSuccess(f, input); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Passed = math.Abs(result.Candidate-c.SuccessRate) <= referenceTolerance
+	return result
+}
+
+// csvNumberErrors reports the coefficient cells of a CDC formula CSV that aren't numbers, which ReadFormulas reads as 0.
+func csvNumberErrors(data []byte) []string {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil
+	}
+	header := records[0]
+	var errs []string
+	for i, record := range records[1:] {
+		for j := 4; j < len(record) && j < len(header); j++ {
+			if _, err := strconv.ParseFloat(record[j], 64); err != nil {
+				errs = append(errs, fmt.Sprintf("record %d: %s is not a number: %q", i+1, header[j], record[j]))
+			}
+		}
+	}
+	return errs
+}
+
+func formulaSelector(f *models.Formula) string {
+	return fmt.Sprintf("using_own_eggs=%s attempted_ivf_previously=%s is_reason_for_infertility_known=%s",
+		f.UsingOwnEggs, f.AttemptedIVFPreviously, f.IsReasonForInfertilityKnown)
+}
+
+func reportDetails(report *models.ValidationReport) string {
+	if report == nil {
+		return ""
+	}
+	return fmt.Sprintf("valid=%t formulas=%d errors=%d warnings=%d", report.Valid, report.Formulas, len(report.Errors),
+		len(report.Warnings))
+}
+
+func reportVersion(report *models.ValidationReport) int {
+	if report == nil {
+		return 0
+	}
+	return report.Version
+}
+
+// audit writes the action to the audit trail and returns err, or the error writing the entry.
+func (a *FormulaAdmin) audit(actor string, action string, set string, version int, err error, details string) error {
+	if err != nil {
+		details = "failed: " + err.Error()
+	}
+	entry := &models.AuditEntry{Actor: actor, Action: action, FormulaSet: set, Version: version, Details: details}
+	if auditErr := a.Store.AddAuditEntry(entry); auditErr != nil {
+		if a.Logger != nil {
+			a.Logger.Printf("error writing audit entry %+v: %v", entry, auditErr)
+		}
+		if err == nil {
+			return auditErr
+		}
+	}
+	return err
+}
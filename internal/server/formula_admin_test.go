@@ -0,0 +1,116 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/internal/repo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	formulasPath       = "../repo/data/ivf_success_formulas.csv"
+	referenceCasesPath = "../repo/data/reference_cases.json"
+)
+
+func newTestFormulaAdmin(t *testing.T) (*FormulaAdmin, []byte) {
+	db, err := repo.NewSQLiteFormula(&repo.SQLiteConfig{Path: filepath.Join(t.TempDir(), "formulas.db")})
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	referenceCases, err := repo.LoadReferenceCases(referenceCasesPath)
+	require.NoError(t, err)
+	data, err := os.ReadFile(formulasPath)
+	require.NoError(t, err)
+
+	return NewFormulaAdmin(&AdminConfig{Store: db, ReferenceCases: referenceCases}), data
+}
+
+func TestFormulaAdmin(t *testing.T) {
+	t.Run("Upload, activate and roll back", func(t *testing.T) {
+		admin, data := newTestFormulaAdmin(t)
+
+		report, err := admin.Upload("alice", "cdc", "cdc.csv", "csv", data)
+		require.NoError(t, err)
+		assert.True(t, report.Valid)
+		assert.Equal(t, 1, report.Version)
+		assert.Equal(t, []string{"formula set cdc has no active version to compare with"}, report.Warnings)
+		require.Len(t, report.ReferenceCases, 10)
+		for _, c := range report.ReferenceCases {
+			assert.True(t, c.Passed, c.Name)
+			assert.Nil(t, c.Active)
+		}
+
+		_, err = admin.Formulas("alice", "cdc", 0)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+		require.NoError(t, admin.Activate("alice", "cdc", 1))
+
+		report, err = admin.Upload("bob", "cdc", "cdc.csv", "csv", data)
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Version)
+		assert.Empty(t, report.Warnings)
+		require.NoError(t, admin.Activate("bob", "cdc", 2))
+
+		version, err := admin.Rollback("alice", "cdc")
+		require.NoError(t, err)
+		assert.Equal(t, 1, version)
+		_, err = admin.Rollback("alice", "cdc")
+		assert.ErrorIs(t, err, models.ErrConflict)
+
+		versions, err := admin.Versions("alice", "cdc")
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.Equal(t, []int{1, 2}, []int{versions[0].Version, versions[1].Version}, "oldest first")
+		assert.True(t, versions[0].Active)
+		assert.False(t, versions[1].Active)
+
+		entries, err := admin.AuditLog(3)
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		assert.Equal(t, "list_versions", entries[0].Action)
+		assert.Equal(t, "rollback", entries[1].Action)
+		assert.Equal(t, "failed: formula set cdc has no version older than 1: conflict", entries[1].Details)
+		assert.Equal(t, "rollback", entries[2].Action)
+		assert.Equal(t, "alice", entries[2].Actor)
+		assert.Equal(t, 1, entries[2].Version)
+	})
+
+	t.Run("Invalid upload is not stored", func(t *testing.T) {
+		admin, data := newTestFormulaAdmin(t)
+		_, err := admin.Upload("alice", "cdc", "cdc.csv", "csv", data)
+		require.NoError(t, err)
+		require.NoError(t, admin.Activate("alice", "cdc", 1))
+
+		lines := strings.Split(string(data), "\n")
+		lines[1] = strings.Replace(lines[1], "-6.8392144", "abc", 1)
+		invalid := strings.Join(append(lines[:2], lines[3:]...), "\n")
+
+		report, err := admin.Upload("alice", "cdc", "broken.csv", "csv", []byte(invalid))
+		require.NoError(t, err)
+		assert.False(t, report.Valid)
+		assert.Zero(t, report.Version)
+		assert.Equal(t, []string{
+			`record 1: formula_intercept is not a number: "abc"`,
+			"no formula applies to using_own_eggs=TRUE attempted_ivf_previously=FALSE " +
+				"is_reason_for_infertility_known=FALSE, the active formula 4-6 does",
+		}, report.Errors)
+		assert.Contains(t, report.Warnings, `reference case "README: Own eggs, no prior IVF, known reason" doesn't match`)
+
+		versions, err := admin.Versions("alice", "cdc")
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+	})
+
+	t.Run("Unknown formula set", func(t *testing.T) {
+		admin, _ := newTestFormulaAdmin(t)
+
+		_, err := admin.Versions("alice", "amh")
+		assert.ErrorIs(t, err, models.ErrNotFound)
+		err = admin.Activate("alice", "amh", 1)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+	})
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"time"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/pkg/ivf"
+)
+
+// purgeInterval is the least time between two deletions of the expired calculations.
+const purgeInterval = time.Hour
+
+// CalculationStore stores the calculation history.
+type CalculationStore interface {
+	SaveCalculation(c *models.Calculation) error
+	GetCalculation(id string) (*models.Calculation, error)
+	DeleteCalculationsBefore(t time.Time) (int64, error)
+}
+
+// FormulaVersioner is implemented by the formula repos that can tell which formulas they serve.
+type FormulaVersioner interface {
+	FormulaVersion() (string, error)
+}
+
+// Record stores the prediction in the calculation history with the normalized inputs and the version of the formula
+// the result was calculated with, and returns it with its ID.
+func (s *SuccessCalculator) Record(model string, values url.Values, result *models.IVFResult) (*models.Calculation, error) {
+	if s.History == nil {
+		return nil, fmt.Errorf("calculation history is disabled")
+	}
+	m, err := s.models.Model(model)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &models.Calculation{
+		ID:             newCalculationID(),
+		CreatedAt:      time.Now().UTC(),
+		Model:          result.Model,
+		Inputs:         map[string]string{},
+		FormulaVersion: result.FormulaVersion,
+		CDCFormula:     result.CDCFormula,
+		SuccessRate:    result.SuccessRate,
+		Precision:      result.Precision,
+		Interval:       result.Interval,
+		Explanation:    result.Explanation,
+	}
+	normalized := ivf.NormalizeValues(m.Params(), values)
+	for name := range normalized {
+		c.Inputs[name] = normalized.Get(name)
+	}
+	if err := s.History.SaveCalculation(c); err != nil {
+		return nil, err
+	}
+	s.purgeExpired(c.CreatedAt)
+	return c, nil
+}
+
+// Calculation returns the calculation with the ID. Calculations older than the retention are not found.
+func (s *SuccessCalculator) Calculation(id string) (*models.Calculation, error) {
+	if s.History == nil {
+		return nil, fmt.Errorf("calculation %s: %w", id, models.ErrNotFound)
+	}
+	c, err := s.History.GetCalculation(id)
+	if err != nil {
+		return nil, err
+	}
+	if s.Retention > 0 && c.CreatedAt.Before(time.Now().Add(-s.Retention)) {
+		return nil, fmt.Errorf("calculation %s has expired: %w", id, models.ErrNotFound)
+	}
+	return c, nil
+}
+
+// formulaVersion returns the version of the formulas the CDC model reads, empty when the repo can't tell.
+func (s *SuccessCalculator) formulaVersion(model string) (string, error) {
+	versioner, ok := s.Repo.(FormulaVersioner)
+	if model != ivf.CDCModelName || !ok {
+		return "", nil
+	}
+	return versioner.FormulaVersion()
+}
+
+// purgeExpired deletes the calculations older than the retention, at most once per purgeInterval.
+func (s *SuccessCalculator) purgeExpired(now time.Time) {
+	if s.Retention <= 0 {
+		return
+	}
+	s.purgeMu.Lock()
+	defer s.purgeMu.Unlock()
+	if now.Sub(s.lastPurge) < purgeInterval {
+		return
+	}
+	s.lastPurge = now
+
+	deleted, err := s.History.DeleteCalculationsBefore(now.Add(-s.Retention))
+	if s.Logger == nil {
+		return
+	}
+	if err != nil {
+		s.Logger.Printf("error deleting expired calculations: %v", err)
+	} else if deleted > 0 {
+		s.Logger.Printf("deleted %d calculations older than %s", deleted, s.Retention)
+	}
+}
+
+// newCalculationID returns a random ID that can't be guessed from other IDs.
+func newCalculationID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/internal/repo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculationHistory(t *testing.T) {
+	history, err := repo.NewSQLiteHistory(&repo.HistoryConfig{Path: filepath.Join(t.TempDir(), "history.db")})
+	require.NoError(t, err)
+	defer history.Close()
+	calc := NewSuccessCalculator(&Config{
+		Repo:      repo.NewIVFFormula(&repo.Config{FilePath: formulasPath}),
+		History:   history,
+		Retention: 24 * time.Hour,
+	})
+	expired := &models.Calculation{ID: "expired", CreatedAt: time.Now().Add(-25 * time.Hour)}
+	require.NoError(t, history.SaveCalculation(expired))
+
+	values, err := url.ParseQuery(readmeQuery + "&model=cdc&utm_source=widget&tubal_factor=Yes")
+	require.NoError(t, err)
+	result, err := calc.Predict("cdc", values)
+	require.NoError(t, err)
+	recorded, err := calc.Record("cdc", values, result)
+	require.NoError(t, err)
+
+	assert.Len(t, recorded.ID, 32)
+	assert.Equal(t, "cdc", recorded.Model)
+	assert.Len(t, recorded.Inputs, 17, "only the model inputs are kept")
+	assert.Equal(t, "No", recorded.Inputs["tubal_factor"], "the first value is the one calculated with")
+	assert.NotContains(t, recorded.Inputs, "utm_source")
+	assert.Regexp(t, `^ivf_success_formulas\.csv@[0-9a-f]{12}$`, recorded.FormulaVersion)
+	assert.Equal(t, result.FormulaVersion, recorded.FormulaVersion)
+	assert.Equal(t, "1-3", recorded.CDCFormula)
+	assert.Equal(t, 62.21, recorded.SuccessRate)
+	require.NotNil(t, recorded.Explanation)
+	assert.Len(t, recorded.Explanation.Terms, 15)
+
+	c, err := calc.Calculation(recorded.ID)
+	require.NoError(t, err)
+	assert.Equal(t, recorded.ID, c.ID)
+	assert.Equal(t, recorded.Inputs, c.Inputs)
+	assert.Equal(t, recorded.SuccessRate, c.SuccessRate)
+	assert.True(t, recorded.CreatedAt.Equal(c.CreatedAt))
+
+	// an activation between the prediction and the record doesn't change the version the result was calculated with
+	earlier := *result
+	earlier.FormulaVersion = "ivf_success_formulas.csv@000000000000"
+	recorded, err = calc.Record("cdc", values, &earlier)
+	require.NoError(t, err)
+	assert.Equal(t, "ivf_success_formulas.csv@000000000000", recorded.FormulaVersion)
+
+	_, err = calc.Calculation("expired")
+	assert.ErrorIs(t, err, models.ErrNotFound)
+	_, err = history.GetCalculation("expired")
+	assert.ErrorIs(t, err, models.ErrNotFound, "expired calculations are deleted")
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"ivf_calculator/internal/models"
+)
+
+// shadowBuckets are the upper bounds, in percentage points, of the difference histogram. The last bucket is open.
+var shadowBuckets = []float64{-5, -1, -0.1, 0.1, 1, 5}
+
+// shadowStats accumulates the differences between the candidate and the active success rates.
+type shadowStats struct {
+	mu       sync.Mutex
+	since    time.Time
+	requests int
+	errors   int
+	formulas map[string]*shadowFormulaStats
+}
+
+type shadowFormulaStats struct {
+	requests int
+	// mean and m2 are updated with Welford's algorithm
+	mean, m2, sumAbs, min, max float64
+	candidates                 map[string]int
+	histogram                  []int
+}
+
+func newShadowStats() *shadowStats {
+	return &shadowStats{since: time.Now().UTC(), formulas: map[string]*shadowFormulaStats{}}
+}
+
+func (s *shadowStats) add(active *models.IVFResult, candidate *models.IVFResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	if err != nil {
+		s.errors++
+		return
+	}
+	f, ok := s.formulas[active.CDCFormula]
+	if !ok {
+		f = &shadowFormulaStats{candidates: map[string]int{}, histogram: make([]int, len(shadowBuckets)+1)}
+		s.formulas[active.CDCFormula] = f
+	}
+
+	diff := candidate.SuccessRate - active.SuccessRate
+	f.requests++
+	delta := diff - f.mean
+	f.mean += delta / float64(f.requests)
+	f.m2 += delta * (diff - f.mean)
+	f.sumAbs += math.Abs(diff)
+	if f.requests == 1 || diff < f.min {
+		f.min = diff
+	}
+	if f.requests == 1 || diff > f.max {
+		f.max = diff
+	}
+	f.candidates[candidate.CDCFormula]++
+	f.histogram[sort.SearchFloat64s(shadowBuckets, diff)]++
+}
+
+func (s *shadowStats) report(candidate string) *models.ShadowReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := &models.ShadowReport{
+		Candidate: candidate,
+		Since:     s.since.Format(time.RFC3339),
+		Requests:  s.requests,
+		Errors:    s.errors,
+		Formulas:  []models.ShadowFormula{},
+	}
+	for id, f := range s.formulas {
+		formula := models.ShadowFormula{
+			CDCFormula:             id,
+			Requests:               f.requests,
+			MeanDifference:         f.mean,
+			MeanAbsoluteDifference: f.sumAbs / float64(f.requests),
+			StdDevDifference:       math.Sqrt(f.m2 / float64(f.requests)),
+			MinDifference:          f.min,
+			MaxDifference:          f.max,
+			CandidateFormulas:      map[string]int{},
+		}
+		for c, n := range f.candidates {
+			formula.CandidateFormulas[c] = n
+		}
+		for i, n := range f.histogram {
+			formula.Histogram = append(formula.Histogram, models.ShadowBucket{Range: bucketRange(i), Count: n})
+		}
+		report.Formulas = append(report.Formulas, formula)
+	}
+	sort.Slice(report.Formulas, func(i, j int) bool {
+		return report.Formulas[i].CDCFormula < report.Formulas[j].CDCFormula
+	})
+	return report
+}
+
+// bucketRange describes the differences counted by the histogram bucket.
+func bucketRange(i int) string {
+	switch i {
+	case 0:
+		return fmt.Sprintf("<= %g", shadowBuckets[0])
+	case len(shadowBuckets):
+		return fmt.Sprintf("> %g", shadowBuckets[i-1])
+	default:
+		return fmt.Sprintf("%g to %g", shadowBuckets[i-1], shadowBuckets[i])
+	}
+}
+
+// shadowPredict evaluates the request with the candidate formulas and records the difference with the active result.
+func (s *SuccessCalculator) shadowPredict(values url.Values, active *models.IVFResult) {
+	candidate, err := s.shadowModel.Predict(values)
+	if err != nil && s.Logger != nil {
+		s.Logger.Printf("shadow formulas %s: %v", s.ShadowName, err)
+	}
+	s.shadow.add(active, candidate, err)
+}
+
+// ShadowReport summarizes the differences between the candidate and the active success rates since the start.
+// It returns nil when there is no candidate.
+func (s *SuccessCalculator) ShadowReport() *models.ShadowReport {
+	if s.shadow == nil {
+		return nil
+	}
+	return s.shadow.report(s.ShadowName)
+}
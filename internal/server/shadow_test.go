@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ivf_calculator/internal/models"
+	"ivf_calculator/internal/repo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const readmeQuery = "age=32&weight=150&feet=5&inches=8&ivf_used=0&gravida=1&tubal_factor=No" +
+	"&male_factor_infertility=No&endometriosis=Yes&ovulatory_disorder=Yes&diminished_ovarian_reserve=No" +
+	"&uterine_factor=No&other_reason=No&unexplained_infertility=No&donotknow=No&eggSource=Own&previous_live_births=1"
+
+// writeCandidate writes the CDC formulas with the intercept of formula 1-3 replaced, dropping formula 4-6.
+func writeCandidate(t *testing.T, intercept string) string {
+	data, err := os.ReadFile(formulasPath)
+	require.NoError(t, err)
+	lines := strings.Split(string(data), "\n")
+	lines[1] = strings.Replace(lines[1], "-6.8392144", intercept, 1)
+	path := filepath.Join(t.TempDir(), "candidate.csv")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(append(lines[:2], lines[3:]...), "\n")), 0o644))
+	return path
+}
+
+func TestShadowMode(t *testing.T) {
+	active := repo.NewIVFFormula(&repo.Config{FilePath: formulasPath})
+	candidate := repo.NewIVFFormula(&repo.Config{FilePath: writeCandidate(t, "-6.7392144")})
+	calc := NewSuccessCalculator(&Config{Repo: active, Shadow: candidate, ShadowName: "candidate.csv"})
+	values, err := url.ParseQuery(readmeQuery)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		result, err := calc.Predict("", values)
+		require.NoError(t, err)
+		assert.Equal(t, 62.21, result.SuccessRate, "only the active result is returned")
+	}
+	values.Set("donotknow", "Yes")
+	values.Set("endometriosis", "No")
+	values.Set("ovulatory_disorder", "No")
+	_, err = calc.Predict("cdc", values)
+	require.NoError(t, err, "the candidate failing doesn't fail the request")
+
+	report := calc.ShadowReport()
+	assert.Equal(t, "candidate.csv", report.Candidate)
+	assert.Equal(t, 3, report.Requests)
+	assert.Equal(t, 1, report.Errors)
+	require.Len(t, report.Formulas, 1)
+	f := report.Formulas[0]
+	assert.Equal(t, "1-3", f.CDCFormula)
+	assert.Equal(t, 2, f.Requests)
+	assert.InDelta(t, 2.32, f.MeanDifference, 1e-9)
+	assert.InDelta(t, 2.32, f.MeanAbsoluteDifference, 1e-9)
+	assert.InDelta(t, 0, f.StdDevDifference, 1e-9)
+	assert.InDelta(t, 2.32, f.MinDifference, 1e-9)
+	assert.InDelta(t, 2.32, f.MaxDifference, 1e-9)
+	assert.Equal(t, map[string]int{"1-3": 2}, f.CandidateFormulas)
+	assert.Equal(t, []models.ShadowBucket{
+		{Range: "<= -5", Count: 0},
+		{Range: "-5 to -1", Count: 0},
+		{Range: "-1 to -0.1", Count: 0},
+		{Range: "-0.1 to 0.1", Count: 0},
+		{Range: "0.1 to 1", Count: 0},
+		{Range: "1 to 5", Count: 2},
+		{Range: "> 5", Count: 0},
+	}, f.Histogram)
+}
+
+func TestShadowModeOff(t *testing.T) {
+	calc := NewSuccessCalculator(&Config{Repo: repo.NewIVFFormula(&repo.Config{FilePath: formulasPath})})
+
+	assert.Nil(t, calc.ShadowReport())
+}
@@ -1,93 +1,166 @@
 package server
 
 import (
+	"fmt"
 	"log"
-	"math"
+	"net/url"
+	"sync"
+	"time"
 
 	"ivf_calculator/internal/models"
+	"ivf_calculator/pkg/ivf"
 )
 
 type Config struct {
 	Repo   FormulaGetter
 	Logger *log.Logger
+	// Models are the prediction models Predict selects from. Defaults to the CDC model reading formulas from Repo.
+	Models *ivf.Registry
+	// Shadow is the candidate formula set evaluated next to the CDC model in shadow mode. Only the active result is
+	// returned; the differences are summarized by ShadowReport.
+	Shadow FormulaGetter
+	// ShadowName describes the candidate in the report, e.g. its file or version.
+	ShadowName string
+	// History stores the calculations passed to Record. Recording is disabled when it is nil.
+	History CalculationStore
+	// Retention is how long calculations are kept in the history. Zero keeps them forever.
+	Retention time.Duration
+	// CacheSize is the number of predictions kept in the LRU result cache. Zero disables the cache. Only the
+	// predictions of repos implementing FormulaVersioner are cached, and the cache is emptied when the version changes.
+	CacheSize int
 }
 
 type FormulaGetter interface {
 	GetFormula(usingOwnEggs string, attemptedIVFPreviously string, isReasonKnown string) (*models.Formula, error)
+	GetFormulas() ([]*models.Formula, error)
 }
 
 type SuccessCalculator struct {
 	*Config
+	models      *ivf.Registry
+	shadowModel ivf.PredictionModel
+	shadow      *shadowStats
+	cache       *resultCache
+
+	purgeMu   sync.Mutex
+	lastPurge time.Time
 }
 
 func NewSuccessCalculator(config *Config) *SuccessCalculator {
-	return &SuccessCalculator{
-		config,
+	s := &SuccessCalculator{
+		Config: config,
+		models: config.Models,
+	}
+	if s.models == nil {
+		// a single model can't clash with another one
+		s.models, _ = ivf.NewRegistry(ivf.NewCDCModel(config.Repo))
+	}
+	if config.CacheSize > 0 {
+		s.cache = newResultCache(config.CacheSize)
+	}
+	if config.Shadow != nil {
+		s.shadowModel = ivf.NewCDCModel(config.Shadow)
+		s.shadow = newShadowStats()
 	}
+	return s
 }
 
 // CalculateSuccess calculates the success probability using the formula
-func (s *SuccessCalculator) CalculateSuccess(params *models.IVFInput) (float64, error) {
+func (s *SuccessCalculator) CalculateSuccess(params *models.IVFInput) (*models.IVFResult, error) {
 	f, err := s.Repo.GetFormula(params.UseOwnEggs, params.IVFUsed, params.ReasonKnown)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	bmi := s.CalculateBMI(params)
-	age := float64(params.Age)
-
-	// Base calculation
-	score := f.Coefficients.Intercept +
-		f.Coefficients.AgeLinear*age +
-		f.Coefficients.AgePower*math.Pow(age, f.Coefficients.AgePowerFactor) +
-		f.Coefficients.BMILinear*bmi +
-		f.Coefficients.BMIPower*math.Pow(bmi, f.Coefficients.BMIPowerFactor)
+	explanation, err := ivf.Explain(f, params)
+	if err != nil {
+		return nil, err
+	}
+	return &models.IVFResult{
+		Model:       ivf.CDCModelName,
+		SuccessRate: explanation.SuccessRate,
+		CDCFormula:  f.CDCFormula,
+		Precision:   explanation.Precision,
+		Interval:    explanation.Interval,
+	}, nil
+}
 
-	// Add boolean parameters if they exist in the input
-	booleanFactors := map[string]*map[bool]float64{
-		"tubalFactor":              &f.Coefficients.TubalFactor,
-		"maleFactorInfertility":    &f.Coefficients.MaleFactorInfertility,
-		"endometriosis":            &f.Coefficients.Endometriosis,
-		"ovulatoryDisorder":        &f.Coefficients.OvulatoryDisorder,
-		"diminishedOvarianReserve": &f.Coefficients.DiminishedOvarianReserve,
-		"uterineFactor":            &f.Coefficients.UterineFactor,
-		"otherReason":              &f.Coefficients.OtherReason,
-		"unexplainedInfertility":   &f.Coefficients.UnexplainedInfertility,
+// Predict calculates the success probability with the named prediction model, the default one when the name is empty.
+func (s *SuccessCalculator) Predict(model string, values url.Values) (*models.IVFResult, error) {
+	m, err := s.models.Model(model)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.cachedPredict(m, values)
+	if err == nil && s.shadow != nil && m.Name() == ivf.CDCModelName {
+		s.shadowPredict(values, result)
 	}
+	return result, err
+}
 
-	for key, coeffMap := range booleanFactors {
-		if val, exists := params.Coefficients[key]; exists {
-			if boolVal, ok := val.(bool); ok {
-				coef := (*coeffMap)[boolVal]
-				score += coef
-			}
-		}
+// ModelParams returns the inputs the named prediction model reads, those of the default one when the name is empty.
+func (s *SuccessCalculator) ModelParams(model string) ([]ivf.Param, error) {
+	m, err := s.models.Model(model)
+	if err != nil {
+		return nil, err
 	}
+	return m.Params(), nil
+}
 
-	// Add numeric parameters
-	if val, exists := params.Coefficients["priorPregnancies"]; exists {
-		if strVal, ok := val.(string); ok {
-			score += f.Coefficients.PriorPregnancies[strVal]
-		}
+// cachedPredict returns the cached prediction of the values, or predicts and caches it. Errors are never cached.
+func (s *SuccessCalculator) cachedPredict(m ivf.PredictionModel, values url.Values) (*models.IVFResult, error) {
+	if s.cache == nil {
+		return m.Predict(values)
+	}
+	key := s.calculationKey(m, values)
+	if key == "" {
+		return m.Predict(values)
+	}
+	if result, ok := s.cache.get(key); ok {
+		return result, nil
 	}
+	result, err := m.Predict(values)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.add(key, result)
+	return result, nil
+}
+
+func (s *SuccessCalculator) CalculateBMI(params *models.IVFInput) float64 {
+	return ivf.CalculateBMI(params)
+}
+
+// CalculateCumulative projects the cumulative success probability over the given number of cycles.
+func (s *SuccessCalculator) CalculateCumulative(params *models.IVFInput, cycles int, monthsBetween int) (*ivf.Projection, error) {
+	return ivf.ProjectCycles(s.Repo, params, cycles, monthsBetween)
+}
 
-	if val, exists := params.Coefficients["priorLiveBirths"]; exists {
-		if strVal, ok := val.(string); ok {
-			score += f.Coefficients.PriorLiveBirths[strVal]
-		}
+// CompareScenarios calculates the success probability under every egg source and prior IVF formula
+// that applies to the patient's infertility reason.
+func (s *SuccessCalculator) CompareScenarios(params *models.IVFInput) ([]ivf.Scenario, error) {
+	formulas, err := s.Repo.GetFormulas()
+	if err != nil {
+		return nil, err
 	}
 
-	// calculate success rate in %
-	successRate := 1.0 / (1.0 + math.Exp(-score)) * 100
-	// round to 2 decimal digits
-	successRate = math.Round(successRate*100) / 100
+	scenarios, err := ivf.CompareScenarios(formulas, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(scenarios) == 0 {
+		return nil, fmt.Errorf("no matching formula found for the given parameters")
+	}
+	return scenarios, nil
+}
 
-	return successRate, nil
+// CalculateAgeCurve calculates the success probability for every age in the range, with the other inputs fixed.
+func (s *SuccessCalculator) CalculateAgeCurve(params *models.IVFInput, fromAge int, toAge int, step int) (*ivf.AgeCurve, error) {
+	return ivf.CalculateAgeCurve(s.Repo, params, fromAge, toAge, step)
 }
 
-func (s *SuccessCalculator) CalculateBMI(params *models.IVFInput) float64 {
-	bmi := float64(params.Weight) / math.Pow(float64(params.Feet*12)+float64(params.Inches), 2) * 703
-	// round to single decimal to match assignment results.
-	bmi = math.Round(bmi*10) / 10
-	return bmi
+// WhatIfBMI predicts the change in success probability at the target BMI and, when optimize is set,
+// finds the BMI that maximizes it.
+func (s *SuccessCalculator) WhatIfBMI(params *models.IVFInput, target *ivf.BMITarget, optimize bool) (*ivf.BMIWhatIf, error) {
+	return ivf.WhatIfBMI(s.Repo, params, target, optimize)
 }
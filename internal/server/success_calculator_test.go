@@ -23,12 +23,20 @@ func (m *MockFormulaGetter) GetFormula(usingOwnEggs string, attemptedIVFPrevious
 	return args.Get(0).(*models.Formula), args.Error(1)
 }
 
+func (m *MockFormulaGetter) GetFormulas() ([]*models.Formula, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Formula), args.Error(1)
+}
+
 func TestNewSuccessCalculator(t *testing.T) {
 	repo := new(MockFormulaGetter)
-	calc := NewSuccessCalculator(repo)
+	calc := NewSuccessCalculator(&Config{Repo: repo})
 
 	assert.NotNil(t, calc)
-	assert.Equal(t, repo, calc.repo)
+	assert.Equal(t, repo, calc.Repo)
 }
 
 func TestCalculateBMI(t *testing.T) {
@@ -57,7 +65,7 @@ func TestCalculateBMI(t *testing.T) {
 		},
 	}
 
-	calc := NewSuccessCalculator(nil) // repo not needed for BMI calculation
+	calc := NewSuccessCalculator(&Config{}) // repo not needed for BMI calculation
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -191,7 +199,7 @@ func TestCalculateSuccess(t *testing.T) {
 			repo.On("GetFormula", tt.input.UseOwnEggs, tt.input.IVFUsed, tt.input.ReasonKnown).
 				Return(tt.mockFormula, tt.mockError)
 
-			calc := NewSuccessCalculator(repo)
+			calc := NewSuccessCalculator(&Config{Repo: repo})
 			result, err := calc.CalculateSuccess(tt.input)
 
 			if tt.expectedError != nil {
@@ -199,7 +207,8 @@ func TestCalculateSuccess(t *testing.T) {
 				assert.Equal(t, tt.expectedError.Error(), err.Error())
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.expected, result)
+				assert.Equal(t, tt.expected, result.SuccessRate)
+				assert.Equal(t, tt.mockFormula.CDCFormula, result.CDCFormula)
 			}
 			repo.AssertExpectations(t)
 		})
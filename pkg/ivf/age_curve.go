@@ -0,0 +1,44 @@
+package ivf
+
+// AgePoint is the success rate at one age with every other input fixed.
+type AgePoint struct {
+	Age         int     `json:"age"`
+	SuccessRate float64 `json:"success_rate"`
+}
+
+// AgeCurve is the success rate over a range of ages, for charting.
+type AgeCurve struct {
+	CDCFormula string     `json:"cdc_formula"`
+	Points     []AgePoint `json:"points"`
+}
+
+// CalculateAgeCurve holds every input but the age fixed and calculates the success rate for each age from
+// fromAge to toAge in step year increments. Both ages must be in the range accepted for the age input.
+func CalculateAgeCurve(repo FormulaGetter, params *Input, fromAge int, toAge int, step int) (*AgeCurve, error) {
+	age := lookupParam("age")
+	if fromAge < *age.Minimum || fromAge > *age.Maximum {
+		return nil, inputErrorf("from_age", "from_age must be between %d and %d. Got %d", *age.Minimum, *age.Maximum, fromAge)
+	}
+	if toAge < fromAge || toAge > *age.Maximum {
+		return nil, inputErrorf("to_age", "to_age must be between %d and %d. Got %d", fromAge, *age.Maximum, toAge)
+	}
+	if step < 1 {
+		return nil, inputErrorf("step", "step must be at least 1. Got %d", step)
+	}
+
+	f, err := repo.GetFormula(params.UseOwnEggs, params.IVFUsed, params.ReasonKnown)
+	if err != nil {
+		return nil, err
+	}
+
+	curve := &AgeCurve{CDCFormula: f.CDCFormula}
+	point := *params
+	for point.Age = fromAge; point.Age <= toAge; point.Age += step {
+		rate, err := CalculateSuccess(f, &point)
+		if err != nil {
+			return nil, err
+		}
+		curve.Points = append(curve.Points, AgePoint{Age: point.Age, SuccessRate: rate})
+	}
+	return curve, nil
+}
@@ -0,0 +1,162 @@
+package ivf
+
+import (
+	"fmt"
+	"math"
+)
+
+// BMIOutcome is the success rate at one BMI with every other input fixed.
+type BMIOutcome struct {
+	BMI float64 `json:"bmi"`
+	// Weight is the weight in pounds giving this BMI at the patient's height.
+	Weight      float64 `json:"weight"`
+	SuccessRate float64 `json:"success_rate"`
+	// Change is the difference with the current success rate, in percentage points.
+	Change float64 `json:"change"`
+}
+
+// BMITarget is the weight or BMI to evaluate in a what-if analysis. Only one of them may be set.
+type BMITarget struct {
+	Weight int
+	BMI    float64
+}
+
+// BMIWhatIf compares the current success rate with the rate at a target BMI and at the best BMI for the formula.
+type BMIWhatIf struct {
+	CDCFormula string      `json:"cdc_formula"`
+	Current    BMIOutcome  `json:"current"`
+	Target     *BMIOutcome `json:"target,omitempty"`
+	Optimal    *BMIOutcome `json:"optimal,omitempty"`
+}
+
+// WhatIfBMI predicts the change in success rate when the BMI changes to the target, if any, and, when optimize is
+// set, finds the BMI that maximizes the prediction. BMIs are limited to the range the weight input allows at the
+// patient's height.
+func WhatIfBMI(repo FormulaGetter, params *Input, target *BMITarget, optimize bool) (*BMIWhatIf, error) {
+	height := float64(params.Feet*12 + params.Inches)
+	if height <= 0 {
+		return nil, inputErrorf("feet", "feet and inches are required to calculate the BMI")
+	}
+	weight := lookupParam("weight")
+	precision := params.Precision
+	minBMI := precision.roundBMI(bmiForWeight(*weight.Minimum, height))
+	maxBMI := precision.roundBMI(bmiForWeight(*weight.Maximum, height))
+
+	f, err := repo.GetFormula(params.UseOwnEggs, params.IVFUsed, params.ReasonKnown)
+	if err != nil {
+		return nil, err
+	}
+
+	current := CalculateBMI(params)
+	currentRate, err := CalculateSuccess(f, params)
+	if err != nil {
+		return nil, err
+	}
+	outcome := func(bmi float64) *BMIOutcome {
+		// the terms compiled for the current rate
+		explanation, _ := ExplainWithBMI(f, params, bmi)
+		rate := explanation.SuccessRate
+		return &BMIOutcome{
+			BMI:         bmi,
+			Weight:      precision.round(bmi*height*height/703, 1),
+			SuccessRate: rate,
+			Change:      precision.roundRate(rate - currentRate),
+		}
+	}
+
+	whatIf := &BMIWhatIf{
+		CDCFormula: f.CDCFormula,
+		Current:    BMIOutcome{BMI: current, Weight: float64(params.Weight), SuccessRate: currentRate},
+	}
+
+	if target != nil {
+		switch {
+		case target.Weight != 0 && target.BMI != 0:
+			return nil, inputErrorf("target_bmi", "only one of target_weight or target_bmi can be set")
+		case target.Weight != 0:
+			if target.Weight < *weight.Minimum || target.Weight > *weight.Maximum {
+				return nil, inputErrorf("target_weight", "target_weight must be between %d and %d. Got %d",
+					*weight.Minimum, *weight.Maximum, target.Weight)
+			}
+			t := *params
+			t.Weight = target.Weight
+			whatIf.Target = outcome(CalculateBMI(&t))
+			whatIf.Target.Weight = float64(target.Weight)
+		case target.BMI != 0:
+			// NaN compares false with both bounds
+			if math.IsNaN(target.BMI) || target.BMI < minBMI || target.BMI > maxBMI {
+				return nil, inputErrorf("target_bmi", "target_bmi must be between %.1f and %.1f at this height. Got %g",
+					minBMI, maxBMI, target.BMI)
+			}
+			whatIf.Target = outcome(precision.roundBMI(target.BMI))
+		}
+	}
+
+	if optimize {
+		bmi, err := optimalBMI(f, minBMI, maxBMI, precision)
+		if err != nil {
+			return nil, err
+		}
+		whatIf.Optimal = outcome(bmi)
+	}
+
+	return whatIf, nil
+}
+
+// optimalBMI returns the BMI in [minBMI, maxBMI] maximizing the BMI terms of the formula, which must add up to
+// linear*bmi + power*bmi^k: any number of linear terms and power terms sharing a single exponent. The maximum is either
+// at a bound or where the derivative is zero: linear + power*k*bmi^(k-1) = 0. Formulas with power terms of several
+// exponents are rejected.
+func optimalBMI(f *Formula, minBMI float64, maxBMI float64, precision Precision) (float64, error) {
+	terms, err := f.terms()
+	if err != nil {
+		return 0, err
+	}
+	var linear, power, k float64
+	for _, t := range terms {
+		if t.Input != "bmi" || t.Coefficient == 0 {
+			continue
+		}
+		switch {
+		case t.Kind == TermLinear || (t.Kind == TermPower && t.Exponent == 1):
+			linear += t.Coefficient
+		case t.Kind == TermPower && t.Exponent == 0:
+			// bmi^0 is a constant
+		case t.Kind == TermPower:
+			if power != 0 && t.Exponent != k {
+				return 0, fmt.Errorf("formula %s has BMI power terms with several exponents, the optimal BMI can't "+
+					"be calculated", f.CDCFormula)
+			}
+			power += t.Coefficient
+			k = t.Exponent
+		}
+	}
+	score := func(bmi float64) float64 {
+		return linear*bmi + power*math.Pow(bmi, k)
+	}
+
+	candidates := []float64{minBMI, maxBMI}
+	if power != 0 {
+		stationary := math.Pow(-linear/(power*k), 1/(k-1))
+		switch {
+		case math.IsNaN(stationary) || stationary <= minBMI || stationary >= maxBMI:
+		case precision == PrecisionExact:
+			candidates = append(candidates, stationary)
+		default:
+			// BMI is rounded to a single decimal, so check both neighbours of the exact optimum.
+			candidates = append(candidates, math.Floor(stationary*10)/10, math.Ceil(stationary*10)/10)
+		}
+	}
+
+	best := candidates[0]
+	for _, bmi := range candidates[1:] {
+		if score(bmi) > score(best) {
+			best = bmi
+		}
+	}
+	return best, nil
+}
+
+func bmiForWeight(weight int, height float64) float64 {
+	return float64(weight) / (height * height) * 703
+}
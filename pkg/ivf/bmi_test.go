@@ -0,0 +1,42 @@
+package ivf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptimalBMI(t *testing.T) {
+	bmiFormula := func(terms ...FormulaTerm) *Formula {
+		f := &Formula{CDCFormula: "custom", Terms: append([]FormulaTerm{{Name: "intercept", Kind: TermConstant}}, terms...)}
+		require.NoError(t, f.Compile())
+		return f
+	}
+	// 0.2*bmi - 0.004*bmi^2 is maximal at bmi 25
+	single := bmiFormula(
+		FormulaTerm{Name: "bmi_linear", Kind: TermLinear, Input: "bmi", Coefficient: 0.2},
+		FormulaTerm{Name: "bmi_power", Kind: TermPower, Input: "bmi", Coefficient: -0.004, Exponent: 2})
+	split := bmiFormula(
+		FormulaTerm{Name: "bmi_linear_a", Kind: TermLinear, Input: "bmi", Coefficient: 0.15},
+		FormulaTerm{Name: "bmi_linear_b", Kind: TermPower, Input: "bmi", Coefficient: 0.05, Exponent: 1},
+		FormulaTerm{Name: "bmi_power_a", Kind: TermPower, Input: "bmi", Coefficient: -0.003, Exponent: 2},
+		FormulaTerm{Name: "bmi_power_b", Kind: TermPower, Input: "bmi", Coefficient: -0.001, Exponent: 2})
+
+	for _, f := range []*Formula{single, split} {
+		bmi, err := optimalBMI(f, 15, 40, PrecisionExact)
+		require.NoError(t, err)
+		assert.InDelta(t, 25, bmi, 1e-9)
+	}
+
+	bmi, err := optimalBMI(split, 26, 40, PrecisionCDC)
+	require.NoError(t, err)
+	assert.Equal(t, 26.0, bmi, "the optimum outside the range is at the closest bound")
+
+	cubic := bmiFormula(
+		FormulaTerm{Name: "bmi_linear", Kind: TermLinear, Input: "bmi", Coefficient: 0.2},
+		FormulaTerm{Name: "bmi_square", Kind: TermPower, Input: "bmi", Coefficient: -0.004, Exponent: 2},
+		FormulaTerm{Name: "bmi_cube", Kind: TermPower, Input: "bmi", Coefficient: 0.00001, Exponent: 3})
+	_, err = optimalBMI(cubic, 15, 40, PrecisionExact)
+	assert.EqualError(t, err, "formula custom has BMI power terms with several exponents, the optimal BMI can't be calculated")
+}
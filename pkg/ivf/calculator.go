@@ -0,0 +1,63 @@
+package ivf
+
+import "math"
+
+// Result holds the calculated success rate and the formula used to calculate it.
+type Result struct {
+	// Model is the name of the prediction model the result was calculated with.
+	Model       string
+	SuccessRate float64
+	CDCFormula  string
+	// Precision is the rounding the result was calculated with.
+	Precision Precision
+	// Interval is the confidence interval of the success rate, set when the formula has a coefficient covariance.
+	Interval *Interval
+	// Explanation is the term breakdown of the success rate, set by the models that have one.
+	Explanation *Explanation
+	// FormulaVersion is the Version of the formula the result was calculated with.
+	FormulaVersion string
+}
+
+// Calculate selects the formula matching the input and calculates the success rate with it.
+func Calculate(formulas []*Formula, params *Input) (*Result, error) {
+	f, err := FindFormula(formulas, params.UseOwnEggs, params.IVFUsed, params.ReasonKnown)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation, err := Explain(f, params)
+	if err != nil {
+		return nil, err
+	}
+	return newCDCResult(f, explanation), nil
+}
+
+func newCDCResult(f *Formula, explanation *Explanation) *Result {
+	return &Result{
+		Model:       CDCModelName,
+		SuccessRate: explanation.SuccessRate,
+		CDCFormula:  f.CDCFormula,
+		Precision:   explanation.Precision,
+		Interval:    explanation.Interval,
+		Explanation: explanation,
+		// the version of the formula used, not of the repo when the result is recorded
+		FormulaVersion: f.Version,
+	}
+}
+
+// CalculateSuccess calculates the success probability, in percents, using the formula
+func CalculateSuccess(f *Formula, params *Input) (float64, error) {
+	explanation, err := Explain(f, params)
+	if err != nil {
+		return 0, err
+	}
+	return explanation.SuccessRate, nil
+}
+
+// CalculateBMI calculates the body mass index from the weight in pounds and the height in feet and inches.
+// It is rounded to a single decimal unless the input precision is exact.
+func CalculateBMI(params *Input) float64 {
+	bmi := float64(params.Weight) / math.Pow(float64(params.Feet*12)+float64(params.Inches), 2) * 703
+	// round to single decimal to match assignment results.
+	return params.Precision.roundBMI(bmi)
+}
@@ -0,0 +1,82 @@
+package ivf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const formulasPath = "../../internal/repo/data/ivf_success_formulas.csv"
+
+func TestCalculate(t *testing.T) {
+	formulas, err := LoadFormulas(formulasPath)
+	require.NoError(t, err)
+	require.Len(t, formulas, 6)
+
+	tests := []struct {
+		name            string
+		patient         *Patient
+		expectedRate    float64
+		expectedFormula string
+	}{
+		{
+			name: "Own eggs, no prior IVF, known reason",
+			patient: &Patient{Age: 32, Weight: 150, Feet: 5, Inches: 8, IVFUsed: "0", Gravida: "1", PreviousLiveBirths: "1",
+				Endometriosis: true, OvulatoryDisorder: true, EggSource: EggSourceOwn},
+			expectedRate:    62.21,
+			expectedFormula: "1-3",
+		},
+		{
+			name: "Donor eggs, unknown reason",
+			patient: &Patient{Age: 32, Weight: 150, Feet: 5, Inches: 8, IVFUsed: "2", Gravida: "1", PreviousLiveBirths: "1",
+				DoNotKnow: true, EggSource: EggSourceDonor},
+			expectedRate:    55.8,
+			expectedFormula: "14-16",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, err := NewInput(tt.patient)
+			require.NoError(t, err)
+
+			result, err := Calculate(formulas, input)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedRate, result.SuccessRate)
+			assert.Equal(t, tt.expectedFormula, result.CDCFormula)
+		})
+	}
+}
+
+func TestNewInputError(t *testing.T) {
+	_, err := NewInput(&Patient{Age: 32, Weight: 150, Feet: 5, Inches: 8, IVFUsed: "0", Gravida: "0",
+		PreviousLiveBirths: "1", DoNotKnow: true, EggSource: EggSourceOwn})
+
+	var inputErr *InputError
+	require.True(t, errors.As(err, &inputErr))
+	assert.Equal(t, "previous_live_births", inputErr.Param)
+	assert.Equal(t, "previous_live_births can't be greater then gravida", inputErr.Error())
+}
+
+func TestFindFormulaNoMatch(t *testing.T) {
+	formulas, err := LoadFormulas(formulasPath)
+	require.NoError(t, err)
+
+	_, err = FindFormula(formulas, "", "FALSE", "TRUE")
+
+	assert.EqualError(t, err, "no matching formula found for the given parameters")
+}
+
+func TestCalculateBMIPrecision(t *testing.T) {
+	input := &Input{Weight: 150, Feet: 5, Inches: 8}
+	assert.Equal(t, 22.8, CalculateBMI(input))
+
+	input.Precision = PrecisionCDC
+	assert.Equal(t, 22.8, CalculateBMI(input))
+
+	input.Precision = PrecisionExact
+	assert.InDelta(t, 22.80493079584775, CalculateBMI(input), 1e-12)
+}
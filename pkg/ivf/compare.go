@@ -0,0 +1,57 @@
+package ivf
+
+// Scenario is the success rate of the patient under one egg source and prior IVF combination.
+type Scenario struct {
+	EggSource string `json:"egg_source"`
+	// AttemptedIVFPreviously is "Yes", "No" or "N/A" for donor eggs, where prior IVF is not a formula input.
+	AttemptedIVFPreviously string  `json:"attempted_ivf_previously"`
+	CDCFormula             string  `json:"cdc_formula"`
+	SuccessRate            float64 `json:"success_rate"`
+	// MatchesInput is true for the scenario of the egg source and prior IVF given in the input.
+	MatchesInput bool `json:"matches_input"`
+}
+
+// CompareScenarios evaluates the patient under every formula for the patient's infertility reason,
+// one per egg source and prior IVF combination, in the order the formulas are given.
+func CompareScenarios(formulas []*Formula, params *Input) ([]Scenario, error) {
+	var scenarios []Scenario
+	for _, f := range formulas {
+		if f.IsReasonForInfertilityKnown != params.ReasonKnown {
+			continue
+		}
+
+		scenario := *params
+		scenario.UseOwnEggs = f.UsingOwnEggs
+		scenario.IVFUsed = f.AttemptedIVFPreviously
+		rate, err := CalculateSuccess(f, &scenario)
+		if err != nil {
+			return nil, err
+		}
+		scenarios = append(scenarios, Scenario{
+			EggSource:              eggSourceValue(f.UsingOwnEggs),
+			AttemptedIVFPreviously: formulaFlagValue(f.AttemptedIVFPreviously),
+			CDCFormula:             f.CDCFormula,
+			SuccessRate:            rate,
+			MatchesInput:           f.UsingOwnEggs == params.UseOwnEggs && f.AttemptedIVFPreviously == params.IVFUsed,
+		})
+	}
+	return scenarios, nil
+}
+
+func eggSourceValue(usingOwnEggs string) string {
+	if usingOwnEggs == "FALSE" {
+		return EggSourceDonor
+	}
+	return EggSourceOwn
+}
+
+// formulaFlagValue converts a TRUE/FALSE/N/A formula parameter into the Yes/No/N/A form value.
+func formulaFlagValue(flag string) string {
+	switch flag {
+	case "TRUE":
+		return "Yes"
+	case "FALSE":
+		return "No"
+	}
+	return flag
+}
@@ -0,0 +1,72 @@
+package ivf
+
+// CycleProjection is the predicted outcome of one planned IVF cycle.
+type CycleProjection struct {
+	Cycle      int    `json:"cycle"`
+	Age        int    `json:"age"`
+	CDCFormula string `json:"cdc_formula"`
+	// SuccessRate is the chance of success of this cycle alone, in percents.
+	SuccessRate float64 `json:"success_rate"`
+	// CumulativeSuccessRate is the chance of success by the end of this cycle, in percents.
+	CumulativeSuccessRate float64 `json:"cumulative_success_rate"`
+}
+
+// Projection is the cumulative chance of success over several planned IVF cycles.
+type Projection struct {
+	CumulativeSuccessRate float64           `json:"cumulative_success_rate"`
+	Cycles                []CycleProjection `json:"cycles"`
+}
+
+// ProjectCycles projects the cumulative chance of success over the given number of cycles, started
+// monthsBetween months apart. Every cycle after the first is calculated with the "attempted IVF previously"
+// formula and with the age the patient will have when it starts. Cycles are assumed independent, so the
+// cumulative chance is 1 - (1-p1)(1-p2)...(1-pn).
+func ProjectCycles(repo FormulaGetter, params *Input, cycles int, monthsBetween int) (*Projection, error) {
+	if cycles < 1 {
+		return nil, inputErrorf("cycles", "cycles must be at least 1. Got %d", cycles)
+	}
+	if monthsBetween < 0 {
+		return nil, inputErrorf("months_between_cycles", "months_between_cycles can't be negative. Got %d", monthsBetween)
+	}
+	maxAge := *lookupParam("age").Maximum
+	if lastAge := params.Age + (cycles-1)*monthsBetween/12; lastAge > maxAge {
+		return nil, inputErrorf("cycles", "the last cycle would start at age %d, the calculator supports ages up to %d", lastAge, maxAge)
+	}
+
+	projection := &Projection{}
+	failure := 1.0
+	for i := 0; i < cycles; i++ {
+		cycle := NextCycleInput(params, i, monthsBetween)
+		f, err := repo.GetFormula(cycle.UseOwnEggs, cycle.IVFUsed, cycle.ReasonKnown)
+		if err != nil {
+			return nil, err
+		}
+
+		rate, err := CalculateSuccess(f, cycle)
+		if err != nil {
+			return nil, err
+		}
+		failure *= 1 - rate/100
+		projection.Cycles = append(projection.Cycles, CycleProjection{
+			Cycle:                 i + 1,
+			Age:                   cycle.Age,
+			CDCFormula:            f.CDCFormula,
+			SuccessRate:           rate,
+			CumulativeSuccessRate: params.Precision.roundRate((1 - failure) * 100),
+		})
+	}
+	projection.CumulativeSuccessRate = projection.Cycles[len(projection.Cycles)-1].CumulativeSuccessRate
+
+	return projection, nil
+}
+
+// NextCycleInput returns the input of the given zero based cycle: the patient is older by the time elapsed
+// since the first cycle and, when using own eggs, has attempted IVF previously.
+func NextCycleInput(params *Input, cycle int, monthsBetween int) *Input {
+	next := *params
+	next.Age += cycle * monthsBetween / 12
+	if cycle > 0 && next.IVFUsed == "FALSE" {
+		next.IVFUsed = "TRUE"
+	}
+	return &next
+}
@@ -0,0 +1,7 @@
+// Package ivf is the IVF success calculator as a library: it validates CDC form inputs, loads the CDC
+// formulas and calculates the chance of having a baby using In Vitro Fertilization. It has no HTTP dependency.
+//
+//	formulas, err := ivf.LoadFormulas("ivf_success_formulas.csv")
+//	input, err := ivf.NewInput(&ivf.Patient{Age: 32, Weight: 150, Feet: 5, Inches: 8, ...})
+//	result, err := ivf.Calculate(formulas, input)
+package ivf
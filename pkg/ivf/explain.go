@@ -0,0 +1,73 @@
+package ivf
+
+import "math"
+
+// Term is the contribution of a single formula term to the score.
+type Term struct {
+	Name string `json:"name"`
+	// Input is the patient value the term was evaluated with, empty for the intercept.
+	Input        string  `json:"input,omitempty"`
+	Contribution float64 `json:"contribution"`
+
+	// coefficient is the name of the coefficient the term multiplies, as in the formula CSV header,
+	// and covariate is the value it is multiplied with.
+	coefficient string
+	covariate   float64
+}
+
+// Explanation breaks a success rate down into the formula terms that produced it.
+type Explanation struct {
+	CDCFormula string    `json:"cdc_formula"`
+	Precision  Precision `json:"precision"`
+	BMI        float64   `json:"bmi"`
+	Terms      []Term    `json:"terms"`
+	// Score is the sum of all term contributions, the log-odds of success.
+	Score       float64 `json:"score"`
+	SuccessRate float64 `json:"success_rate"`
+	// Interval is the confidence interval of the success rate, set when the formula has a coefficient covariance.
+	Interval *Interval `json:"confidence_interval,omitempty"`
+}
+
+// Explain evaluates the formula for the input and returns every term contribution along with the success rate.
+// It fails when the formula terms don't compile.
+func Explain(f *Formula, params *Input) (*Explanation, error) {
+	return ExplainWithBMI(f, params, CalculateBMI(params))
+}
+
+// ExplainWithBMI is Explain with the given BMI instead of the one calculated from the weight and height.
+func ExplainWithBMI(f *Formula, params *Input, bmi float64) (*Explanation, error) {
+	compiled, err := f.terms()
+	if err != nil {
+		return nil, err
+	}
+	var terms []Term
+	for _, t := range compiled {
+		if term, ok := t.evaluate(params, bmi); ok {
+			terms = append(terms, term)
+		}
+	}
+
+	score := 0.0
+	for _, t := range terms {
+		score += t.Contribution
+	}
+
+	// calculate success rate in %
+	successRate := 1.0 / (1.0 + math.Exp(-score)) * 100
+	precision := params.Precision
+	if precision == "" {
+		precision = PrecisionCDC
+	}
+	// round to 2 decimal digits
+	successRate = precision.roundRate(successRate)
+
+	return &Explanation{
+		CDCFormula:  f.CDCFormula,
+		Precision:   precision,
+		BMI:         bmi,
+		Terms:       terms,
+		Score:       score,
+		SuccessRate: successRate,
+		Interval:    confidenceInterval(f.Covariance, terms, score, precision),
+	}, nil
+}
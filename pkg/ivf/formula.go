@@ -0,0 +1,234 @@
+package ivf
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Formula represents a single IVF success formula with all its parameters
+type Formula struct {
+	UsingOwnEggs                string
+	AttemptedIVFPreviously      string
+	IsReasonForInfertilityKnown string
+	CDCFormula                  string
+	// Coefficients holds the coefficients of formulas read from the CDC CSV layout.
+	Coefficients Coefficients
+	// Terms defines the formula as data. Formulas read from the CSV layout get the terms of their Coefficients.
+	// Formulas built in code with Terms are compiled on every use until Compile is called; calculations with invalid
+	// terms fail with the Compile error.
+	Terms []FormulaTerm
+	// Covariance is the optional covariance matrix of the coefficients. When present, calculations
+	// report a confidence interval around the success rate.
+	Covariance Covariance
+	// Version identifies the formulas the formula was loaded with, e.g. cdc@3, when the repo can tell.
+	Version string
+
+	// compiled holds Terms compiled by Compile.
+	compiled []compiledTerm
+}
+
+// Compile checks the formula terms against the formula inputs and prepares them for evaluation.
+// A formula without terms gets the terms of its Coefficients.
+func (f *Formula) Compile() error {
+	terms := f.Terms
+	if len(terms) == 0 {
+		terms = cdcTerms(&f.Coefficients)
+	}
+	compiled, err := compileTerms(terms)
+	if err != nil {
+		return err
+	}
+	f.Terms, f.compiled = terms, compiled
+	return nil
+}
+
+// terms returns the compiled terms of the formula, compiling them when the formula was built in code.
+func (f *Formula) terms() ([]compiledTerm, error) {
+	if f.compiled != nil {
+		return f.compiled, nil
+	}
+	terms := f.Terms
+	if len(terms) == 0 {
+		terms = cdcTerms(&f.Coefficients)
+	}
+	compiled, err := compileTerms(terms)
+	if err != nil {
+		return nil, fmt.Errorf("formula %s: %w", f.CDCFormula, err)
+	}
+	return compiled, nil
+}
+
+// Coefficients holds the logistic regression coefficients of a formula.
+type Coefficients struct {
+	Intercept                float64
+	AgeLinear                float64
+	AgePower                 float64
+	AgePowerFactor           float64
+	BMILinear                float64
+	BMIPower                 float64
+	BMIPowerFactor           float64
+	TubalFactor              map[bool]float64
+	MaleFactorInfertility    map[bool]float64
+	Endometriosis            map[bool]float64
+	OvulatoryDisorder        map[bool]float64
+	DiminishedOvarianReserve map[bool]float64
+	UterineFactor            map[bool]float64
+	OtherReason              map[bool]float64
+	UnexplainedInfertility   map[bool]float64
+	PriorPregnancies         map[string]float64
+	PriorLiveBirths          map[string]float64
+}
+
+// formulaColumns is the number of columns in the CDC formula CSV layout.
+const formulaColumns = 33
+
+// LoadFormulas reads all formulas from a CSV file in the CDC formula layout, or from a .json file of formula
+// definitions, see ReadFormulaDefinitions.
+func LoadFormulas(path string) ([]*Formula, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	defer file.Close()
+
+	if filepath.Ext(path) == ".json" {
+		return ReadFormulaDefinitions(file)
+	}
+	return ReadFormulas(file)
+}
+
+// ReadFormulas reads all formulas from CSV data in the CDC formula layout.
+func ReadFormulas(r io.Reader) ([]*Formula, error) {
+	reader := csv.NewReader(r)
+
+	// Skip headers
+	_, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading headers: %w", err)
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading records: %w", err)
+	}
+
+	formulas := make([]*Formula, 0, len(records))
+	for i, record := range records {
+		if len(record) < formulaColumns {
+			return nil, fmt.Errorf("record %d has %d columns, expected %d", i+1, len(record), formulaColumns)
+		}
+		formulas = append(formulas, parseFormula(record))
+	}
+	return formulas, nil
+}
+
+// FindFormula returns the formula matching the given parameters.
+func FindFormula(formulas []*Formula, usingOwnEggs string, attemptedIVFPreviously string, isReasonKnown string) (*Formula, error) {
+	for _, formula := range formulas {
+		if usingOwnEggs == formula.UsingOwnEggs && attemptedIVFPreviously == formula.AttemptedIVFPreviously &&
+			isReasonKnown == formula.IsReasonForInfertilityKnown {
+			return formula, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no matching formula found for the given parameters")
+}
+
+// parseFloat parses a coefficient of the CSV layout, 0 when it isn't a number.
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+func parseFormula(record []string) *Formula {
+	formula := &Formula{}
+
+	// Parse boolean fields
+	formula.UsingOwnEggs = record[0]
+	formula.AttemptedIVFPreviously = record[1]
+	formula.IsReasonForInfertilityKnown = record[2]
+
+	formula.CDCFormula = record[3]
+
+	// Parse coefficients
+	formula.Coefficients.Intercept = parseFloat(record[4])
+	formula.Coefficients.AgeLinear = parseFloat(record[5])
+	formula.Coefficients.AgePower = parseFloat(record[6])
+	formula.Coefficients.AgePowerFactor = parseFloat(record[7])
+	formula.Coefficients.BMILinear = parseFloat(record[8])
+	formula.Coefficients.BMIPower = parseFloat(record[9])
+	formula.Coefficients.BMIPowerFactor = parseFloat(record[10])
+
+	// Initialize maps for boolean coefficients
+	formula.Coefficients.TubalFactor = map[bool]float64{
+		true:  parseFloat(record[11]),
+		false: parseFloat(record[12]),
+	}
+	formula.Coefficients.MaleFactorInfertility = map[bool]float64{
+		true:  parseFloat(record[13]),
+		false: parseFloat(record[14]),
+	}
+	formula.Coefficients.Endometriosis = map[bool]float64{
+		true:  parseFloat(record[15]),
+		false: parseFloat(record[16]),
+	}
+	formula.Coefficients.OvulatoryDisorder = map[bool]float64{
+		true:  parseFloat(record[17]),
+		false: parseFloat(record[18]),
+	}
+	formula.Coefficients.DiminishedOvarianReserve = map[bool]float64{
+		true:  parseFloat(record[19]),
+		false: parseFloat(record[20]),
+	}
+	formula.Coefficients.UterineFactor = map[bool]float64{
+		true:  parseFloat(record[21]),
+		false: parseFloat(record[22]),
+	}
+	formula.Coefficients.OtherReason = map[bool]float64{
+		true:  parseFloat(record[23]),
+		false: parseFloat(record[24]),
+	}
+	formula.Coefficients.UnexplainedInfertility = map[bool]float64{
+		true:  parseFloat(record[25]),
+		false: parseFloat(record[26]),
+	}
+
+	// Initialize maps for numeric coefficients
+	formula.Coefficients.PriorPregnancies = map[string]float64{
+		"0":  parseFloat(record[27]),
+		"1":  parseFloat(record[28]),
+		"2+": parseFloat(record[29]),
+	}
+	formula.Coefficients.PriorLiveBirths = map[string]float64{
+		"0":  parseFloat(record[30]),
+		"1":  parseFloat(record[31]),
+		"2+": parseFloat(record[32]),
+	}
+
+	// the CDC terms always compile
+	_ = formula.Compile()
+
+	return formula
+}
+
+// FormulaGetter returns the formula for the given egg source, prior IVF attempt and infertility reason.
+type FormulaGetter interface {
+	GetFormula(usingOwnEggs string, attemptedIVFPreviously string, isReasonKnown string) (*Formula, error)
+}
+
+// FormulaSet is a list of loaded formulas that can be used as a FormulaGetter.
+type FormulaSet []*Formula
+
+// GetFormula returns the formula matching the given parameters.
+func (s FormulaSet) GetFormula(usingOwnEggs string, attemptedIVFPreviously string, isReasonKnown string) (*Formula, error) {
+	return FindFormula(s, usingOwnEggs, attemptedIVFPreviously, isReasonKnown)
+}
+
+// GetFormulas returns all formulas of the set.
+func (s FormulaSet) GetFormulas() ([]*Formula, error) {
+	return s, nil
+}
@@ -0,0 +1,344 @@
+package ivf
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+// Input holds the calculator request input.
+type Input struct {
+	Age          int
+	Weight       int
+	Feet         int
+	Inches       int
+	IVFUsed      string
+	Coefficients map[string]interface{}
+	ReasonKnown  string
+	UseOwnEggs   string
+	// Precision selects the rounding of the BMI and of the results. The zero value rounds as PrecisionCDC.
+	Precision Precision
+}
+
+// InputError is returned by ParseInput when an input is missing or invalid.
+type InputError struct {
+	// Param is the name of the invalid input, or the name of the Choice of a rule between several inputs. It is empty
+	// when the error involves no particular input.
+	Param   string
+	Message string
+}
+
+func (e *InputError) Error() string {
+	return e.Message
+}
+
+func inputErrorf(param string, format string, a ...interface{}) *InputError {
+	return &InputError{Param: param, Message: fmt.Sprintf(format, a...)}
+}
+
+// Param describes a calculator input exactly as ParseInput accepts it.
+type Param struct {
+	Name string
+	// Label is the short name of the input shown to patients, e.g. in reports.
+	Label       string
+	Description string
+	// Type is "integer" or "string", or "number" for the inputs of a LogisticModel.
+	Type     string
+	Enum     []string
+	Minimum  *int
+	Maximum  *int
+	Required bool
+	// AtMost names the input this one can't be greater than.
+	AtMost string
+}
+
+// Choice is a rule of ParseInput between Yes/No inputs: exactly one of its options must be selected. An option is
+// selected when one of its inputs is Yes.
+type Choice struct {
+	Name        string
+	Description string
+	Options     [][]string
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+var yesNo = []string{"Yes", "No"}
+
+// params is the single description of the calculator inputs. ParseInput takes enums and ranges from it.
+var params = []Param{
+	{Name: "age", Label: "Age", Description: "Age in years.", Type: "integer", Minimum: intPtr(20), Maximum: intPtr(50)},
+	{Name: "weight", Label: "Weight (lb)", Description: "Weight in pounds.", Type: "integer", Minimum: intPtr(80),
+		Maximum: intPtr(300)},
+	{Name: "feet", Label: "Height (feet)", Description: "Height, feet part.", Type: "integer"},
+	{Name: "inches", Label: "Height (inches)", Description: "Height, inches part.", Type: "integer"},
+	{Name: "ivf_used", Label: "Prior IVF cycles", Description: "Number of prior IVF cycles. Ignored when eggSource is Donor.",
+		Type: "string", Enum: []string{"0", "1", "2", "3+"}, Required: true},
+	{Name: "gravida", Label: "Prior pregnancies", Description: "Number of prior pregnancies.", Type: "string",
+		Enum: []string{"0", "1", "2+"}, Required: true},
+	{Name: "previous_live_births", Label: "Prior live births",
+		Description: "Number of prior live births. Can't be greater than gravida.", Type: "string",
+		Enum: []string{"0", "1", "2+"}, Required: true, AtMost: "gravida"},
+	{Name: "tubal_factor", Label: "Tubal factor", Description: "Known infertility reason: tubal factor.", Type: "string",
+		Enum: yesNo, Required: true},
+	{Name: "male_factor_infertility", Label: "Male factor infertility",
+		Description: "Known infertility reason: male factor infertility.", Type: "string", Enum: yesNo, Required: true},
+	{Name: "endometriosis", Label: "Endometriosis", Description: "Known infertility reason: endometriosis.", Type: "string",
+		Enum: yesNo, Required: true},
+	{Name: "ovulatory_disorder", Label: "Ovulatory disorder",
+		Description: "Known infertility reason: ovulatory disorder, including PCOS.", Type: "string", Enum: yesNo,
+		Required: true},
+	{Name: "diminished_ovarian_reserve", Label: "Diminished ovarian reserve",
+		Description: "Known infertility reason: diminished ovarian reserve.", Type: "string", Enum: yesNo, Required: true},
+	{Name: "uterine_factor", Label: "Uterine factor", Description: "Known infertility reason: uterine factor.",
+		Type: "string", Enum: yesNo, Required: true},
+	{Name: "other_reason", Label: "Other reason", Description: "Known infertility reason: other reason.", Type: "string",
+		Enum: yesNo, Required: true},
+	{Name: "unexplained_infertility", Label: "Unexplained infertility",
+		Description: "Unexplained (idiopathic) infertility. Exactly one of a known reason, " +
+			"unexplained_infertility or donotknow must be Yes.", Type: "string", Enum: yesNo},
+	{Name: "donotknow", Label: "Reason not known",
+		Description: "The reason for infertility is not known. Exactly one of a known reason, " +
+			"unexplained_infertility or donotknow must be Yes.", Type: "string", Enum: yesNo},
+	{Name: "eggSource", Label: "Egg source", Description: "Use own or donor eggs.", Type: "string",
+		Enum: []string{"Own", "Donor"}},
+	{Name: "precision", Label: "Precision", Description: "Rounding of the BMI and of the results: cdc rounds the BMI " +
+		"to a single decimal and rates to 2 decimals, matching the CDC calculator, exact skips all rounding. Defaults to " +
+		"cdc.", Type: "string", Enum: []string{string(PrecisionCDC), string(PrecisionExact)}},
+}
+
+// reasonChoice is the rule that the infertility reason is either known, unexplained or not known.
+var reasonChoice = Choice{
+	Name:        "infertility_reason",
+	Description: "Reason for infertility. Exactly one of a known reason, unexplained infertility or not known.",
+	Options: [][]string{
+		{"tubal_factor", "male_factor_infertility", "endometriosis", "ovulatory_disorder", "diminished_ovarian_reserve",
+			"uterine_factor", "other_reason"},
+		{"unexplained_infertility"},
+		{"donotknow"},
+	},
+}
+
+// Params returns the description of every calculator input in the order of the CDC form.
+func Params() []Param {
+	return append([]Param(nil), params...)
+}
+
+// Choices returns the rules between inputs of which exactly one option must be selected.
+func Choices() []Choice {
+	return []Choice{reasonChoice}
+}
+
+// NormalizeValues keeps the first value of every declared param, the one ParseInput reads, dropping empty values and
+// undeclared params. Equal normalized values give the same prediction.
+func NormalizeValues(params []Param, values url.Values) url.Values {
+	normalized := url.Values{}
+	for _, p := range params {
+		if v := values.Get(p.Name); v != "" {
+			normalized.Set(p.Name, v)
+		}
+	}
+	return normalized
+}
+
+func lookupParam(name string) Param {
+	for _, p := range params {
+		if p.Name == name {
+			return p
+		}
+	}
+	panic("ivf: unknown param " + name)
+}
+
+// knownReasons maps the known infertility reason inputs to their coefficient names.
+var knownReasons = map[string]string{
+	"tubal_factor":               "tubalFactor",
+	"male_factor_infertility":    "maleFactorInfertility",
+	"endometriosis":              "endometriosis",
+	"ovulatory_disorder":         "ovulatoryDisorder",
+	"diminished_ovarian_reserve": "diminishedOvarianReserve",
+	"uterine_factor":             "uterineFactor",
+	"other_reason":               "otherReason",
+}
+
+// Values are the form values ParseInput reads, e.g. url.Values.
+type Values interface {
+	Get(key string) string
+}
+
+// ParseInput validates the CDC form values and converts them into the calculator input.
+// Errors are returned as *InputError.
+func ParseInput(values Values) (*Input, error) {
+	input := &Input{}
+
+	var err error
+	if input.Age, err = parseRangedInt(values, "age"); err != nil {
+		return nil, err
+	}
+	if input.Weight, err = parseRangedInt(values, "weight"); err != nil {
+		return nil, err
+	}
+	if input.Feet, err = parseRangedInt(values, "feet"); err != nil {
+		return nil, err
+	}
+	if input.Inches, err = parseRangedInt(values, "inches"); err != nil {
+		return nil, err
+	}
+
+	// Populate coefficients map
+	input.Coefficients = make(map[string]interface{})
+	priorPregnanciesStr := values.Get("gravida")
+	if priorPregnanciesStr != "" {
+		if slices.Contains(lookupParam("gravida").Enum, priorPregnanciesStr) {
+			input.Coefficients["priorPregnancies"] = priorPregnanciesStr
+		} else {
+			return nil, inputErrorf("gravida", "gravida has invalid value %s", priorPregnanciesStr)
+		}
+	} else {
+		return nil, inputErrorf("gravida", "gravida is required")
+	}
+
+	priorLiveBirthsStr := values.Get("previous_live_births")
+	if priorLiveBirthsStr != "" {
+		if slices.Contains(lookupParam("previous_live_births").Enum, priorLiveBirthsStr) {
+			if priorLiveBirthsStr > values.Get(lookupParam("previous_live_births").AtMost) {
+				return nil, inputErrorf("previous_live_births", "previous_live_births can't be greater then gravida")
+			}
+			input.Coefficients["priorLiveBirths"] = priorLiveBirthsStr
+		} else {
+			return nil, inputErrorf("previous_live_births", "previous_live_births has invalid value %s", priorLiveBirthsStr)
+		}
+	} else {
+		return nil, inputErrorf("previous_live_births", "previous_live_births is required")
+	}
+
+	known_reasons := false
+	for paramName, coefficientName := range knownReasons {
+		if value, err := processKnownReasons(values, paramName); err != nil {
+			return nil, err
+		} else {
+			input.Coefficients[coefficientName] = value
+			if value {
+				known_reasons = true
+			}
+		}
+	}
+
+	unexplainedInfertilitySel := false
+	if noReasonStr := values.Get("unexplained_infertility"); noReasonStr != "" {
+		switch noReasonStr {
+		case `Yes`:
+			input.Coefficients["unexplainedInfertility"] = true
+			unexplainedInfertilitySel = true
+		case `No`:
+			input.Coefficients["unexplainedInfertility"] = false
+		default:
+			return nil, inputErrorf("unexplained_infertility", "unexplained_infertility has invalid value %s", noReasonStr)
+		}
+	}
+
+	noReasonSel := false
+	if noReasonStr := values.Get("donotknow"); noReasonStr != "" {
+		switch noReasonStr {
+		case `Yes`:
+			input.ReasonKnown = "FALSE"
+			noReasonSel = true
+		case `No`:
+			input.ReasonKnown = "TRUE"
+		default:
+			return nil, inputErrorf("donotknow", "donotknow has invalid value %s", noReasonStr)
+		}
+	}
+
+	if !onlyOneTrue(known_reasons, unexplainedInfertilitySel, noReasonSel) {
+		return nil, inputErrorf(reasonChoice.Name, "known_reasons OR unexplained_infertility OR no_reason is required")
+	}
+
+	if useOwnEggsStr := values.Get("eggSource"); useOwnEggsStr != "" {
+		switch useOwnEggsStr {
+		case `Own`:
+			input.UseOwnEggs = "TRUE"
+		case `Donor`:
+			input.UseOwnEggs = "FALSE"
+		default:
+			return nil, inputErrorf("eggSource", "eggSource has invalid value %s", useOwnEggsStr)
+		}
+	}
+
+	if ivfusedStr := values.Get("ivf_used"); ivfusedStr != "" {
+		if input.UseOwnEggs == "FALSE" {
+			input.IVFUsed = "N/A"
+		} else {
+			if slices.Contains(lookupParam("ivf_used").Enum, ivfusedStr) {
+				if ivfusedStr == "0" {
+					input.IVFUsed = "FALSE"
+				} else {
+					input.IVFUsed = "TRUE"
+				}
+			} else {
+				return nil, inputErrorf("ivf_used", "ivf_used has invalid value %s", ivfusedStr)
+			}
+		}
+	} else {
+		return nil, inputErrorf("ivf_used", "ivf_used is required")
+	}
+
+	input.Precision = PrecisionCDC
+	if precisionStr := values.Get("precision"); precisionStr != "" {
+		if !slices.Contains(lookupParam("precision").Enum, precisionStr) {
+			return nil, inputErrorf("precision", "precision has invalid value %s", precisionStr)
+		}
+		input.Precision = Precision(precisionStr)
+	}
+
+	return input, nil
+}
+
+// onlyOneTrue returns true if only 1 value out of 3 is set to true.
+func onlyOneTrue(b1, b2, b3 bool) bool {
+	count := 0
+	if b1 {
+		count++
+	}
+	if b2 {
+		count++
+	}
+	if b3 {
+		count++
+	}
+	return count == 1
+}
+
+// parseRangedInt parses an optional integer input and checks it against the range declared in params.
+func parseRangedInt(values Values, name string) (int, error) {
+	str := values.Get(name)
+	if str == "" {
+		return 0, nil
+	}
+
+	v, err := strconv.Atoi(str)
+	if err != nil {
+		return 0, &InputError{Param: name, Message: err.Error()}
+	}
+	p := lookupParam(name)
+	if (p.Minimum != nil && v < *p.Minimum) || (p.Maximum != nil && v > *p.Maximum) {
+		return 0, inputErrorf(name, "%s must be between %d and %d. Got %s", name, *p.Minimum, *p.Maximum, str)
+	}
+	return v, nil
+}
+
+func processKnownReasons(values Values, paramName string) (bool, error) {
+	if paramStr := values.Get(paramName); paramStr != "" {
+		switch paramStr {
+		case `Yes`:
+			return true, nil
+		case `No`:
+			return false, nil
+		default:
+			return false, inputErrorf(paramName, "%s has invalid value %s", paramName, paramStr)
+		}
+	}
+	return false, inputErrorf(paramName, "%s is required", paramName)
+}
@@ -0,0 +1,137 @@
+package ivf
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+// ConfidenceLevel is the level of the reported confidence intervals.
+const ConfidenceLevel = 0.95
+
+// zScore is the standard normal quantile for ConfidenceLevel.
+const zScore = 1.959963984540054
+
+// Interval is a confidence interval of the success rate, in percents.
+type Interval struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	Level float64 `json:"level"`
+}
+
+// Covariance is a covariance matrix of formula coefficients, keyed by the coefficient names of the formula terms.
+// For CSV formulas, names follow the formula CSV header without the formula_ prefix and the _coefficient/_value
+// suffix, e.g. intercept, age_linear, age_power, bmi_linear, bmi_power, tubal_factor_true or prior_pregnancies_2+.
+// Power factors are fixed.
+type Covariance map[string]map[string]float64
+
+// Set sets the covariance of two coefficients, or the variance when both names are the same.
+func (c Covariance) Set(a string, b string, value float64) {
+	if c[a] == nil {
+		c[a] = map[string]float64{}
+	}
+	if c[b] == nil {
+		c[b] = map[string]float64{}
+	}
+	c[a][b] = value
+	c[b][a] = value
+}
+
+// confidenceInterval applies the delta method to the score, which is linear in the coefficients, so its variance
+// is g'Σg where g holds the covariate of every term. The interval is built on the log-odds scale and transformed
+// into a probability, which keeps it within 0-100%.
+func confidenceInterval(covariance Covariance, terms []Term, score float64, precision Precision) *Interval {
+	if len(covariance) == 0 {
+		return nil
+	}
+
+	variance := 0.0
+	for _, a := range terms {
+		for _, b := range terms {
+			variance += a.covariate * covariance[a.coefficient][b.coefficient] * b.covariate
+		}
+	}
+	if variance < 0 {
+		return nil
+	}
+
+	margin := zScore * math.Sqrt(variance)
+	return &Interval{
+		Lower: precision.roundRate(logistic(score-margin) * 100),
+		Upper: precision.roundRate(logistic(score+margin) * 100),
+		Level: ConfidenceLevel,
+	}
+}
+
+func logistic(score float64) float64 {
+	return 1.0 / (1.0 + math.Exp(-score))
+}
+
+// LoadCovariance reads coefficient covariances from a CSV file and attaches them to the formulas by cdc_formula.
+// The file either lists standard errors, with the header cdc_formula,coefficient,std_error, or covariances,
+// with the header cdc_formula,coefficient_a,coefficient_b,covariance.
+func LoadCovariance(path string, formulas []*Formula) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening file: %w", err)
+	}
+	defer file.Close()
+
+	return ReadCovariance(file, formulas)
+}
+
+// ReadCovariance reads coefficient covariances from CSV data, see LoadCovariance.
+func ReadCovariance(r io.Reader, formulas []*Formula) error {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("error reading headers: %w", err)
+	}
+	standardErrors := len(header) == 3
+	if !standardErrors && len(header) != 4 {
+		return fmt.Errorf("expected the columns cdc_formula,coefficient,std_error or " +
+			"cdc_formula,coefficient_a,coefficient_b,covariance")
+	}
+
+	byID := map[string]*Formula{}
+	for _, f := range formulas {
+		byID[f.CDCFormula] = f
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("error reading records: %w", err)
+	}
+	for i, record := range records {
+		f, ok := byID[record[0]]
+		if !ok {
+			return fmt.Errorf("record %d: unknown cdc_formula %s", i+1, record[0])
+		}
+		terms, err := f.terms()
+		if err != nil {
+			return err
+		}
+		names := coefficientNames(terms)
+		for _, name := range record[1 : len(record)-1] {
+			if !names[name] {
+				return fmt.Errorf("record %d: unknown coefficient %s", i+1, name)
+			}
+		}
+		value, err := strconv.ParseFloat(record[len(record)-1], 64)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i+1, err)
+		}
+		if f.Covariance == nil {
+			f.Covariance = Covariance{}
+		}
+		if standardErrors {
+			f.Covariance.Set(record[1], record[1], value*value)
+		} else {
+			f.Covariance.Set(record[1], record[2], value)
+		}
+	}
+	return nil
+}
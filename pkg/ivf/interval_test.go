@@ -0,0 +1,75 @@
+package ivf
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var readmePatient = &Patient{Age: 32, Weight: 150, Feet: 5, Inches: 8, IVFUsed: "0", Gravida: "1",
+	PreviousLiveBirths: "1", Endometriosis: true, OvulatoryDisorder: true, EggSource: EggSourceOwn}
+
+func TestConfidenceInterval(t *testing.T) {
+	input, err := NewInput(readmePatient)
+	require.NoError(t, err)
+
+	t.Run("No interval without covariance", func(t *testing.T) {
+		formulas, err := LoadFormulas(formulasPath)
+		require.NoError(t, err)
+
+		result, err := Calculate(formulas, input)
+
+		require.NoError(t, err)
+		assert.Nil(t, result.Interval)
+	})
+
+	t.Run("Standard errors", func(t *testing.T) {
+		formulas, err := LoadFormulas(formulasPath)
+		require.NoError(t, err)
+		require.NoError(t, LoadCovariance("testdata/standard_errors.csv", formulas))
+
+		result, err := Calculate(formulas, input)
+
+		require.NoError(t, err)
+		require.NotNil(t, result.Interval)
+		explanation, err := Explain(formulas[0], input)
+		require.NoError(t, err)
+		score := explanation.Score
+		assert.Equal(t, 62.21, result.SuccessRate)
+		assert.Equal(t, PrecisionCDC.roundRate(logistic(score-zScore*0.1)*100), result.Interval.Lower)
+		assert.Equal(t, PrecisionCDC.roundRate(logistic(score+zScore*0.1)*100), result.Interval.Upper)
+		assert.Equal(t, 0.95, result.Interval.Level)
+	})
+
+	t.Run("Covariance", func(t *testing.T) {
+		formulas, err := LoadFormulas(formulasPath)
+		require.NoError(t, err)
+		require.NoError(t, LoadCovariance("testdata/covariance.csv", formulas))
+
+		result, err := Calculate(formulas, input)
+
+		require.NoError(t, err)
+		require.NotNil(t, result.Interval)
+		// g = (1, age): var = 0.01 + 32*32*0.0001 + 2*32*-0.0009
+		explanation, err := Explain(formulas[0], input)
+		require.NoError(t, err)
+		score := explanation.Score
+		margin := zScore * math.Sqrt(0.01+32*32*0.0001-2*32*0.0009)
+		assert.Equal(t, PrecisionCDC.roundRate(logistic(score-margin)*100), result.Interval.Lower)
+		assert.Equal(t, PrecisionCDC.roundRate(logistic(score+margin)*100), result.Interval.Upper)
+		assert.Less(t, result.Interval.Lower, result.SuccessRate)
+		assert.Greater(t, result.Interval.Upper, result.SuccessRate)
+	})
+
+	t.Run("Unknown coefficient", func(t *testing.T) {
+		formulas, err := LoadFormulas(formulasPath)
+		require.NoError(t, err)
+
+		err = ReadCovariance(strings.NewReader("cdc_formula,coefficient,std_error\n1-3,age,0.1\n"), formulas)
+
+		assert.EqualError(t, err, "record 1: unknown coefficient age")
+	})
+}
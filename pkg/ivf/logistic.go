@@ -0,0 +1,140 @@
+package ivf
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// LogisticModel is a logistic prediction model defined as data, e.g. a SART-style or clinic-trained model with other
+// covariates than the CDC form, like AMH or the number of embryos. It reads its own inputs and has no precision modes:
+// the success rate is rounded to 2 decimals.
+type LogisticModel struct {
+	ModelName string `json:"name"`
+	// Inputs are the inputs the model reads. Their Type is "integer", "number" or "string"; string inputs need an Enum.
+	Inputs    []Param        `json:"inputs"`
+	Intercept float64        `json:"intercept"`
+	Terms     []LogisticTerm `json:"terms"`
+}
+
+// LogisticTerm adds coefficient * input^exponent for a numeric input, or the level coefficient of a string input.
+// Inputs left out, when they are optional, add nothing.
+type LogisticTerm struct {
+	Input       string  `json:"input"`
+	Coefficient float64 `json:"coefficient,omitempty"`
+	// Exponent defaults to 1.
+	Exponent float64            `json:"exponent,omitempty"`
+	Levels   map[string]float64 `json:"levels,omitempty"`
+}
+
+// LoadLogisticModels reads a JSON array of logistic model definitions and checks them.
+func LoadLogisticModels(path string) ([]*LogisticModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading models: %w", err)
+	}
+	var models []*LogisticModel
+	if err := json.Unmarshal(data, &models); err != nil {
+		return nil, fmt.Errorf("error reading models: %w", err)
+	}
+	for _, m := range models {
+		if err := m.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return models, nil
+}
+
+// Validate checks that the model has a name, that its inputs are well defined and that its terms read them.
+func (m *LogisticModel) Validate() error {
+	if m.ModelName == "" {
+		return fmt.Errorf("model name is required")
+	}
+	inputs := map[string]Param{}
+	for _, p := range m.Inputs {
+		switch {
+		case p.Name == "":
+			return fmt.Errorf("model %s: input name is required", m.ModelName)
+		case p.Type == "string" && len(p.Enum) == 0:
+			return fmt.Errorf("model %s: input %s: string inputs need an enum", m.ModelName, p.Name)
+		case p.Type != "string" && p.Type != "integer" && p.Type != "number":
+			return fmt.Errorf("model %s: input %s: unknown type %q", m.ModelName, p.Name, p.Type)
+		case (p.Minimum == nil) != (p.Maximum == nil):
+			return fmt.Errorf("model %s: input %s: set both minimum and maximum or neither", m.ModelName, p.Name)
+		}
+		inputs[p.Name] = p
+	}
+	for i, t := range m.Terms {
+		p, ok := inputs[t.Input]
+		if !ok {
+			return fmt.Errorf("model %s: term %d: unknown input %q", m.ModelName, i+1, t.Input)
+		}
+		if p.Type == "string" {
+			for level := range t.Levels {
+				if !slices.Contains(p.Enum, level) {
+					return fmt.Errorf("model %s: term %d: %s has no level %q", m.ModelName, i+1, t.Input, level)
+				}
+			}
+		} else if len(t.Levels) > 0 {
+			return fmt.Errorf("model %s: term %d: numeric input %s has no levels", m.ModelName, i+1, t.Input)
+		}
+	}
+	return nil
+}
+
+func (m *LogisticModel) Name() string {
+	return m.ModelName
+}
+
+func (m *LogisticModel) Params() []Param {
+	return append([]Param(nil), m.Inputs...)
+}
+
+func (m *LogisticModel) Predict(values url.Values) (*Result, error) {
+	numbers := map[string]float64{}
+	for _, p := range m.Inputs {
+		str := values.Get(p.Name)
+		switch {
+		case str == "" && p.Required:
+			return nil, inputErrorf(p.Name, "%s is required", p.Name)
+		case str == "":
+		case p.Type == "string":
+			if !slices.Contains(p.Enum, str) {
+				return nil, inputErrorf(p.Name, "%s has invalid value %s", p.Name, str)
+			}
+		default:
+			v, err := strconv.ParseFloat(str, 64)
+			if err != nil || (p.Type == "integer" && v != math.Trunc(v)) {
+				return nil, inputErrorf(p.Name, "%s has invalid value %s", p.Name, str)
+			}
+			if (p.Minimum != nil && v < float64(*p.Minimum)) || (p.Maximum != nil && v > float64(*p.Maximum)) {
+				return nil, inputErrorf(p.Name, "%s must be between %d and %d. Got %s", p.Name, *p.Minimum, *p.Maximum,
+					str)
+			}
+			numbers[p.Name] = v
+		}
+	}
+
+	score := m.Intercept
+	for _, t := range m.Terms {
+		if t.Levels != nil {
+			score += t.Levels[values.Get(t.Input)]
+			continue
+		}
+		x, ok := numbers[t.Input]
+		if !ok {
+			continue
+		}
+		exponent := t.Exponent
+		if exponent == 0 {
+			exponent = 1
+		}
+		score += t.Coefficient * math.Pow(x, exponent)
+	}
+
+	return &Result{Model: m.ModelName, SuccessRate: PrecisionCDC.roundRate(logistic(score) * 100)}, nil
+}
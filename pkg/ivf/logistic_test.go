@@ -0,0 +1,87 @@
+package ivf
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogisticModel(t *testing.T) {
+	models, err := LoadLogisticModels("testdata/logistic_models.json")
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	m := models[0]
+	assert.Equal(t, "amh-example", m.Name())
+	assert.Equal(t, []string{"age", "amh", "eggSource"}, paramNames(m.Params()))
+
+	tests := []struct {
+		name     string
+		values   url.Values
+		expected float64
+		err      string
+	}{
+		{"All terms", url.Values{"age": {"32"}, "amh": {"4"}, "eggSource": {"Donor"}}, 83.2, ""},
+		{"Optional input left out", url.Values{"age": {"32"}, "eggSource": {"Own"}}, 45.02, ""},
+		{"Required input missing", url.Values{"amh": {"4"}, "eggSource": {"Own"}}, 0, "age is required"},
+		{"Integer input with decimals", url.Values{"age": {"32.5"}, "eggSource": {"Own"}}, 0,
+			"age has invalid value 32.5"},
+		{"Out of range", url.Values{"age": {"32"}, "amh": {"25"}, "eggSource": {"Own"}}, 0,
+			"amh must be between 0 and 20. Got 25"},
+		{"Unknown level", url.Values{"age": {"32"}, "eggSource": {"Frozen"}}, 0, "eggSource has invalid value Frozen"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := m.Predict(tt.values)
+			if tt.err != "" {
+				var inputErr *InputError
+				require.True(t, errors.As(err, &inputErr))
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, &Result{Model: "amh-example", SuccessRate: tt.expected}, result)
+		})
+	}
+}
+
+func TestLoadLogisticModelsInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition string
+		err        string
+	}{
+		{"Missing name", `[{"inputs": []}]`, "model name is required"},
+		{"String input without enum", `[{"name": "m", "inputs": [{"name": "a", "type": "string"}]}]`,
+			"model m: input a: string inputs need an enum"},
+		{"Unknown type", `[{"name": "m", "inputs": [{"name": "a", "type": "bool"}]}]`,
+			`model m: input a: unknown type "bool"`},
+		{"Half a range", `[{"name": "m", "inputs": [{"name": "a", "type": "number", "minimum": 0}]}]`,
+			"model m: input a: set both minimum and maximum or neither"},
+		{"Term on unknown input", `[{"name": "m", "terms": [{"input": "amh", "coefficient": 1}]}]`,
+			`model m: term 1: unknown input "amh"`},
+		{"Unknown level", `[{"name": "m", "inputs": [{"name": "a", "type": "string", "enum": ["x"]}], ` +
+			`"terms": [{"input": "a", "levels": {"y": 1}}]}]`, `model m: term 1: a has no level "y"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "models.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.definition), 0o600))
+
+			_, err := LoadLogisticModels(path)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func paramNames(params []Param) []string {
+	var names []string
+	for _, p := range params {
+		names = append(names, p.Name)
+	}
+	return names
+}
@@ -0,0 +1,112 @@
+package ivf
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+// CDCModelName is the name of the CDC model, the default prediction model.
+const CDCModelName = "cdc"
+
+// PredictionModel predicts the chance of a live birth from the form values. Models declare the inputs they read,
+// so alternative models, e.g. with AMH or the number of embryos, can take other inputs than the CDC form.
+type PredictionModel interface {
+	// Name is the name the model is selected by, e.g. /calculate?model=cdc.
+	Name() string
+	// Params describes the inputs the model reads.
+	Params() []Param
+	// Predict validates the inputs and returns the chance of a live birth. Invalid inputs are reported as *InputError.
+	Predict(values url.Values) (*Result, error)
+}
+
+// Registry holds the prediction models by name.
+type Registry struct {
+	models       map[string]PredictionModel
+	defaultModel string
+}
+
+// NewRegistry returns a registry of the given models. The first model is the default one.
+func NewRegistry(models ...PredictionModel) (*Registry, error) {
+	r := &Registry{models: map[string]PredictionModel{}}
+	for _, m := range models {
+		if err := r.Register(m); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register adds the model to the registry. The first registered model is the default one.
+func (r *Registry) Register(m PredictionModel) error {
+	if m.Name() == "" {
+		return fmt.Errorf("model name is required")
+	}
+	if _, exists := r.models[m.Name()]; exists {
+		return fmt.Errorf("model %s is already registered", m.Name())
+	}
+	r.models[m.Name()] = m
+	if r.defaultModel == "" {
+		r.defaultModel = m.Name()
+	}
+	return nil
+}
+
+// Model returns the model with the given name, or the default model when the name is empty.
+// Unknown names are reported as *InputError on the model param.
+func (r *Registry) Model(name string) (PredictionModel, error) {
+	if name == "" {
+		name = r.defaultModel
+	}
+	m, ok := r.models[name]
+	if !ok {
+		return nil, inputErrorf("model", "model has invalid value %s", name)
+	}
+	return m, nil
+}
+
+// Names returns the names of the registered models in alphabetical order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.models))
+	for name := range r.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CDCModel is the CDC IVF success estimator: a logistic formula selected by egg source, prior IVF and whether the
+// infertility reason is known.
+type CDCModel struct {
+	Formulas FormulaGetter
+}
+
+// NewCDCModel returns the CDC model reading its formulas from the given repo.
+func NewCDCModel(formulas FormulaGetter) *CDCModel {
+	return &CDCModel{Formulas: formulas}
+}
+
+func (m *CDCModel) Name() string {
+	return CDCModelName
+}
+
+func (m *CDCModel) Params() []Param {
+	return Params()
+}
+
+func (m *CDCModel) Predict(values url.Values) (*Result, error) {
+	input, err := ParseInput(values)
+	if err != nil {
+		return nil, err
+	}
+	f, err := m.Formulas.GetFormula(input.UseOwnEggs, input.IVFUsed, input.ReasonKnown)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation, err := Explain(f, input)
+	if err != nil {
+		return nil, err
+	}
+	return newCDCResult(f, explanation), nil
+}
@@ -0,0 +1,77 @@
+package ivf
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// amhModel is a logistic model on the AMH level alone, standing in for a clinic-trained model.
+type amhModel struct{}
+
+func (m *amhModel) Name() string { return "amh" }
+
+func (m *amhModel) Params() []Param {
+	return []Param{{Name: "amh", Description: "AMH level in ng/mL.", Type: "integer", Minimum: intPtr(0),
+		Maximum: intPtr(20), Required: true}}
+}
+
+func (m *amhModel) Predict(values url.Values) (*Result, error) {
+	amh, err := strconv.Atoi(values.Get("amh"))
+	if err != nil || amh < 0 || amh > 20 {
+		return nil, inputErrorf("amh", "amh must be between 0 and 20. Got %s", values.Get("amh"))
+	}
+	return &Result{Model: m.Name(), SuccessRate: logistic(-1+0.2*float64(amh)) * 100}, nil
+}
+
+func TestRegistry(t *testing.T) {
+	formulas, err := LoadFormulas(formulasPath)
+	require.NoError(t, err)
+	registry, err := NewRegistry(NewCDCModel(FormulaSet(formulas)), &amhModel{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"amh", "cdc"}, registry.Names())
+
+	t.Run("CDC model is the default", func(t *testing.T) {
+		m, err := registry.Model("")
+		require.NoError(t, err)
+		require.Equal(t, CDCModelName, m.Name())
+
+		patient := &Patient{Age: 32, Weight: 150, Feet: 5, Inches: 8, IVFUsed: "0", Gravida: "1",
+			PreviousLiveBirths: "1", Endometriosis: true, OvulatoryDisorder: true, EggSource: EggSourceOwn}
+		result, err := m.Predict(patient.Values())
+		require.NoError(t, err)
+		require.NotNil(t, result.Explanation)
+		assert.Equal(t, "1-3", result.Explanation.CDCFormula)
+		assert.Len(t, result.Explanation.Terms, 15)
+		result.Explanation = nil
+		assert.Equal(t, &Result{Model: CDCModelName, SuccessRate: 62.21, CDCFormula: "1-3", Precision: PrecisionCDC}, result)
+	})
+
+	t.Run("Model selected by name", func(t *testing.T) {
+		m, err := registry.Model("amh")
+		require.NoError(t, err)
+
+		result, err := m.Predict(url.Values{"amh": {"5"}})
+		require.NoError(t, err)
+		assert.Equal(t, "amh", result.Model)
+		assert.InDelta(t, 50, result.SuccessRate, 1e-9)
+	})
+
+	t.Run("Unknown model", func(t *testing.T) {
+		_, err := registry.Model("sart")
+
+		var inputErr *InputError
+		require.True(t, errors.As(err, &inputErr))
+		assert.Equal(t, "model", inputErr.Param)
+		assert.EqualError(t, err, "model has invalid value sart")
+	})
+
+	t.Run("Duplicate model", func(t *testing.T) {
+		assert.EqualError(t, registry.Register(&amhModel{}), "model amh is already registered")
+	})
+}
@@ -0,0 +1,77 @@
+package ivf
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// Egg sources accepted by the calculator.
+const (
+	EggSourceOwn   = "Own"
+	EggSourceDonor = "Donor"
+)
+
+// Patient holds the calculator inputs as typed values, mirroring the CDC form.
+type Patient struct {
+	Age    int
+	Weight int
+	Feet   int
+	Inches int
+	// IVFUsed is the number of prior IVF cycles: "0", "1", "2" or "3+".
+	IVFUsed string
+	// Gravida is the number of prior pregnancies: "0", "1" or "2+".
+	Gravida string
+	// PreviousLiveBirths is the number of prior live births: "0", "1" or "2+".
+	PreviousLiveBirths       string
+	TubalFactor              bool
+	MaleFactorInfertility    bool
+	Endometriosis            bool
+	OvulatoryDisorder        bool
+	DiminishedOvarianReserve bool
+	UterineFactor            bool
+	OtherReason              bool
+	UnexplainedInfertility   bool
+	DoNotKnow                bool
+	// EggSource is EggSourceOwn or EggSourceDonor.
+	EggSource string
+	// Precision selects the rounding of the results. Empty means PrecisionCDC.
+	Precision Precision
+}
+
+// NewInput validates the patient and converts it into the calculator input.
+func NewInput(p *Patient) (*Input, error) {
+	return ParseInput(p.Values())
+}
+
+// Values returns the patient as CDC form values, the same as the /calculate query parameters.
+func (p *Patient) Values() url.Values {
+	values := url.Values{}
+	values.Set("age", strconv.Itoa(p.Age))
+	values.Set("weight", strconv.Itoa(p.Weight))
+	values.Set("feet", strconv.Itoa(p.Feet))
+	values.Set("inches", strconv.Itoa(p.Inches))
+	values.Set("ivf_used", p.IVFUsed)
+	values.Set("gravida", p.Gravida)
+	values.Set("previous_live_births", p.PreviousLiveBirths)
+	values.Set("tubal_factor", yesNoValue(p.TubalFactor))
+	values.Set("male_factor_infertility", yesNoValue(p.MaleFactorInfertility))
+	values.Set("endometriosis", yesNoValue(p.Endometriosis))
+	values.Set("ovulatory_disorder", yesNoValue(p.OvulatoryDisorder))
+	values.Set("diminished_ovarian_reserve", yesNoValue(p.DiminishedOvarianReserve))
+	values.Set("uterine_factor", yesNoValue(p.UterineFactor))
+	values.Set("other_reason", yesNoValue(p.OtherReason))
+	values.Set("unexplained_infertility", yesNoValue(p.UnexplainedInfertility))
+	values.Set("donotknow", yesNoValue(p.DoNotKnow))
+	values.Set("eggSource", p.EggSource)
+	if p.Precision != "" {
+		values.Set("precision", string(p.Precision))
+	}
+	return values
+}
+
+func yesNoValue(v bool) string {
+	if v {
+		return "Yes"
+	}
+	return "No"
+}
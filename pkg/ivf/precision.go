@@ -0,0 +1,34 @@
+package ivf
+
+import "math"
+
+// Precision selects how the BMI and the calculated rates are rounded.
+type Precision string
+
+const (
+	// PrecisionCDC rounds the BMI to a single decimal and rates to 2 decimals, matching the CDC calculator.
+	// It is the default and should be used for patient-facing output.
+	PrecisionCDC Precision = "cdc"
+	// PrecisionExact skips all rounding, for research use.
+	PrecisionExact Precision = "exact"
+)
+
+// round rounds the value to the given number of decimals, unless the precision is exact.
+// The zero Precision rounds the same as PrecisionCDC.
+func (p Precision) round(value float64, decimals int) float64 {
+	if p == PrecisionExact {
+		return value
+	}
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
+
+// roundRate rounds a rate in percents to 2 decimals, unless the precision is exact.
+func (p Precision) roundRate(rate float64) float64 {
+	return p.round(rate, 2)
+}
+
+// roundBMI rounds the BMI to a single decimal, unless the precision is exact.
+func (p Precision) roundBMI(bmi float64) float64 {
+	return p.round(bmi, 1)
+}
@@ -0,0 +1,294 @@
+package ivf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// TermKind is the shape of a formula term.
+type TermKind string
+
+const (
+	// TermConstant adds the coefficient, e.g. the intercept.
+	TermConstant TermKind = "constant"
+	// TermLinear adds coefficient * input.
+	TermLinear TermKind = "linear"
+	// TermPower adds coefficient * input^exponent.
+	TermPower TermKind = "power"
+	// TermIndicator adds the level coefficient of a Yes/No input, keyed "true" or "false".
+	TermIndicator TermKind = "indicator"
+	// TermLookup adds the level coefficient of a categorical input, keyed by the input value.
+	TermLookup TermKind = "lookup"
+)
+
+// FormulaTerm is one term of a formula defined as data. Terms can add, drop or re-weight the CDC inputs without a code
+// change, but they can't read a new covariate: that still needs an entry in formulaInputs, a Param and its parsing in
+// ParseInput.
+type FormulaTerm struct {
+	// Name is the name of the term in explanations.
+	Name string   `json:"name"`
+	Kind TermKind `json:"kind"`
+	// Input is the name of the input the term reads, see FormulaInputs. Constant terms have no input.
+	Input       string  `json:"input,omitempty"`
+	Coefficient float64 `json:"coefficient,omitempty"`
+	Exponent    float64 `json:"exponent,omitempty"`
+	// Levels are the coefficients of indicator and lookup terms by input value. Missing levels add nothing.
+	Levels map[string]float64 `json:"levels,omitempty"`
+	// CoefficientName names the coefficient in a covariance file and defaults to Name. Indicator and lookup
+	// coefficients are named CoefficientName_level, e.g. tubal_factor_true or prior_pregnancies_2+.
+	CoefficientName string `json:"coefficient_name,omitempty"`
+}
+
+// inputKind is the kind of value of a formula input.
+type inputKind int
+
+const (
+	numericInput inputKind = iota
+	booleanInput
+	categoricalInput
+)
+
+// formulaInputs lists the inputs formula terms can read, in the order the CDC formula uses them, with the key of
+// their value in Input.Coefficients. age and bmi are read from the input directly. These are the CDC inputs ParseInput
+// validates: a formula definition can combine them freely, but a term on any other input, e.g. AMH, is rejected when
+// the formula is compiled. The list is code, not data: a new covariate is added here, to params and to ParseInput.
+// Models with other inputs are LogisticModels or implement PredictionModel.
+var formulaInputs = []struct {
+	name string
+	kind inputKind
+	key  string
+}{
+	{"age", numericInput, ""},
+	{"bmi", numericInput, ""},
+	{"tubal_factor", booleanInput, "tubalFactor"},
+	{"male_factor_infertility", booleanInput, "maleFactorInfertility"},
+	{"endometriosis", booleanInput, "endometriosis"},
+	{"ovulatory_disorder", booleanInput, "ovulatoryDisorder"},
+	{"diminished_ovarian_reserve", booleanInput, "diminishedOvarianReserve"},
+	{"uterine_factor", booleanInput, "uterineFactor"},
+	{"other_reason", booleanInput, "otherReason"},
+	{"unexplained_infertility", booleanInput, "unexplainedInfertility"},
+	{"gravida", categoricalInput, "priorPregnancies"},
+	{"previous_live_births", categoricalInput, "priorLiveBirths"},
+}
+
+// FormulaInputs returns the names of the inputs formula terms can read.
+func FormulaInputs() []string {
+	names := make([]string, 0, len(formulaInputs))
+	for _, in := range formulaInputs {
+		names = append(names, in.name)
+	}
+	return names
+}
+
+// compiledTerm is a FormulaTerm checked against the formula inputs, ready to be evaluated.
+type compiledTerm struct {
+	FormulaTerm
+	kind inputKind
+	key  string
+}
+
+// compileTerms checks the terms and resolves their inputs.
+func compileTerms(terms []FormulaTerm) ([]compiledTerm, error) {
+	compiled := make([]compiledTerm, 0, len(terms))
+	names := map[string]bool{}
+	for i, t := range terms {
+		if t.Name == "" {
+			return nil, fmt.Errorf("term %d: name is required", i+1)
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("term %s: duplicate name", t.Name)
+		}
+		names[t.Name] = true
+		if t.CoefficientName == "" {
+			t.CoefficientName = t.Name
+		}
+
+		c := compiledTerm{FormulaTerm: t}
+		if t.Kind == TermConstant {
+			if t.Input != "" {
+				return nil, fmt.Errorf("term %s: constant terms have no input", t.Name)
+			}
+			compiled = append(compiled, c)
+			continue
+		}
+
+		found := false
+		for _, in := range formulaInputs {
+			if in.name == t.Input {
+				c.kind, c.key, found = in.kind, in.key, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("term %s: unknown input %q, formula terms can read %s", t.Name, t.Input,
+				strings.Join(FormulaInputs(), ", "))
+		}
+
+		var expected inputKind
+		switch t.Kind {
+		case TermLinear, TermPower:
+			expected = numericInput
+		case TermIndicator:
+			expected = booleanInput
+			for level := range t.Levels {
+				if level != "true" && level != "false" {
+					return nil, fmt.Errorf("term %s: indicator levels are true and false, got %q", t.Name, level)
+				}
+			}
+		case TermLookup:
+			expected = categoricalInput
+			enum := lookupParam(t.Input).Enum
+			for level := range t.Levels {
+				if !slices.Contains(enum, level) {
+					return nil, fmt.Errorf("term %s: %s has no level %q", t.Name, t.Input, level)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("term %s: unknown kind %q", t.Name, t.Kind)
+		}
+		if c.kind != expected {
+			return nil, fmt.Errorf("term %s: %s terms can't read input %s", t.Name, t.Kind, t.Input)
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// evaluate returns the contribution of the term for the input, and false when the input has no value for it.
+func (t *compiledTerm) evaluate(params *Input, bmi float64) (Term, bool) {
+	term := Term{Name: t.Name, coefficient: t.CoefficientName, covariate: 1}
+	switch t.Kind {
+	case TermConstant:
+		term.Contribution = t.Coefficient
+	case TermLinear, TermPower:
+		x := bmi
+		if t.Input == "age" {
+			x = float64(params.Age)
+		}
+		term.Input = strconv.FormatFloat(x, 'f', -1, 64)
+		term.covariate = x
+		if t.Kind == TermPower {
+			term.covariate = math.Pow(x, t.Exponent)
+		}
+		term.Contribution = t.Coefficient * term.covariate
+	case TermIndicator:
+		v, ok := params.Coefficients[t.key].(bool)
+		if !ok {
+			return Term{}, false
+		}
+		term.Input = yesNoValue(v)
+		term.coefficient = t.CoefficientName + "_" + strconv.FormatBool(v)
+		term.Contribution = t.Levels[strconv.FormatBool(v)]
+	case TermLookup:
+		v, ok := params.Coefficients[t.key].(string)
+		if !ok {
+			return Term{}, false
+		}
+		term.Input = v
+		term.coefficient = t.CoefficientName + "_" + v
+		term.Contribution = t.Levels[v]
+	}
+	return term, true
+}
+
+// coefficientNames returns the names of the coefficients the terms estimate.
+func coefficientNames(terms []compiledTerm) map[string]bool {
+	names := map[string]bool{}
+	for _, t := range terms {
+		switch t.Kind {
+		case TermIndicator, TermLookup:
+			for level := range t.Levels {
+				names[t.CoefficientName+"_"+level] = true
+			}
+		default:
+			names[t.CoefficientName] = true
+		}
+	}
+	return names
+}
+
+// cdcTerms returns the terms of a formula in the CDC CSV layout.
+func cdcTerms(c *Coefficients) []FormulaTerm {
+	indicator := func(name string, levels map[bool]float64) FormulaTerm {
+		return FormulaTerm{Name: name, Kind: TermIndicator, Input: name,
+			Levels: map[string]float64{"true": levels[true], "false": levels[false]}}
+	}
+	return []FormulaTerm{
+		{Name: "intercept", Kind: TermConstant, Coefficient: c.Intercept},
+		{Name: "age_linear", Kind: TermLinear, Input: "age", Coefficient: c.AgeLinear},
+		{Name: "age_power", Kind: TermPower, Input: "age", Coefficient: c.AgePower, Exponent: c.AgePowerFactor},
+		{Name: "bmi_linear", Kind: TermLinear, Input: "bmi", Coefficient: c.BMILinear},
+		{Name: "bmi_power", Kind: TermPower, Input: "bmi", Coefficient: c.BMIPower, Exponent: c.BMIPowerFactor},
+		indicator("tubal_factor", c.TubalFactor),
+		indicator("male_factor_infertility", c.MaleFactorInfertility),
+		indicator("endometriosis", c.Endometriosis),
+		indicator("ovulatory_disorder", c.OvulatoryDisorder),
+		indicator("diminished_ovarian_reserve", c.DiminishedOvarianReserve),
+		indicator("uterine_factor", c.UterineFactor),
+		indicator("other_reason", c.OtherReason),
+		indicator("unexplained_infertility", c.UnexplainedInfertility),
+		{Name: "gravida", Kind: TermLookup, Input: "gravida", Levels: c.PriorPregnancies,
+			CoefficientName: "prior_pregnancies"},
+		{Name: "previous_live_births", Kind: TermLookup, Input: "previous_live_births", Levels: c.PriorLiveBirths,
+			CoefficientName: "prior_live_births"},
+	}
+}
+
+// formulaDefinition is a formula in the JSON definitions layout.
+type formulaDefinition struct {
+	CDCFormula                  string        `json:"cdc_formula"`
+	UsingOwnEggs                string        `json:"using_own_eggs"`
+	AttemptedIVFPreviously      string        `json:"attempted_ivf_previously"`
+	IsReasonForInfertilityKnown string        `json:"is_reason_for_infertility_known"`
+	Terms                       []FormulaTerm `json:"terms"`
+}
+
+// ReadFormulaDefinitions reads formulas defined as term lists from JSON data: an array of objects with
+// cdc_formula, using_own_eggs, attempted_ivf_previously, is_reason_for_infertility_known and terms.
+// The terms are compiled once, here.
+func ReadFormulaDefinitions(r io.Reader) ([]*Formula, error) {
+	var definitions []formulaDefinition
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&definitions); err != nil {
+		return nil, fmt.Errorf("error reading formula definitions: %w", err)
+	}
+
+	formulas := make([]*Formula, 0, len(definitions))
+	for _, d := range definitions {
+		f := &Formula{
+			UsingOwnEggs:                d.UsingOwnEggs,
+			AttemptedIVFPreviously:      d.AttemptedIVFPreviously,
+			IsReasonForInfertilityKnown: d.IsReasonForInfertilityKnown,
+			CDCFormula:                  d.CDCFormula,
+			Terms:                       d.Terms,
+		}
+		if err := f.Compile(); err != nil {
+			return nil, fmt.Errorf("formula %s: %w", d.CDCFormula, err)
+		}
+		formulas = append(formulas, f)
+	}
+	return formulas, nil
+}
+
+// WriteFormulaDefinitions writes the formulas as term lists in the layout ReadFormulaDefinitions reads.
+func WriteFormulaDefinitions(w io.Writer, formulas []*Formula) error {
+	definitions := make([]formulaDefinition, 0, len(formulas))
+	for _, f := range formulas {
+		definitions = append(definitions, formulaDefinition{
+			CDCFormula:                  f.CDCFormula,
+			UsingOwnEggs:                f.UsingOwnEggs,
+			AttemptedIVFPreviously:      f.AttemptedIVFPreviously,
+			IsReasonForInfertilityKnown: f.IsReasonForInfertilityKnown,
+			Terms:                       f.Terms,
+		})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(definitions)
+}
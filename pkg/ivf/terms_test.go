@@ -0,0 +1,109 @@
+package ivf
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const definitionsPath = "../../internal/repo/data/ivf_success_formulas.json"
+
+func TestFormulaDefinitionsMatchCSV(t *testing.T) {
+	csvFormulas, err := LoadFormulas(formulasPath)
+	require.NoError(t, err)
+	jsonFormulas, err := LoadFormulas(definitionsPath)
+	require.NoError(t, err)
+	require.Len(t, jsonFormulas, len(csvFormulas))
+
+	input, err := NewInput(readmePatient)
+	require.NoError(t, err)
+	input.Precision = PrecisionExact
+
+	for i, csvFormula := range csvFormulas {
+		t.Run(csvFormula.CDCFormula, func(t *testing.T) {
+			jsonFormula := jsonFormulas[i]
+			assert.Equal(t, csvFormula.CDCFormula, jsonFormula.CDCFormula)
+			assert.Equal(t, csvFormula.UsingOwnEggs, jsonFormula.UsingOwnEggs)
+			assert.Equal(t, csvFormula.AttemptedIVFPreviously, jsonFormula.AttemptedIVFPreviously)
+			assert.Equal(t, csvFormula.IsReasonForInfertilityKnown, jsonFormula.IsReasonForInfertilityKnown)
+			expected, err := Explain(csvFormula, input)
+			require.NoError(t, err)
+			actual, err := Explain(jsonFormula, input)
+			require.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestReadFormulaDefinitions(t *testing.T) {
+	t.Run("New terms are data only", func(t *testing.T) {
+		formulas, err := ReadFormulaDefinitions(strings.NewReader(`[{
+			"cdc_formula": "custom", "using_own_eggs": "TRUE", "attempted_ivf_previously": "FALSE",
+			"is_reason_for_infertility_known": "TRUE",
+			"terms": [
+				{"name": "intercept", "kind": "constant", "coefficient": -2},
+				{"name": "age_squared", "kind": "power", "input": "age", "coefficient": 0.001, "exponent": 2},
+				{"name": "pcos", "kind": "indicator", "input": "ovulatory_disorder", "levels": {"true": 0.5}},
+				{"name": "gravida", "kind": "lookup", "input": "gravida", "levels": {"1": 0.25}}
+			]}]`))
+		require.NoError(t, err)
+		input, err := NewInput(readmePatient)
+		require.NoError(t, err)
+
+		explanation, err := Explain(formulas[0], input)
+		require.NoError(t, err)
+
+		score := -2 + 0.001*32*32 + 0.5 + 0.25
+		assert.InDelta(t, score, explanation.Score, 1e-12)
+		assert.Equal(t, math.Round(logistic(score)*10000)/100, explanation.SuccessRate)
+		assert.Equal(t, []Term{
+			{Name: "intercept", Contribution: -2, coefficient: "intercept", covariate: 1},
+			{Name: "age_squared", Input: "32", Contribution: 0.001 * 32 * 32, coefficient: "age_squared", covariate: 32 * 32},
+			{Name: "pcos", Input: "Yes", Contribution: 0.5, coefficient: "pcos_true", covariate: 1},
+			{Name: "gravida", Input: "1", Contribution: 0.25, coefficient: "gravida_1", covariate: 1},
+		}, explanation.Terms)
+	})
+
+	tests := []struct {
+		name          string
+		terms         string
+		expectedError string
+	}{
+		{"Unknown input", `{"name": "amh", "kind": "linear", "input": "amh", "coefficient": 1}`,
+			`formula x: term amh: unknown input "amh", formula terms can read age, bmi, tubal_factor, ` +
+				"male_factor_infertility, endometriosis, ovulatory_disorder, diminished_ovarian_reserve, uterine_factor, " +
+				"other_reason, unexplained_infertility, gravida, previous_live_births"},
+		{"Unknown kind", `{"name": "age", "kind": "spline", "input": "age"}`,
+			`formula x: term age: unknown kind "spline"`},
+		{"Wrong input kind", `{"name": "age", "kind": "indicator", "input": "age"}`,
+			"formula x: term age: indicator terms can't read input age"},
+		{"Unknown level", `{"name": "gravida", "kind": "lookup", "input": "gravida", "levels": {"3": 1}}`,
+			`formula x: term gravida: gravida has no level "3"`},
+		{"Constant with input", `{"name": "intercept", "kind": "constant", "input": "age"}`,
+			"formula x: term intercept: constant terms have no input"},
+		{"Duplicate name", `{"name": "a", "kind": "constant"}, {"name": "a", "kind": "constant"}`,
+			"formula x: term a: duplicate name"},
+	}
+
+	t.Run("Formula built in code with invalid terms", func(t *testing.T) {
+		input, err := NewInput(readmePatient)
+		require.NoError(t, err)
+		f := &Formula{CDCFormula: "custom", UsingOwnEggs: input.UseOwnEggs, AttemptedIVFPreviously: input.IVFUsed,
+			IsReasonForInfertilityKnown: input.ReasonKnown, Terms: []FormulaTerm{{Name: "amh", Kind: TermLinear, Input: "amh"}}}
+
+		_, err = Calculate([]*Formula{f}, input)
+		assert.ErrorContains(t, err, `formula custom: term amh: unknown input "amh"`)
+		assert.ErrorContains(t, f.Compile(), `term amh: unknown input "amh"`)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadFormulaDefinitions(strings.NewReader(`[{"cdc_formula": "x", "terms": [` + tt.terms + `]}]`))
+
+			assert.EqualError(t, err, tt.expectedError)
+		})
+	}
+}